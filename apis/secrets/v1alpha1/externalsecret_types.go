@@ -17,6 +17,7 @@ limitations under the License.
 package v1alpha1
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
@@ -32,14 +33,55 @@ type ExternalSecretStoreRef struct {
 	Name string `json:"name"`
 }
 
+// ExternalSecretDeletionPolicy defines how the target Secret is cleaned up
+type ExternalSecretDeletionPolicy string
+
+const (
+	// DeletionPolicyDelete deletes the target Secret, this is the default
+	DeletionPolicyDelete ExternalSecretDeletionPolicy = "Delete"
+	// DeletionPolicyRetain keeps the target Secret and its data
+	DeletionPolicyRetain ExternalSecretDeletionPolicy = "Retain"
+	// DeletionPolicyMerge only removes the keys written by the ExternalSecret from the target Secret
+	DeletionPolicyMerge ExternalSecretDeletionPolicy = "Merge"
+)
+
+// ExternalSecretDecodingStrategy defines how retrieved values are decoded
+// before they are written to the target Secret
+type ExternalSecretDecodingStrategy string
+
+const (
+	// DecodingStrategyNone writes values as retrieved, this is the default
+	DecodingStrategyNone ExternalSecretDecodingStrategy = "None"
+	// DecodingStrategyBase64 decodes standard base64 values
+	DecodingStrategyBase64 ExternalSecretDecodingStrategy = "Base64"
+	// DecodingStrategyBase64URL decodes URL safe base64 values
+	DecodingStrategyBase64URL ExternalSecretDecodingStrategy = "Base64URL"
+	// DecodingStrategyHex decodes hexadecimal values
+	DecodingStrategyHex ExternalSecretDecodingStrategy = "Hex"
+	// DecodingStrategyPKCS12 unpacks PKCS#12 archives into the PEM certificate chain and private key
+	DecodingStrategyPKCS12 ExternalSecretDecodingStrategy = "PKCS12"
+	// DecodingStrategyAuto decodes base64 and URL safe base64 values, and writes other values as retrieved
+	DecodingStrategyAuto ExternalSecretDecodingStrategy = "Auto"
+)
+
 // ExternalSecretTarget ...
 type ExternalSecretTarget struct {
 	//  Name of the target Secret Resource
 	//  defaults to .metadata.name of the ExternalSecret. immutable.
 	// +kubebuilder:validation:Optional
 	Name string `json:"name,omitempty"`
+	// Type of the target Secret, defaults to Opaque.
+	// The data must contain the keys required by the type.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=Opaque;kubernetes.io/tls;kubernetes.io/dockerconfigjson;kubernetes.io/basic-auth;kubernetes.io/ssh-auth
+	Type corev1.SecretType `json:"type,omitempty"`
 	// +kubebuilder:validation:Optional
 	CreationPolicy string `json:"creationPolicy,omitempty"`
+	// What happens to the target Secret when the ExternalSecret is deleted or targets another Secret:
+	// Delete it, Retain it as is, or Merge: only remove the keys written by the ExternalSecret
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=Delete;Retain;Merge
+	DeletionPolicy ExternalSecretDeletionPolicy `json:"deletionPolicy,omitempty"`
 	// +kubebuilder:validation:Optional
 	Template runtime.RawExtension `json:"template,omitempty"`
 }
@@ -52,6 +94,89 @@ type ExternalSecretData struct {
 	Key string `json:"key"`
 	// Version of the secret to be retrieved
 	Version string `json:"version,omitempty"`
+	// Key of the target Secret the value is written to, defaults to Key
+	// +kubebuilder:validation:Optional
+	SecretKey string `json:"secretKey,omitempty"`
+	// Property of the secret to write instead of the whole secret, e.g. tls.crt
+	// or tls.key of an Azure Key Vault certificate
+	// +kubebuilder:validation:Optional
+	Property string `json:"property,omitempty"`
+	// How the value is decoded before it is written, defaults to None.
+	// PKCS12 archives are unpacked into their tls.crt and tls.key properties.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=None;Base64;Base64URL;Hex;PKCS12;Auto
+	DecodingStrategy ExternalSecretDecodingStrategy `json:"decodingStrategy,omitempty"`
+}
+
+// ExternalSecretDataFrom retrieves all the secrets listed by the backend of the
+// SecretStore, e.g. all the variables of a GitLab project
+type ExternalSecretDataFrom struct {
+	// Only retrieve the secrets whose Key/Name starts with Prefix
+	// +kubebuilder:validation:Optional
+	Prefix string `json:"prefix,omitempty"`
+	// How the listed values are decoded before they are written, defaults to None
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=None;Base64;Base64URL;Hex;PKCS12;Auto
+	DecodingStrategy ExternalSecretDecodingStrategy `json:"decodingStrategy,omitempty"`
+}
+
+// ExternalSecretRolloutTarget is a workload restarted when the target Secret data changes
+type ExternalSecretRolloutTarget struct {
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Enum=Deployment;StatefulSet;DaemonSet
+	Kind string `json:"kind"`
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+}
+
+// ExternalSecretPreviousVersion writes the version preceding each retrieved key
+// next to it, so that consumers accept both values while secrets are rotated
+type ExternalSecretPreviousVersion struct {
+	// Suffix appended to the key of the previous version, defaults to "_previous"
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Pattern=`^[-._a-zA-Z0-9]+$`
+	Suffix string `json:"suffix,omitempty"`
+}
+
+// ExternalSecretKeyTransform transforms the keys of the target Secret
+type ExternalSecretKeyTransform string
+
+const (
+	// KeyTransformUpper upper cases keys, e.g. for environment variables
+	KeyTransformUpper ExternalSecretKeyTransform = "Upper"
+	// KeyTransformLower lower cases keys
+	KeyTransformLower ExternalSecretKeyTransform = "Lower"
+	// KeyTransformSanitize replaces the characters not allowed in Secret keys with "_"
+	KeyTransformSanitize ExternalSecretKeyTransform = "Sanitize"
+)
+
+// ExternalSecretRewriteRegexp replaces the matches of a regular expression in keys
+type ExternalSecretRewriteRegexp struct {
+	// Regular expression matched against the key, in Go syntax
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	Source string `json:"source"`
+	// Replacement of the matches, may reference groups of Source like $1 or ${name}
+	// +kubebuilder:validation:Optional
+	Target string `json:"target"`
+}
+
+// ExternalSecretRewrite rewrites the keys of the target Secret generated from
+// remote keys: the keys of data without secretKey and the keys listed by dataFrom.
+// The fields set are applied in order: regexp, stripPrefix, addPrefix then transform.
+type ExternalSecretRewrite struct {
+	// +kubebuilder:validation:Optional
+	Regexp *ExternalSecretRewriteRegexp `json:"regexp,omitempty"`
+	// Prefix removed from keys starting with it
+	// +kubebuilder:validation:Optional
+	StripPrefix string `json:"stripPrefix,omitempty"`
+	// Prefix added to keys
+	// +kubebuilder:validation:Optional
+	AddPrefix string `json:"addPrefix,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=Upper;Lower;Sanitize
+	Transform ExternalSecretKeyTransform `json:"transform,omitempty"`
 }
 
 // ExternalSecretSpec defines the desired state of ExternalSecret
@@ -59,11 +184,13 @@ type ExternalSecretSpec struct {
 	// INSERT ADDITIONAL SPEC FIELDS - desired state of cluster
 	// Important: Run "make" to regenerate code after modifying this file
 
-	// Secrets
-	// +kubebuilder:validation:Required
+	// Secrets, at least one of data or dataFrom is required
+	// +kubebuilder:validation:Optional
 	// +kubebuilder:validation:MaxItems=20
-	// +kubebuilder:validation:MinItems=1
-	Data []ExternalSecretData `json:"data"`
+	Data []ExternalSecretData `json:"data,omitempty"`
+	// Retrieve all the secrets listed by the backend, for backends able to list them
+	// +kubebuilder:validation:Optional
+	DataFrom []ExternalSecretDataFrom `json:"dataFrom,omitempty"`
 	// SecretStore reference
 	// +kubebuilder:validation:Required
 	StoreRef ExternalSecretStoreRef `json:"storeRef"`
@@ -74,6 +201,53 @@ type ExternalSecretSpec struct {
 	RefreshInterval string `json:"refreshInterval,omitempty"`
 	// +kubebuilder:validation:Optional
 	Target ExternalSecretTarget `json:"target,omitempty"`
+	// Workloads in the same namespace restarted when the target Secret data changes
+	// +kubebuilder:validation:Optional
+	RolloutTargets []ExternalSecretRolloutTarget `json:"rolloutTargets,omitempty"`
+	// Also write the previous version of each key, under the key followed by a suffix
+	// +kubebuilder:validation:Optional
+	PreviousVersion *ExternalSecretPreviousVersion `json:"previousVersion,omitempty"`
+	// Rules rewriting the keys generated from remote keys, applied in order.
+	// Remote keys rewritten to the same key are reported instead of overwriting each other.
+	// +kubebuilder:validation:Optional
+	Rewrite []ExternalSecretRewrite `json:"rewrite,omitempty"`
+}
+
+const (
+	// ExternalSecretReady tells whether the target Secret is in sync with the SecretStore
+	ExternalSecretReady = "Ready"
+
+	// ReasonSynced means the target Secret was written from the SecretStore
+	ReasonSynced = "Synced"
+	// ReasonStoreNotFound means the referenced SecretStore does not exist or is being deleted
+	ReasonStoreNotFound = "StoreNotFound"
+	// ReasonInvalidVersion means the version of a key is not supported by the backend of the SecretStore
+	ReasonInvalidVersion = "InvalidVersion"
+	// ReasonKeyCollision means several remote keys are written to the same key of the target Secret
+	ReasonKeyCollision = "KeyCollision"
+	// ReasonInvalidKey means a remote key is written to a key not valid in a Secret
+	ReasonInvalidKey = "InvalidKey"
+	// ReasonAccessDenied means the policy of the SecretStore denies reading a key
+	ReasonAccessDenied = "AccessDenied"
+)
+
+// ExternalSecretSource records where a key of the target Secret was retrieved
+// from. It never holds the value itself.
+type ExternalSecretSource struct {
+	// Key of the target Secret
+	SecretKey string `json:"secretKey"`
+	// Type of the backend, e.g. asm or gsm
+	Backend string `json:"backend"`
+	// Name of the SecretStore
+	Store string `json:"store"`
+	// Key/Name of the secret held in the ExternalBackend
+	RemoteKey string `json:"remoteKey"`
+	// Property of the secret written, if any
+	// +optional
+	Property string `json:"property,omitempty"`
+	// Version retrieved, as reported by the backend
+	// +optional
+	Version string `json:"version,omitempty"`
 }
 
 // ExternalSecretStatus defines the observed state of ExternalSecret
@@ -84,6 +258,14 @@ type ExternalSecretStatus struct {
 	Phase string `json:"phase,omitempty"`
 	// Conditions represent the latest available observations of an object's state
 	Conditions []metav1.Condition `json:"conditions"`
+	// Name of the Secret last written by the operator
+	TargetName string `json:"targetName,omitempty"`
+	// Keys last written by the operator in the target Secret
+	TargetKeys []string `json:"targetKeys,omitempty"`
+	// Sources of the keys last written by the operator in the target Secret
+	Sources []ExternalSecretSource `json:"sources,omitempty"`
+	// Hash of the target Secret data last rolled out to the rollout targets
+	RolloutHash string `json:"rolloutHash,omitempty"`
 }
 
 // +kubebuilder:object:root=true
@@ -33,6 +33,53 @@ type SecretStoreSpec struct {
 	// +kubebuilder:validation:Type=string
 	Controller string               `json:"controller"`
 	Store      runtime.RawExtension `json:"store"`
+	// Policy restricting the remote keys ExternalSecrets may read from the store,
+	// all keys may be read without policy
+	// +kubebuilder:validation:Optional
+	Policy *SecretStorePolicy `json:"policy,omitempty"`
+}
+
+// SecretStorePolicyAction allows or denies reading remote keys
+type SecretStorePolicyAction string
+
+const (
+	// PolicyActionAllow allows reading the matching remote keys
+	PolicyActionAllow SecretStorePolicyAction = "Allow"
+	// PolicyActionDeny denies reading the matching remote keys
+	PolicyActionDeny SecretStorePolicyAction = "Deny"
+)
+
+// SecretStorePolicy decides which remote keys ExternalSecrets may read. The
+// first rule matching both the remote key and the ExternalSecret applies.
+type SecretStorePolicy struct {
+	// +kubebuilder:validation:Optional
+	Rules []SecretStorePolicyRule `json:"rules,omitempty"`
+	// Action when no rule matches, defaults to Deny
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=Allow;Deny
+	DefaultAction SecretStorePolicyAction `json:"defaultAction,omitempty"`
+}
+
+// SecretStorePolicyRule allows or denies ExternalSecrets in Namespaces and
+// matching Selector to read the remote keys matching Keys. Whoever creates an
+// ExternalSecret chooses its labels, a Selector alone is not an access
+// boundary between the tenants of a namespace, Namespaces is.
+type SecretStorePolicyRule struct {
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Enum=Allow;Deny
+	Action SecretStorePolicyAction `json:"action"`
+	// Patterns of remote keys, as matched by Go's path.Match: * does not match /.
+	// Matches all keys when empty.
+	// +kubebuilder:validation:Optional
+	Keys []string `json:"keys,omitempty"`
+	// Namespaces of the ExternalSecrets, matches all namespaces when empty
+	// +kubebuilder:validation:Optional
+	Namespaces []string `json:"namespaces,omitempty"`
+	// Labels of the ExternalSecrets, matches all ExternalSecrets when empty.
+	// Labels are set by whoever creates the ExternalSecret, they select
+	// ExternalSecrets but do not authorize them.
+	// +kubebuilder:validation:Optional
+	Selector *metav1.LabelSelector `json:"selector,omitempty"`
 }
 
 // SecretStoreStatus defines the observed state of SecretStore
@@ -45,6 +92,20 @@ type SecretStoreStatus struct {
 	Conditions []metav1.Condition `json:"conditions"`
 }
 
+const (
+	// SecretStoreReady tells whether the backend of the SecretStore is initialized and reachable
+	SecretStoreReady = "Ready"
+
+	// ReasonInitialized means the backend is initialized, it has no health check
+	ReasonInitialized = "Initialized"
+	// ReasonInitFailed means the backend could not be initialized
+	ReasonInitFailed = "InitFailed"
+	// ReasonHealthy means the backend passed its last health check
+	ReasonHealthy = "Healthy"
+	// ReasonUnhealthy means the backend failed its last health check
+	ReasonUnhealthy = "Unhealthy"
+)
+
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
 
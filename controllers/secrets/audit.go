@@ -0,0 +1,63 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"encoding/json"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+
+	secretsv1alpha1 "github.com/containersolutions/externalsecret-operator/apis/secrets/v1alpha1"
+	"github.com/containersolutions/externalsecret-operator/pkg/backend"
+)
+
+// SourcesAnnotation records on the target Secret where each of its keys was
+// retrieved from, as a JSON list of ExternalSecretSource
+const SourcesAnnotation = annotationPrefix + "sources"
+
+// newSource describes where the value of data was retrieved from, without the value
+func newSource(backendType string, store string, data secretsv1alpha1.ExternalSecretData, value *backend.Value) secretsv1alpha1.ExternalSecretSource {
+	return secretsv1alpha1.ExternalSecretSource{
+		SecretKey: secretKey(data),
+		Backend:   backendType,
+		Store:     store,
+		RemoteKey: data.Key,
+		Property:  data.Property,
+		Version:   value.Metadata[backend.MetadataVersion],
+	}
+}
+
+func sortSources(sources []secretsv1alpha1.ExternalSecretSource) {
+	sort.Slice(sources, func(i, j int) bool {
+		return sources[i].SecretKey < sources[j].SecretKey
+	})
+}
+
+// setSourcesAnnotation records sources on secret
+func setSourcesAnnotation(secret *corev1.Secret, sources []secretsv1alpha1.ExternalSecretSource) error {
+	value, err := json.Marshal(sources)
+	if err != nil {
+		return err
+	}
+
+	if secret.Annotations == nil {
+		secret.Annotations = map[string]string{}
+	}
+	secret.Annotations[SourcesAnnotation] = string(value)
+	return nil
+}
@@ -0,0 +1,36 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	secretsv1alpha1 "github.com/containersolutions/externalsecret-operator/apis/secrets/v1alpha1"
+	"github.com/containersolutions/externalsecret-operator/pkg/utils"
+)
+
+// setReadyCondition records whether the target Secret of s is in sync
+func (r *ExternalSecretReconciler) setReadyCondition(ctx context.Context, s *secretsv1alpha1.ExternalSecret, status metav1.ConditionStatus, reason string, message string) error {
+	return utils.SetStatusCondition(ctx, r, s, &s.Status.Conditions, metav1.Condition{
+		Type:    secretsv1alpha1.ExternalSecretReady,
+		Status:  status,
+		Reason:  reason,
+		Message: message,
+	})
+}
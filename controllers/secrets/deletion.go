@@ -0,0 +1,163 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	secretsv1alpha1 "github.com/containersolutions/externalsecret-operator/apis/secrets/v1alpha1"
+)
+
+// targetFinalizer ensures the target Secret is cleaned up according to the deletionPolicy
+const targetFinalizer = annotationPrefix + "target-cleanup"
+
+// finalize cleans up the target Secret of a deleted ExternalSecret and releases it
+func (r *ExternalSecretReconciler) finalize(ctx context.Context, s *secretsv1alpha1.ExternalSecret) error {
+	if !controllerutil.ContainsFinalizer(s, targetFinalizer) {
+		return nil
+	}
+
+	name := s.Status.TargetName
+	if name == "" {
+		name = targetName(s)
+	}
+
+	keys := s.Status.TargetKeys
+	if keys == nil {
+		for _, data := range s.Spec.Data {
+			keys = append(keys, secretKey(data))
+		}
+	}
+
+	if err := r.cleanupTarget(ctx, s, name, keys); err != nil {
+		return err
+	}
+
+	controllerutil.RemoveFinalizer(s, targetFinalizer)
+	return r.Update(ctx, s)
+}
+
+// recordTarget cleans up the previous target Secret when the target name
+// changed and records the Secret, the keys of data and their sources in the status
+func (r *ExternalSecretReconciler) recordTarget(ctx context.Context, s *secretsv1alpha1.ExternalSecret, name string, data map[string][]byte, sources []secretsv1alpha1.ExternalSecretSource) error {
+	previous := s.Status.TargetName
+	if previous != "" && previous != name {
+		r.Log.Info("Target Secret changed, cleaning up previous target", "previous", previous, "target", name)
+		if err := r.cleanupTarget(ctx, s, previous, s.Status.TargetKeys); err != nil {
+			return err
+		}
+	}
+
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	if previous == name && reflect.DeepEqual(keys, s.Status.TargetKeys) && reflect.DeepEqual(sources, s.Status.Sources) {
+		return nil
+	}
+
+	s.Status.TargetName = name
+	s.Status.TargetKeys = keys
+	s.Status.Sources = sources
+	if s.Status.Conditions == nil {
+		s.Status.Conditions = []metav1.Condition{}
+	}
+	return r.Status().Update(ctx, s)
+}
+
+// cleanupTarget applies the deletionPolicy of s to the Secret name. Secrets
+// not created by the ExternalSecret are never deleted, only its keys are removed.
+func (r *ExternalSecretReconciler) cleanupTarget(ctx context.Context, s *secretsv1alpha1.ExternalSecret, name string, keys []string) error {
+	secret := &corev1.Secret{}
+	err := r.Get(ctx, types.NamespacedName{Name: name, Namespace: s.Namespace}, secret)
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	owned := metav1.IsControlledBy(secret, s)
+	policy := s.Spec.Target.DeletionPolicy
+
+	if policy == secretsv1alpha1.DeletionPolicyRetain {
+		if !owned {
+			return nil
+		}
+		removeOwnerReference(secret, s)
+		return r.Update(ctx, secret)
+	}
+
+	if policy != secretsv1alpha1.DeletionPolicyMerge && owned {
+		return client.IgnoreNotFound(r.Delete(ctx, secret))
+	}
+
+	for _, key := range keys {
+		delete(secret.Data, key)
+	}
+	if owned && len(secret.Data) == 0 {
+		return client.IgnoreNotFound(r.Delete(ctx, secret))
+	}
+
+	removeOwnerReference(secret, s)
+	return r.Update(ctx, secret)
+}
+
+// mergeData returns the data of a Secret shared with other writers: keys
+// previously written by the ExternalSecret are replaced by data, others are kept
+func mergeData(existing map[string][]byte, data map[string][]byte, previousKeys []string) map[string][]byte {
+	merged := make(map[string][]byte, len(existing)+len(data))
+	for k, v := range existing {
+		merged[k] = v
+	}
+	for _, k := range previousKeys {
+		delete(merged, k)
+	}
+	for k, v := range data {
+		merged[k] = v
+	}
+	return merged
+}
+
+// previousKeys returns the keys last written by the ExternalSecret in the Secret name
+func previousKeys(s *secretsv1alpha1.ExternalSecret, name string) []string {
+	if s.Status.TargetName != name {
+		return nil
+	}
+	return s.Status.TargetKeys
+}
+
+func removeOwnerReference(object metav1.Object, owner metav1.Object) {
+	references := []metav1.OwnerReference{}
+	for _, reference := range object.GetOwnerReferences() {
+		if reference.UID != owner.GetUID() {
+			references = append(references, reference)
+		}
+	}
+	object.SetOwnerReferences(references)
+}
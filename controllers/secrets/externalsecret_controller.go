@@ -19,27 +19,47 @@ package controllers
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/go-logr/logr"
-	"github.com/prometheus/common/log"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	secretsv1alpha1 "github.com/containersolutions/externalsecret-operator/apis/secrets/v1alpha1"
 	storev1alpha1 "github.com/containersolutions/externalsecret-operator/apis/store/v1alpha1"
 
 	"github.com/containersolutions/externalsecret-operator/pkg/backend"
+	config "github.com/containersolutions/externalsecret-operator/pkg/config"
+	"github.com/containersolutions/externalsecret-operator/pkg/policy"
+	"github.com/containersolutions/externalsecret-operator/pkg/store"
 )
 
 const (
 	defaulRetryPeriod      = time.Second * 30
 	defaultRefreshInterval = time.Hour * 1
+
+	// defaultPreviousSuffix is appended to the keys of previous versions
+	defaultPreviousSuffix = "_previous"
+	// previousVersionPrefix distinguishes previous versions in the backend cache
+	previousVersionPrefix = "previous-of:"
+
+	// storeRefField indexes ExternalSecrets by the name of the SecretStore they reference
+	storeRefField = "spec.storeRef.name"
 )
 
 // ExternalSecretReconciler reconciles a ExternalSecret object
@@ -47,12 +67,34 @@ type ExternalSecretReconciler struct {
 	client.Client
 	Log    logr.Logger
 	Scheme *runtime.Scheme
+	// Cache is shared with the SecretStoreReconciler, nil disables caching
+	Cache *backend.Cache
+	// Backends holds the backend instances initialized by Stores
+	Backends *backend.Registry
+	// Stores initializes the backend of SecretStores not reconciled yet
+	Stores *store.Manager
+	// Events optionally enqueues ExternalSecrets outside of the refreshInterval
+	Events <-chan event.GenericEvent
+
+	// RetryPeriod is the delay before retrying a failed reconcile, 30s when zero
+	RetryPeriod time.Duration
+	// RefreshInterval applies to ExternalSecrets without refreshInterval, 1h when zero
+	RefreshInterval time.Duration
+	// MaxConcurrentReconciles is the number of ExternalSecrets reconciled in parallel
+	MaxConcurrentReconciles int
+	// Selector restricts the reconciled ExternalSecrets to those matching its
+	// labels, nil reconciles every ExternalSecret
+	Selector labels.Selector
+	// ControllerClass restricts the reconciled ExternalSecrets to those whose
+	// SecretStore controller is set to it, empty reconciles every ExternalSecret
+	ControllerClass string
 }
 
 // +kubebuilder:rbac:groups=secrets.externalsecret-operator.container-solutions.com,resources=externalsecrets,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=secrets.externalsecret-operator.container-solutions.com,resources=externalsecrets/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=store.externalsecret-operator.container-solutions.com,resources=secretstores,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=apps,resources=deployments;statefulsets;daemonsets,verbs=get;list;watch;patch
 
 func (r *ExternalSecretReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 	var (
@@ -81,6 +123,39 @@ func (r *ExternalSecretReconciler) Reconcile(req ctrl.Request) (ctrl.Result, err
 		return ctrl.Result{}, err
 	}
 
+	if !r.selects(externalSecret) {
+		log.Info("ExternalSecret not selected, ignoring it")
+		return ctrl.Result{}, nil
+	}
+
+	inClass, err := r.inClass(ctx, externalSecret)
+	if err != nil {
+		log.Error(err, "Failed to get SecretStore")
+		return ctrl.Result{RequeueAfter: r.retryPeriod()}, err
+	}
+	if !inClass {
+		log.Info("SecretStore of another controller class, ignoring ExternalSecret")
+		return ctrl.Result{}, nil
+	}
+
+	if !externalSecret.ObjectMeta.DeletionTimestamp.IsZero() {
+		err = r.finalize(ctx, externalSecret)
+		if err != nil {
+			log.Error(err, "Failed to clean up target Secret")
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, nil
+	}
+
+	if !controllerutil.ContainsFinalizer(externalSecret, targetFinalizer) {
+		controllerutil.AddFinalizer(externalSecret, targetFinalizer)
+		err = r.Update(ctx, externalSecret)
+		if err != nil {
+			log.Error(err, "Failed to add finalizer")
+			return ctrl.Result{}, err
+		}
+	}
+
 	refreshInterval, err = r.parseRefreshInterval(externalSecret.Spec.RefreshInterval)
 	if err != nil {
 		log.Error(err, "Unable to parse refreshInterval")
@@ -92,33 +167,85 @@ func (r *ExternalSecretReconciler) Reconcile(req ctrl.Request) (ctrl.Result, err
 	// Fetch referenced store
 	secretStore := &storev1alpha1.SecretStore{}
 	err = r.Get(ctx, types.NamespacedName{Name: secretStoreRef.Name, Namespace: externalSecret.Namespace}, secretStore)
+	if errors.IsNotFound(err) || (err == nil && !secretStore.DeletionTimestamp.IsZero()) {
+		log.Info("SecretStore not found", "secretstore", secretStoreRef.Name)
+		err = r.setReadyCondition(ctx, externalSecret, metav1.ConditionFalse, secretsv1alpha1.ReasonStoreNotFound,
+			fmt.Sprintf("SecretStore %s not found", secretStoreRef.Name))
+		if err != nil {
+			log.Error(err, "Failed to update ExternalSecret status")
+		}
+		return ctrl.Result{RequeueAfter: r.retryPeriod()}, nil
+	}
 	if err != nil {
 		// Error reading the object - requeue the request.
 		log.Error(err, "Failed to get SecretStore")
-		return ctrl.Result{RequeueAfter: defaulRetryPeriod}, err
+		return ctrl.Result{RequeueAfter: r.retryPeriod()}, err
 	}
 
-	secretLookupName = externalSecret.Spec.Target.Name
-	if secretLookupName == "" {
-		secretLookupName = externalSecret.Name
+	// The SecretStore may not be reconciled yet, e.g. after a restart
+	err = r.Stores.Init(ctx, secretStore)
+	if err != nil {
+		log.Error(err, "Failed to initialize SecretStore backend")
+		return ctrl.Result{RequeueAfter: r.retryPeriod()}, err
 	}
 
+	err = r.validateVersions(externalSecret, secretStore)
+	if err != nil {
+		log.Info("Unsupported version", "reason", err.Error())
+		err = r.setReadyCondition(ctx, externalSecret, metav1.ConditionFalse, secretsv1alpha1.ReasonInvalidVersion, err.Error())
+		if err != nil {
+			log.Error(err, "Failed to update ExternalSecret status")
+			return ctrl.Result{}, err
+		}
+		// The ExternalSecret is reconciled again when its versions are fixed
+		return ctrl.Result{}, nil
+	}
+
+	err = policy.Check(secretStore, externalSecret)
+	if err != nil {
+		log.Info("Access denied", "reason", err.Error())
+		err = r.setReadyCondition(ctx, externalSecret, metav1.ConditionFalse, secretsv1alpha1.ReasonAccessDenied, err.Error())
+		if err != nil {
+			log.Error(err, "Failed to update ExternalSecret status")
+			return ctrl.Result{}, err
+		}
+		// The ExternalSecret is reconciled again when it or the policy of its SecretStore change
+		return ctrl.Result{}, nil
+	}
+
+	secretLookupName = targetName(externalSecret)
+
 	// Check if this Secret already exists
 	foundSecret := &corev1.Secret{}
 	err = r.Get(ctx, types.NamespacedName{Name: secretLookupName, Namespace: externalSecret.Namespace}, foundSecret)
 	if err != nil {
 		if errors.IsNotFound(err) {
 			// Define a new Secret object
-			secret, err := r.newSecretForCR(externalSecret, secretStore)
+			secret, sources, err := r.newSecretForCR(ctx, externalSecret, secretStore)
 			if err != nil {
+				if keyErr, ok := err.(*keyError); ok {
+					return r.reportKeyError(ctx, externalSecret, keyErr)
+				}
 				log.Error(err, "Failed to create Secret")
-				return ctrl.Result{RequeueAfter: defaulRetryPeriod}, err
+				return ctrl.Result{RequeueAfter: r.retryPeriod()}, err
 			}
 
 			log.Info("Creating a new Secret", "Secret.Namespace", secret.Namespace, "Secret.Name", secret.Name)
 			err = r.Create(ctx, secret)
 			if err != nil {
-				log.Error(err, "Failed to create Secret", "secret", secret)
+				log.Error(err, "Failed to create Secret", "Secret.Namespace", secret.Namespace, "Secret.Name", secret.Name)
+				return ctrl.Result{}, err
+			}
+
+			err = r.recordTarget(ctx, externalSecret, secret.Name, secret.Data, sources)
+			if err != nil {
+				log.Error(err, "Failed to record target Secret")
+				return ctrl.Result{}, err
+			}
+
+			err = r.setReadyCondition(ctx, externalSecret, metav1.ConditionTrue, secretsv1alpha1.ReasonSynced, "")
+			if err != nil {
+				log.Error(err, "Failed to update ExternalSecret status")
 				return ctrl.Result{}, err
 			}
 
@@ -131,36 +258,91 @@ func (r *ExternalSecretReconciler) Reconcile(req ctrl.Request) (ctrl.Result, err
 	}
 
 	// update Secret if it already exists
-	secretMap, err := r.backendGet(externalSecret, secretStore)
+	secretMap, sources, err := r.backendGet(ctx, externalSecret, secretStore)
 	if err != nil {
+		if keyErr, ok := err.(*keyError); ok {
+			return r.reportKeyError(ctx, externalSecret, keyErr)
+		}
 		log.Error(err, "backendGet")
 		return ctrl.Result{}, err
 	}
 
 	updateLabels := makeLabels(secretStore.Spec.Controller, externalSecret.Spec.StoreRef.Name)
 
+	// The type of a Secret is immutable, recreate it when the target type changed
+	if !sameSecretType(foundSecret.Type, secretType(externalSecret)) {
+		if !metav1.IsControlledBy(foundSecret, externalSecret) {
+			err = fmt.Errorf("Secret %s has type %s, not %s, and is not owned by the ExternalSecret", foundSecret.Name, foundSecret.Type, secretType(externalSecret))
+			log.Error(err, "Cannot change Secret type")
+			return ctrl.Result{RequeueAfter: r.retryPeriod()}, err
+		}
+
+		log.Info("Recreating Secret with the new type", "Secret.Name", foundSecret.Name, "type", secretType(externalSecret))
+		err = r.Delete(ctx, foundSecret)
+		if err != nil && !errors.IsNotFound(err) {
+			log.Error(err, "Failed to delete secret")
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{Requeue: true}, nil
+	}
+
+	secretData := secretMap
+	if externalSecret.Spec.Target.DeletionPolicy == secretsv1alpha1.DeletionPolicyMerge {
+		secretData = mergeData(foundSecret.Data, secretMap, previousKeys(externalSecret, foundSecret.Name))
+	}
+
+	err = validateSecretData(foundSecret.Type, secretData)
+	if err != nil {
+		log.Error(err, "Invalid secret data")
+		return ctrl.Result{RequeueAfter: r.retryPeriod()}, err
+	}
+
 	foundSecret.ObjectMeta.Labels = updateLabels
-	foundSecret.Data = secretMap
+	foundSecret.Data = secretData
+	err = setSourcesAnnotation(foundSecret, sources)
+	if err != nil {
+		log.Error(err, "Failed to record sources")
+		return ctrl.Result{}, err
+	}
 	err = r.Update(ctx, foundSecret)
 	if err != nil {
 		log.Error(err, "Failed to update secret")
 		return ctrl.Result{}, err
 	}
 
+	err = r.recordTarget(ctx, externalSecret, foundSecret.Name, secretMap, sources)
+	if err != nil {
+		log.Error(err, "Failed to record target Secret")
+		return ctrl.Result{}, err
+	}
+
+	err = r.rollout(ctx, externalSecret, foundSecret.Name, secretData)
+	if err != nil {
+		log.Error(err, "Failed to roll out workloads")
+		return ctrl.Result{}, err
+	}
+
+	err = r.setReadyCondition(ctx, externalSecret, metav1.ConditionTrue, secretsv1alpha1.ReasonSynced, "")
+	if err != nil {
+		log.Error(err, "Failed to update ExternalSecret status")
+		return ctrl.Result{}, err
+	}
+
 	return ctrl.Result{RequeueAfter: refreshInterval}, nil
 }
 
-func (r *ExternalSecretReconciler) newSecretForCR(s *secretsv1alpha1.ExternalSecret, st *storev1alpha1.SecretStore) (*corev1.Secret, error) {
-	var secretObjName string
-	secretObjName = s.Spec.Target.Name
-	if secretObjName == "" {
-		secretObjName = s.Name
+func (r *ExternalSecretReconciler) newSecretForCR(ctx context.Context, s *secretsv1alpha1.ExternalSecret, st *storev1alpha1.SecretStore) (*corev1.Secret, []secretsv1alpha1.ExternalSecretSource, error) {
+	secretObjName := targetName(s)
+
+	secretMap, sources, err := r.backendGet(ctx, s, st)
+	if err != nil {
+		r.Log.Error(err, "backendGet")
+		return nil, nil, err
 	}
 
-	secretMap, err := r.backendGet(s, st)
+	err = validateSecretData(secretType(s), secretMap)
 	if err != nil {
-		log.Error(err, "backendGet")
-		return nil, err
+		return nil, nil, err
 	}
 
 	secretLabels := makeLabels(st.Spec.Controller, s.Spec.StoreRef.Name)
@@ -175,41 +357,229 @@ func (r *ExternalSecretReconciler) newSecretForCR(s *secretsv1alpha1.ExternalSec
 			Namespace: s.Namespace,
 			Labels:    secretLabels,
 		},
+		Type: secretType(s),
 		Data: secretMap,
 	}
 
+	err = setSourcesAnnotation(secretObject, sources)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	// Allows deleted objects to be garbage collected.
 	err = ctrl.SetControllerReference(s, secretObject, r.Scheme)
 	if err != nil {
-		log.Error(err, "Error setting owner references", secretObject)
-		return nil, err
+		r.Log.Error(err, "Error setting owner references", "Secret.Namespace", secretObject.Namespace, "Secret.Name", secretObject.Name)
+		return nil, nil, err
+	}
+
+	return secretObject, sources, nil
+}
+
+// getPrevious retrieves the version of secret preceding current. The first
+// version of a secret is its own previous version.
+func (r *ExternalSecretReconciler) getPrevious(ctx context.Context, instance backend.Backend, backendName string, secret secretsv1alpha1.ExternalSecretData, current *backend.Value) (*backend.Value, error) {
+	getter, ok := instance.(backend.PreviousGetter)
+	if !ok {
+		return nil, fmt.Errorf("previous versions are not supported by this backend")
 	}
 
-	return secretObject, nil
+	currentVersion := current.Metadata[backend.MetadataVersion]
+	if currentVersion == "" {
+		currentVersion = secret.Version
+	}
+
+	return r.Cache.Get(backendName, secret.Key, previousVersionPrefix+currentVersion, func() (*backend.Value, error) {
+		getCtx, cancel := r.Backends.Context(ctx, backendName)
+		defer cancel()
+
+		previous, err := getter.GetPrevious(getCtx, secret.Key, current)
+		if err != nil || previous != nil {
+			return previous, err
+		}
+		return current, nil
+	})
 }
 
-func (r *ExternalSecretReconciler) backendGet(s *secretsv1alpha1.ExternalSecret, st *storev1alpha1.SecretStore) (map[string][]byte, error) {
+// previousKey returns the key of the target Secret the previous version of data is written to
+func previousKey(s *secretsv1alpha1.ExternalSecret, data secretsv1alpha1.ExternalSecretData) string {
+	suffix := s.Spec.PreviousVersion.Suffix
+	if suffix == "" {
+		suffix = defaultPreviousSuffix
+	}
+	return secretKey(data) + suffix
+}
+
+// validateVersions checks the backend of st supports the form of the version
+// of each key of s, and previous versions when they are requested
+func (r *ExternalSecretReconciler) validateVersions(s *secretsv1alpha1.ExternalSecret, st *storev1alpha1.SecretStore) error {
+	instance, ok := r.Backends.Get(store.BackendName(st))
+	if !ok {
+		// Reported by backendGet
+		return nil
+	}
+
+	if _, ok := instance.(backend.PreviousGetter); s.Spec.PreviousVersion != nil && !ok {
+		return fmt.Errorf("previous versions are not supported by this backend")
+	}
+
+	for _, secret := range s.Spec.Data {
+		if err := backend.SupportsVersion(instance, secret.Version); err != nil {
+			return fmt.Errorf("key %s: %w", secret.Key, err)
+		}
+	}
+	return nil
+}
+
+// backendGet retrieves the data of s from the backend of st, along with the
+// sources of each key
+func (r *ExternalSecretReconciler) backendGet(ctx context.Context, s *secretsv1alpha1.ExternalSecret, st *storev1alpha1.SecretStore) (map[string][]byte, []secretsv1alpha1.ExternalSecretSource, error) {
 	secrets := s.Spec.Data
 	secretMap := make(map[string][]byte)
+	sources := make([]secretsv1alpha1.ExternalSecretSource, 0, len(secrets))
 
-	stCtrl := st.Spec.Controller
-	backend, ok := backend.Instances[stCtrl]
+	backendName := store.BackendName(st)
+	instance, ok := r.Backends.Get(backendName)
 	if !ok {
-		log.Error("Cannot find controller:", stCtrl)
-		return secretMap, fmt.Errorf("Cannot find backend: %v", stCtrl)
+		r.Log.Error(fmt.Errorf("backend not found"), "Cannot find backend", "backend", backendName)
+		return secretMap, nil, fmt.Errorf("Cannot find backend: %v", backendName)
+	}
+
+	storeConfig, err := config.ConfigFromCtrl(st.Spec.Store.Raw)
+	if err != nil {
+		return secretMap, nil, err
+	}
+
+	if len(secrets) == 0 && len(s.Spec.DataFrom) == 0 {
+		return secretMap, nil, fmt.Errorf("at least one of data or dataFrom is required")
 	}
 
+	owners := newKeyOwners()
 	for _, secret := range secrets {
-		retrievedValue, err := backend.Get(secret.Key, secret.Version)
+		key, err := dataKey(s, secret)
+		if err != nil {
+			return secretMap, nil, err
+		}
+		secret.SecretKey = key
+		owners.claim(key, secret.Key)
+
+		retrievedValue, err := r.Cache.Get(backendName, secret.Key, secret.Version, func() (*backend.Value, error) {
+			getCtx, cancel := r.Backends.Context(ctx, backendName)
+			defer cancel()
+			return instance.Get(getCtx, secret.Key, secret.Version)
+		})
 		if err != nil {
-			log.Error(err, "could not create secret due to error from backend")
-			return secretMap, fmt.Errorf("could not create secret due to error from backend: %v", err)
+			r.Log.Error(err, "could not create secret due to error from backend", "key", secret.Key)
+			return secretMap, nil, fmt.Errorf("could not create secret due to error from backend: %v", err)
 		}
 
-		secretMap[secret.Key] = []byte(retrievedValue)
+		retrievedValue, err = backend.Decode(retrievedValue, backend.DecodingStrategy(secret.DecodingStrategy))
+		if err != nil {
+			r.Log.Error(err, "could not decode secret", "key", secret.Key, "decodingStrategy", secret.DecodingStrategy)
+			return secretMap, nil, fmt.Errorf("could not decode secret %s: %v", secret.Key, err)
+		}
+		data, err := retrievedValue.Property(secret.Property)
+		if err != nil {
+			r.Log.Error(err, "could not select property of secret", "key", secret.Key, "property", secret.Property)
+			return secretMap, nil, fmt.Errorf("could not select property of secret %s: %v", secret.Key, err)
+		}
+		secretMap[secretKey(secret)] = data
+		sources = append(sources, newSource(storeConfig.Type, st.Name, secret, retrievedValue))
+
+		if s.Spec.PreviousVersion == nil {
+			continue
+		}
+
+		previousValue, err := r.getPrevious(ctx, instance, backendName, secret, retrievedValue)
+		if err != nil {
+			r.Log.Error(err, "could not retrieve previous version from backend", "key", secret.Key)
+			return secretMap, nil, fmt.Errorf("could not retrieve previous version from backend: %v", err)
+		}
+
+		previousSource := newSource(storeConfig.Type, st.Name, secret, previousValue)
+		previousSource.SecretKey = previousKey(s, secret)
+		owners.claim(previousSource.SecretKey, secret.Key+" previous version")
+		previousValue, err = backend.Decode(previousValue, backend.DecodingStrategy(secret.DecodingStrategy))
+		if err != nil {
+			r.Log.Error(err, "could not decode previous version", "key", secret.Key, "decodingStrategy", secret.DecodingStrategy)
+			return secretMap, nil, fmt.Errorf("could not decode previous version of %s: %v", secret.Key, err)
+		}
+		previousData, err := previousValue.Property(secret.Property)
+		if err != nil {
+			r.Log.Error(err, "could not select property of previous version", "key", secret.Key, "property", secret.Property)
+			return secretMap, nil, fmt.Errorf("could not select property of previous version of %s: %v", secret.Key, err)
+		}
+		secretMap[previousSource.SecretKey] = previousData
+		sources = append(sources, previousSource)
+	}
+
+	listed, err := r.backendList(ctx, s, st, instance, backendName)
+	if err != nil {
+		r.Log.Error(err, "could not list secrets from backend")
+		return secretMap, nil, fmt.Errorf("could not list secrets from backend: %v", err)
+	}
+	for remoteKey, value := range listed {
+		key, err := rewriteKey(s.Spec.Rewrite, remoteKey)
+		if err != nil {
+			return secretMap, nil, err
+		}
+		// Listed keys written to a key of data are collisions too
+		if !owners.claim(key, remoteKey) {
+			continue
+		}
+		secretMap[key] = value.Data
+		sources = append(sources, newSource(storeConfig.Type, st.Name, secretsv1alpha1.ExternalSecretData{Key: remoteKey, SecretKey: key}, value))
+	}
+	if err := owners.err(); err != nil {
+		return secretMap, nil, err
 	}
+	sortSources(sources)
 
-	return secretMap, nil
+	return secretMap, sources, nil
+}
+
+// backendList returns the secrets listed by instance matching the dataFrom of s
+// and allowed by the policy of st. Listed keys the policy denies are left out,
+// before being decoded, instead of failing the whole listing.
+func (r *ExternalSecretReconciler) backendList(ctx context.Context, s *secretsv1alpha1.ExternalSecret, st *storev1alpha1.SecretStore, instance backend.Backend, backendName string) (map[string]*backend.Value, error) {
+	values := map[string]*backend.Value{}
+	if len(s.Spec.DataFrom) == 0 {
+		return values, nil
+	}
+
+	lister, ok := instance.(backend.Lister)
+	if !ok {
+		return nil, fmt.Errorf("backend %s cannot list secrets for dataFrom", backendName)
+	}
+
+	listCtx, cancel := r.Backends.Context(ctx, backendName)
+	defer cancel()
+	listed, err := lister.List(listCtx)
+	if err != nil {
+		return nil, err
+	}
+
+	for key, value := range listed {
+		allowed, err := policy.Allowed(st.Spec.Policy, key, s.Namespace, s.Labels)
+		if err != nil {
+			return nil, err
+		}
+		if !allowed {
+			continue
+		}
+		for _, from := range s.Spec.DataFrom {
+			if !strings.HasPrefix(key, from.Prefix) {
+				continue
+			}
+			decoded, err := backend.Decode(value, backend.DecodingStrategy(from.DecodingStrategy))
+			if err != nil {
+				return nil, fmt.Errorf("could not decode secret %s: %v", key, err)
+			}
+			values[key] = decoded
+			break
+		}
+	}
+	return values, nil
 }
 
 func (r *ExternalSecretReconciler) parseRefreshInterval(refreshIntervalString string) (time.Duration, error) {
@@ -217,11 +587,14 @@ func (r *ExternalSecretReconciler) parseRefreshInterval(refreshIntervalString st
 	var err error
 
 	if refreshIntervalString == "" {
-		refreshIntervalValue = defaultRefreshInterval
+		refreshIntervalValue = r.RefreshInterval
+		if refreshIntervalValue == 0 {
+			refreshIntervalValue = defaultRefreshInterval
+		}
 	} else {
 		refreshIntervalValue, err = time.ParseDuration(refreshIntervalString)
 		if err != nil {
-			log.Error(err, "Unable to parse refreshInterval")
+			r.Log.Error(err, "Unable to parse refreshInterval")
 			return 0, err
 		}
 	}
@@ -229,6 +602,21 @@ func (r *ExternalSecretReconciler) parseRefreshInterval(refreshIntervalString st
 	return refreshIntervalValue, nil
 }
 
+func (r *ExternalSecretReconciler) retryPeriod() time.Duration {
+	if r.RetryPeriod == 0 {
+		return defaulRetryPeriod
+	}
+	return r.RetryPeriod
+}
+
+// targetName returns the name of the Secret written by the ExternalSecret
+func targetName(s *secretsv1alpha1.ExternalSecret) string {
+	if s.Spec.Target.Name != "" {
+		return s.Spec.Target.Name
+	}
+	return s.Name
+}
+
 func makeLabels(contrl string, storeRef string) map[string]string {
 	return map[string]string{
 		"secret-controller": contrl,
@@ -236,9 +624,88 @@ func makeLabels(contrl string, storeRef string) map[string]string {
 	}
 }
 
+// selects tells whether the labels of o match the Selector of r
+func (r *ExternalSecretReconciler) selects(o metav1.Object) bool {
+	return r.Selector == nil || r.Selector.Matches(labels.Set(o.GetLabels()))
+}
+
+// inClass tells whether the SecretStore of s belongs to the ControllerClass of
+// r. ExternalSecrets whose SecretStore is not found are reported by instances
+// without class, and cleaned up by every instance once deleted.
+func (r *ExternalSecretReconciler) inClass(ctx context.Context, s *secretsv1alpha1.ExternalSecret) (bool, error) {
+	if r.ControllerClass == "" {
+		return true, nil
+	}
+
+	secretStore := &storev1alpha1.SecretStore{}
+	err := r.Get(ctx, types.NamespacedName{Name: s.Spec.StoreRef.Name, Namespace: s.Namespace}, secretStore)
+	if errors.IsNotFound(err) {
+		return !s.DeletionTimestamp.IsZero(), nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return store.Selected(secretStore, nil, r.ControllerClass), nil
+}
+
+// externalSecretsForStore enqueues the ExternalSecrets referencing a SecretStore
+func (r *ExternalSecretReconciler) externalSecretsForStore(o handler.MapObject) []reconcile.Request {
+	externalSecrets := &secretsv1alpha1.ExternalSecretList{}
+	err := r.List(context.Background(), externalSecrets,
+		client.InNamespace(o.Meta.GetNamespace()),
+		client.MatchingFields{storeRefField: o.Meta.GetName()})
+	if err != nil {
+		r.Log.Error(err, "Failed to list ExternalSecrets", "secretstore", o.Meta.GetName())
+		return nil
+	}
+
+	requests := []reconcile.Request{}
+	for _, externalSecret := range externalSecrets.Items {
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{Name: externalSecret.Name, Namespace: externalSecret.Namespace},
+		})
+	}
+	return requests
+}
+
 func (r *ExternalSecretReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
-		For(&secretsv1alpha1.ExternalSecret{}).
+	err := mgr.GetFieldIndexer().IndexField(context.Background(), &secretsv1alpha1.ExternalSecret{}, storeRefField, func(o runtime.Object) []string {
+		return []string{o.(*secretsv1alpha1.ExternalSecret).Spec.StoreRef.Name}
+	})
+	if err != nil {
+		return err
+	}
+
+	err = indexRolloutSecrets(mgr.GetFieldIndexer())
+	if err != nil {
+		return err
+	}
+
+	blder := ctrl.NewControllerManagedBy(mgr).
+		For(&secretsv1alpha1.ExternalSecret{}, builder.WithPredicates(predicate.NewPredicateFuncs(func(meta metav1.Object, o runtime.Object) bool {
+			if !r.selects(meta) {
+				return false
+			}
+			externalSecret, ok := o.(*secretsv1alpha1.ExternalSecret)
+			if !ok {
+				return false
+			}
+			inClass, err := r.inClass(context.Background(), externalSecret)
+			if err != nil {
+				// Checked again on reconcile
+				return true
+			}
+			return inClass
+		}))).
 		Owns(&corev1.Secret{}).
-		Complete(r)
+		WithOptions(controller.Options{MaxConcurrentReconciles: r.MaxConcurrentReconciles}).
+		Watches(&source.Kind{Type: &storev1alpha1.SecretStore{}},
+			&handler.EnqueueRequestsFromMapFunc{ToRequests: handler.ToRequestsFunc(r.externalSecretsForStore)},
+			builder.WithPredicates(predicate.GenerationChangedPredicate{}))
+
+	if r.Events != nil {
+		blder = blder.Watches(&source.Channel{Source: r.Events}, &handler.EnqueueRequestForObject{})
+	}
+
+	return blder.Complete(r)
 }
@@ -2,13 +2,18 @@ package controllers
 
 import (
 	"context"
+	"encoding/json"
 	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 
@@ -191,6 +196,26 @@ var _ = Describe("ExternalsecretController", func() {
 			secretValue3 := string(secret.Data[ExternalSecret3Key])
 			Expect(secretValue3).Should(Equal("test-key-3test-version-3TestParameter"))
 
+			By("Recording the source of each key without its value")
+			Expect(secret.Annotations[SourcesAnnotation]).ShouldNot(ContainSubstring("TestParameter"))
+			Eventually(func() []secretsv1alpha1.ExternalSecretSource {
+				es := &secretsv1alpha1.ExternalSecret{}
+				if err := k8sClient.Get(ctx, externalSecretLookupKey, es); err != nil {
+					return nil
+				}
+				return es.Status.Sources
+			}, timeout, interval).Should(ContainElement(secretsv1alpha1.ExternalSecretSource{
+				SecretKey: ExternalSecretKey,
+				Backend:   "dummy",
+				Store:     SecretStoreName,
+				RemoteKey: ExternalSecretKey,
+				Version:   ExternalSecretVersion,
+			}))
+
+			var sources []secretsv1alpha1.ExternalSecretSource
+			Expect(json.Unmarshal([]byte(secret.Annotations[SourcesAnnotation]), &sources)).Should(Succeed())
+			Expect(sources).Should(HaveLen(3))
+
 			By("Updating the Secret if it already exists")
 			updatedSecrets := []secretsv1alpha1.ExternalSecretData{
 				{
@@ -309,6 +334,115 @@ var _ = Describe("ExternalsecretController", func() {
 		})
 	})
 
+	Context("When previous versions are requested", func() {
+		It("Should write the previous version of each key under a suffixed key", func() {
+			ctx := context.Background()
+
+			randomObjSafeStr, err := utils.RandomStringObjectSafe(32)
+			Expect(err).To(BeNil())
+
+			secretStore := &storev1alpha1.SecretStore{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      SecretStoreName + randomObjSafeStr,
+					Namespace: ExternalSecretNamespace,
+				},
+				Spec: storev1alpha1.SecretStoreSpec{
+					Controller: StoreControllerName + randomObjSafeStr,
+					Store: runtime.RawExtension{
+						Raw: []byte(StoreConfig),
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, secretStore)).Should(Succeed())
+
+			externalSecret := &secretsv1alpha1.ExternalSecret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      ExternalSecretName + randomObjSafeStr,
+					Namespace: ExternalSecretNamespace,
+				},
+				Spec: secretsv1alpha1.ExternalSecretSpec{
+					StoreRef: secretsv1alpha1.ExternalSecretStoreRef{
+						Name: secretStore.ObjectMeta.Name,
+					},
+					PreviousVersion: &secretsv1alpha1.ExternalSecretPreviousVersion{
+						Suffix: ".old",
+					},
+					Data: []secretsv1alpha1.ExternalSecretData{
+						{
+							Key: ExternalSecretKey,
+						},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, externalSecret)).Should(Succeed())
+
+			secretLookupKey := types.NamespacedName{Name: externalSecret.Name, Namespace: ExternalSecretNamespace}
+			secret := &corev1.Secret{}
+			Eventually(func() error {
+				return k8sClient.Get(ctx, secretLookupKey, secret)
+			}, timeout, interval).Should(Succeed())
+
+			Expect(string(secret.Data[ExternalSecretKey])).Should(Equal("test-keyTestParameter"))
+			Expect(string(secret.Data[ExternalSecretKey+".old"])).Should(Equal("test-keypreviousTestParameter"))
+		})
+	})
+
+	Context("When secrets listed by the backend are requested", func() {
+		It("Should write the listed secrets matching dataFrom next to data", func() {
+			ctx := context.Background()
+
+			randomObjSafeStr, err := utils.RandomStringObjectSafe(32)
+			Expect(err).To(BeNil())
+
+			secretStore := &storev1alpha1.SecretStore{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      SecretStoreName + randomObjSafeStr,
+					Namespace: ExternalSecretNamespace,
+				},
+				Spec: storev1alpha1.SecretStoreSpec{
+					Controller: StoreControllerName + randomObjSafeStr,
+					Store: runtime.RawExtension{
+						Raw: []byte(StoreConfig),
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, secretStore)).Should(Succeed())
+
+			externalSecret := &secretsv1alpha1.ExternalSecret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      ExternalSecretName + randomObjSafeStr,
+					Namespace: ExternalSecretNamespace,
+				},
+				Spec: secretsv1alpha1.ExternalSecretSpec{
+					StoreRef: secretsv1alpha1.ExternalSecretStoreRef{
+						Name: secretStore.ObjectMeta.Name,
+					},
+					Data: []secretsv1alpha1.ExternalSecretData{
+						{
+							Key: ExternalSecretKey,
+						},
+					},
+					DataFrom: []secretsv1alpha1.ExternalSecretDataFrom{
+						{
+							Prefix: "listed-",
+						},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, externalSecret)).Should(Succeed())
+
+			secretLookupKey := types.NamespacedName{Name: externalSecret.Name, Namespace: ExternalSecretNamespace}
+			secret := &corev1.Secret{}
+			Eventually(func() error {
+				return k8sClient.Get(ctx, secretLookupKey, secret)
+			}, timeout, interval).Should(Succeed())
+
+			Expect(secret.Data).Should(HaveLen(2))
+			Expect(string(secret.Data[ExternalSecretKey])).Should(Equal("test-keyTestParameter"))
+			Expect(string(secret.Data["listed-key"])).Should(Equal("listed-keyTestParameter"))
+		})
+	})
+
 	Context("SecretStore does not exist", func() {
 		ctx := context.Background()
 		It("Should return an error", func() {
@@ -351,11 +485,23 @@ var _ = Describe("ExternalsecretController", func() {
 				return k8sClient.Get(ctx, secretLookupKey, secret)
 			}).ShouldNot(Succeed())
 
+			Eventually(func() string {
+				es := &secretsv1alpha1.ExternalSecret{}
+				k8sClient.Get(ctx, secretLookupKey, es)
+				condition := meta.FindStatusCondition(es.Status.Conditions, secretsv1alpha1.ExternalSecretReady)
+				if condition == nil {
+					return ""
+				}
+				return condition.Reason
+			}, timeout, interval).Should(Equal(secretsv1alpha1.ReasonStoreNotFound))
 		})
 	})
 
 	Context("When interacting with Backend", func() {
 		r := &ExternalSecretReconciler{}
+		BeforeEach(func() {
+			r.Backends = backends
+		})
 		ctx := context.Background()
 
 		It("Should Fail when a backend is uninitialized/Not ready", func() {
@@ -407,7 +553,7 @@ var _ = Describe("ExternalsecretController", func() {
 					},
 				},
 			}
-			_, err = r.backendGet(externalSecret, secretStore)
+			_, _, err = r.backendGet(ctx, externalSecret, secretStore)
 			Expect(err).ToNot(BeNil())
 			Expect(err.Error()).Should(Equal("Cannot find backend:" + " " + randomControllerName))
 
@@ -486,7 +632,7 @@ var _ = Describe("ExternalsecretController", func() {
 				We need to wait for the store reconciler to intialize the backend
 			**/
 			Eventually(func() string {
-				_, err := r.backendGet(externalSecret, secretStore)
+				_, _, err := r.backendGet(ctx, externalSecret, secretStore)
 				return err.Error()
 			}, timeout, interval).Should(Equal("could not create secret due to error from backend: Mocked error"))
 
@@ -583,4 +729,764 @@ var _ = Describe("ExternalsecretController", func() {
 		})
 	})
 
+	Context("When the Secret data changes", func() {
+		It("Should roll out the workloads consuming it", func() {
+			ctx := context.Background()
+
+			randomObjSafeStr, err := utils.RandomStringObjectSafe(16)
+			Expect(err).To(BeNil())
+
+			secretStore := &storev1alpha1.SecretStore{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      SecretStoreName + randomObjSafeStr,
+					Namespace: ExternalSecretNamespace,
+				},
+				Spec: storev1alpha1.SecretStoreSpec{
+					Controller: StoreControllerName + randomObjSafeStr,
+					Store: runtime.RawExtension{
+						Raw: []byte(StoreConfig),
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, secretStore)).Should(Succeed())
+
+			externalSecretName := ExternalSecretName + randomObjSafeStr
+			labels := map[string]string{"app": externalSecretName}
+			newDeployment := func(name string, annotations map[string]string) *appsv1.Deployment {
+				return &appsv1.Deployment{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:        name,
+						Namespace:   ExternalSecretNamespace,
+						Annotations: annotations,
+					},
+					Spec: appsv1.DeploymentSpec{
+						Selector: &metav1.LabelSelector{MatchLabels: labels},
+						Template: corev1.PodTemplateSpec{
+							ObjectMeta: metav1.ObjectMeta{Labels: labels},
+							Spec: corev1.PodSpec{
+								Containers: []corev1.Container{{Name: "app", Image: "app"}},
+							},
+						},
+					},
+				}
+			}
+
+			annotated := newDeployment(externalSecretName+"-annotated", map[string]string{RolloutAnnotation: "other," + externalSecretName})
+			targeted := newDeployment(externalSecretName+"-targeted", nil)
+			untouched := newDeployment(externalSecretName+"-untouched", nil)
+			for _, deployment := range []*appsv1.Deployment{annotated, targeted, untouched} {
+				Expect(k8sClient.Create(ctx, deployment)).Should(Succeed())
+			}
+
+			externalSecret := &secretsv1alpha1.ExternalSecret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      externalSecretName,
+					Namespace: ExternalSecretNamespace,
+				},
+				Spec: secretsv1alpha1.ExternalSecretSpec{
+					StoreRef: secretsv1alpha1.ExternalSecretStoreRef{
+						Name: secretStore.Name,
+					},
+					Data: []secretsv1alpha1.ExternalSecretData{
+						{
+							Key:     ExternalSecretKey,
+							Version: ExternalSecretVersion,
+						},
+					},
+					RolloutTargets: []secretsv1alpha1.ExternalSecretRolloutTarget{
+						{
+							Kind: "Deployment",
+							Name: targeted.Name,
+						},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, externalSecret)).Should(Succeed())
+
+			secretLookupKey := types.NamespacedName{Name: externalSecretName, Namespace: ExternalSecretNamespace}
+			Eventually(func() error {
+				return k8sClient.Get(ctx, secretLookupKey, &corev1.Secret{})
+			}, timeout, interval).Should(Succeed())
+
+			By("Updating the ExternalSecret data")
+			Eventually(func() error {
+				es := &secretsv1alpha1.ExternalSecret{}
+				if err := k8sClient.Get(ctx, secretLookupKey, es); err != nil {
+					return err
+				}
+				es.Spec.Data[0].Version = ExternalSecretVersionUpdate
+				return k8sClient.Update(ctx, es)
+			}, timeout, interval).Should(Succeed())
+
+			hashOf := func(name string) string {
+				deployment := &appsv1.Deployment{}
+				err := k8sClient.Get(ctx, types.NamespacedName{Name: name, Namespace: ExternalSecretNamespace}, deployment)
+				if err != nil {
+					return ""
+				}
+				return deployment.Spec.Template.Annotations[secretHashAnnotation(externalSecretName)]
+			}
+
+			Eventually(func() string {
+				return hashOf(annotated.Name)
+			}, timeout, interval).Should(Equal(dataHash(map[string][]byte{
+				ExternalSecretKey: []byte(ExternalSecretKey + ExternalSecretVersionUpdate + "TestParameter"),
+			})))
+			Eventually(func() string {
+				return hashOf(targeted.Name)
+			}, timeout, interval).ShouldNot(BeEmpty())
+			Consistently(func() string {
+				return hashOf(untouched.Name)
+			}, duration, interval).Should(BeEmpty())
+			Eventually(func() string {
+				es := &secretsv1alpha1.ExternalSecret{}
+				if err := k8sClient.Get(ctx, secretLookupKey, es); err != nil {
+					return ""
+				}
+				return es.Status.RolloutHash
+			}, timeout, interval).Should(Equal(hashOf(annotated.Name)))
+
+			By("Adding a rollout target while the data is unchanged")
+			late := newDeployment(externalSecretName+"-late", nil)
+			Expect(k8sClient.Create(ctx, late)).Should(Succeed())
+			Eventually(func() error {
+				es := &secretsv1alpha1.ExternalSecret{}
+				if err := k8sClient.Get(ctx, secretLookupKey, es); err != nil {
+					return err
+				}
+				es.Spec.RolloutTargets = append(es.Spec.RolloutTargets, secretsv1alpha1.ExternalSecretRolloutTarget{Kind: "Deployment", Name: late.Name})
+				return k8sClient.Update(ctx, es)
+			}, timeout, interval).Should(Succeed())
+			Consistently(func() string {
+				return hashOf(late.Name)
+			}, duration, interval).Should(BeEmpty())
+
+			By("Failing a reconcile after the Secret update, before the rollout")
+			Eventually(func() error {
+				es := &secretsv1alpha1.ExternalSecret{}
+				if err := k8sClient.Get(ctx, secretLookupKey, es); err != nil {
+					return err
+				}
+				es.Status.RolloutHash = ""
+				return k8sClient.Status().Update(ctx, es)
+			}, timeout, interval).Should(Succeed())
+			Eventually(func() string {
+				return hashOf(late.Name)
+			}, timeout, interval).Should(Equal(hashOf(annotated.Name)))
+			Consistently(func() string {
+				return hashOf(untouched.Name)
+			}, duration, interval).Should(BeEmpty())
+		})
+	})
+
+	Context("When cleaning up the target Secret", func() {
+		ctx := context.Background()
+
+		newExternalSecret := func(policy secretsv1alpha1.ExternalSecretDeletionPolicy) *secretsv1alpha1.ExternalSecret {
+			randomObjSafeStr, err := utils.RandomStringObjectSafe(16)
+			Expect(err).To(BeNil())
+
+			secretStore := &storev1alpha1.SecretStore{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      SecretStoreName + randomObjSafeStr,
+					Namespace: ExternalSecretNamespace,
+				},
+				Spec: storev1alpha1.SecretStoreSpec{
+					Controller: StoreControllerName + randomObjSafeStr,
+					Store: runtime.RawExtension{
+						Raw: []byte(StoreConfig),
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, secretStore)).Should(Succeed())
+
+			externalSecret := &secretsv1alpha1.ExternalSecret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      ExternalSecretName + randomObjSafeStr,
+					Namespace: ExternalSecretNamespace,
+				},
+				Spec: secretsv1alpha1.ExternalSecretSpec{
+					StoreRef: secretsv1alpha1.ExternalSecretStoreRef{
+						Name: secretStore.Name,
+					},
+					Target: secretsv1alpha1.ExternalSecretTarget{
+						DeletionPolicy: policy,
+					},
+					Data: []secretsv1alpha1.ExternalSecretData{
+						{
+							Key:     ExternalSecretKey,
+							Version: ExternalSecretVersion,
+						},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, externalSecret)).Should(Succeed())
+
+			Eventually(func() error {
+				return k8sClient.Get(ctx, types.NamespacedName{Name: externalSecret.Name, Namespace: ExternalSecretNamespace}, &corev1.Secret{})
+			}, timeout, interval).Should(Succeed())
+
+			return externalSecret
+		}
+
+		It("Should delete the previous target when the target name changes", func() {
+			externalSecret := newExternalSecret("")
+			externalSecretLookupKey := types.NamespacedName{Name: externalSecret.Name, Namespace: ExternalSecretNamespace}
+			newTargetName := externalSecret.Name + "-renamed"
+
+			Eventually(func() error {
+				es := &secretsv1alpha1.ExternalSecret{}
+				if err := k8sClient.Get(ctx, externalSecretLookupKey, es); err != nil {
+					return err
+				}
+				es.Spec.Target.Name = newTargetName
+				return k8sClient.Update(ctx, es)
+			}, timeout, interval).Should(Succeed())
+
+			Eventually(func() error {
+				return k8sClient.Get(ctx, types.NamespacedName{Name: newTargetName, Namespace: ExternalSecretNamespace}, &corev1.Secret{})
+			}, timeout, interval).Should(Succeed())
+
+			Eventually(func() bool {
+				err := k8sClient.Get(ctx, externalSecretLookupKey, &corev1.Secret{})
+				return errors.IsNotFound(err)
+			}, timeout, interval).Should(BeTrue())
+
+			Eventually(func() string {
+				es := &secretsv1alpha1.ExternalSecret{}
+				k8sClient.Get(ctx, externalSecretLookupKey, es)
+				return es.Status.TargetName
+			}, timeout, interval).Should(Equal(newTargetName))
+		})
+
+		It("Should keep the target Secret with a Retain deletionPolicy", func() {
+			externalSecret := newExternalSecret(secretsv1alpha1.DeletionPolicyRetain)
+			lookupKey := types.NamespacedName{Name: externalSecret.Name, Namespace: ExternalSecretNamespace}
+
+			Eventually(func() error {
+				es := &secretsv1alpha1.ExternalSecret{}
+				if err := k8sClient.Get(ctx, lookupKey, es); err != nil {
+					return err
+				}
+				return k8sClient.Delete(ctx, es)
+			}, timeout, interval).Should(Succeed())
+
+			Eventually(func() bool {
+				err := k8sClient.Get(ctx, lookupKey, &secretsv1alpha1.ExternalSecret{})
+				return errors.IsNotFound(err)
+			}, timeout, interval).Should(BeTrue())
+
+			secret := &corev1.Secret{}
+			Expect(k8sClient.Get(ctx, lookupKey, secret)).Should(Succeed())
+			Expect(secret.OwnerReferences).Should(BeEmpty())
+			Expect(string(secret.Data[ExternalSecretKey])).Should(Equal("test-keytest-versionTestParameter"))
+		})
+
+		It("Should only remove its keys with a Merge deletionPolicy", func() {
+			externalSecret := newExternalSecret(secretsv1alpha1.DeletionPolicyMerge)
+			lookupKey := types.NamespacedName{Name: externalSecret.Name, Namespace: ExternalSecretNamespace}
+
+			secret := &corev1.Secret{}
+			Expect(k8sClient.Get(ctx, lookupKey, secret)).Should(Succeed())
+			secret.Data["foreign-key"] = []byte("foreign-value")
+			Expect(k8sClient.Update(ctx, secret)).Should(Succeed())
+
+			Eventually(func() error {
+				es := &secretsv1alpha1.ExternalSecret{}
+				if err := k8sClient.Get(ctx, lookupKey, es); err != nil {
+					return err
+				}
+				return k8sClient.Delete(ctx, es)
+			}, timeout, interval).Should(Succeed())
+
+			Eventually(func() bool {
+				err := k8sClient.Get(ctx, lookupKey, &secretsv1alpha1.ExternalSecret{})
+				return errors.IsNotFound(err)
+			}, timeout, interval).Should(BeTrue())
+
+			Expect(k8sClient.Get(ctx, lookupKey, secret)).Should(Succeed())
+			Expect(secret.Data).Should(Equal(map[string][]byte{"foreign-key": []byte("foreign-value")}))
+		})
+	})
+
+	Context("When a target type is provided", func() {
+		ctx := context.Background()
+
+		It("Should create a Secret of that type with the mapped keys", func() {
+			randomObjSafeStr, err := utils.RandomStringObjectSafe(16)
+			Expect(err).To(BeNil())
+
+			secretStore := &storev1alpha1.SecretStore{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      SecretStoreName + randomObjSafeStr,
+					Namespace: ExternalSecretNamespace,
+				},
+				Spec: storev1alpha1.SecretStoreSpec{
+					Controller: StoreControllerName + randomObjSafeStr,
+					Store: runtime.RawExtension{
+						Raw: []byte(StoreConfig),
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, secretStore)).Should(Succeed())
+
+			externalSecret := &secretsv1alpha1.ExternalSecret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      ExternalSecretName + randomObjSafeStr,
+					Namespace: ExternalSecretNamespace,
+				},
+				Spec: secretsv1alpha1.ExternalSecretSpec{
+					StoreRef: secretsv1alpha1.ExternalSecretStoreRef{
+						Name: secretStore.Name,
+					},
+					Target: secretsv1alpha1.ExternalSecretTarget{
+						Type: corev1.SecretTypeBasicAuth,
+					},
+					Data: []secretsv1alpha1.ExternalSecretData{
+						{
+							Key:       ExternalSecretKey,
+							Version:   ExternalSecretVersion,
+							SecretKey: corev1.BasicAuthUsernameKey,
+						},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, externalSecret)).Should(Succeed())
+
+			lookupKey := types.NamespacedName{Name: externalSecret.Name, Namespace: ExternalSecretNamespace}
+			secret := &corev1.Secret{}
+			Eventually(func() error {
+				return k8sClient.Get(ctx, lookupKey, secret)
+			}, timeout, interval).Should(Succeed())
+
+			Expect(secret.Type).Should(Equal(corev1.SecretTypeBasicAuth))
+			Expect(string(secret.Data[corev1.BasicAuthUsernameKey])).Should(Equal("test-keytest-versionTestParameter"))
+
+			By("Changing the target type")
+			Eventually(func() error {
+				es := &secretsv1alpha1.ExternalSecret{}
+				if err := k8sClient.Get(ctx, lookupKey, es); err != nil {
+					return err
+				}
+				es.Spec.Target.Type = corev1.SecretTypeOpaque
+				return k8sClient.Update(ctx, es)
+			}, timeout, interval).Should(Succeed())
+
+			Eventually(func() corev1.SecretType {
+				k8sClient.Get(ctx, lookupKey, secret)
+				return secret.Type
+			}, timeout, interval).Should(Equal(corev1.SecretTypeOpaque))
+		})
+
+		It("Should not create a Secret missing the keys required by its type", func() {
+			err := validateSecretData(corev1.SecretTypeTLS, map[string][]byte{corev1.TLSCertKey: []byte("cert")})
+			Expect(err).ShouldNot(BeNil())
+			Expect(err.Error()).Should(Equal("kubernetes.io/tls secret: key tls.key is required"))
+
+			err = validateSecretData(corev1.SecretTypeDockerConfigJson, map[string][]byte{corev1.DockerConfigJsonKey: []byte("{")})
+			Expect(err).ShouldNot(BeNil())
+
+			err = validateSecretData(corev1.SecretTypeSSHAuth, map[string][]byte{corev1.SSHAuthPrivateKey: []byte("key")})
+			Expect(err).Should(BeNil())
+		})
+	})
+
+	Context("When a decodingStrategy is provided", func() {
+		ctx := context.Background()
+
+		It("Should only create the Secret when the values can be decoded", func() {
+			randomObjSafeStr, err := utils.RandomStringObjectSafe(16)
+			Expect(err).To(BeNil())
+
+			secretStore := &storev1alpha1.SecretStore{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      SecretStoreName + randomObjSafeStr,
+					Namespace: ExternalSecretNamespace,
+				},
+				Spec: storev1alpha1.SecretStoreSpec{
+					Controller: StoreControllerName + randomObjSafeStr,
+					Store: runtime.RawExtension{
+						Raw: []byte(StoreConfig),
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, secretStore)).Should(Succeed())
+
+			newExternalSecret := func(name string, strategy secretsv1alpha1.ExternalSecretDecodingStrategy) *secretsv1alpha1.ExternalSecret {
+				return &secretsv1alpha1.ExternalSecret{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      name,
+						Namespace: ExternalSecretNamespace,
+					},
+					Spec: secretsv1alpha1.ExternalSecretSpec{
+						StoreRef: secretsv1alpha1.ExternalSecretStoreRef{
+							Name: secretStore.Name,
+						},
+						Data: []secretsv1alpha1.ExternalSecretData{
+							{
+								Key:              ExternalSecretKey,
+								Version:          ExternalSecretVersion,
+								DecodingStrategy: strategy,
+							},
+						},
+					},
+				}
+			}
+
+			// The dummy value is neither base64 nor hex, Auto writes it as retrieved
+			autoSecret := newExternalSecret(ExternalSecretName+"-auto"+randomObjSafeStr, secretsv1alpha1.DecodingStrategyAuto)
+			Expect(k8sClient.Create(ctx, autoSecret)).Should(Succeed())
+
+			secret := &corev1.Secret{}
+			Eventually(func() error {
+				return k8sClient.Get(ctx, types.NamespacedName{Name: autoSecret.Name, Namespace: ExternalSecretNamespace}, secret)
+			}, timeout, interval).Should(Succeed())
+			Expect(string(secret.Data[ExternalSecretKey])).Should(Equal("test-keytest-versionTestParameter"))
+
+			hexSecret := newExternalSecret(ExternalSecretName+"-hex"+randomObjSafeStr, secretsv1alpha1.DecodingStrategyHex)
+			Expect(k8sClient.Create(ctx, hexSecret)).Should(Succeed())
+
+			Consistently(func() error {
+				return k8sClient.Get(ctx, types.NamespacedName{Name: hexSecret.Name, Namespace: ExternalSecretNamespace}, &corev1.Secret{})
+			}, duration, interval).ShouldNot(Succeed())
+		})
+	})
+
+	Context("When rewrite rules are provided", func() {
+		ctx := context.Background()
+
+		It("Should rewrite the keys generated from remote keys", func() {
+			rules := []secretsv1alpha1.ExternalSecretRewrite{
+				{Regexp: &secretsv1alpha1.ExternalSecretRewriteRegexp{Source: "^prod/", Target: ""}},
+				{Transform: secretsv1alpha1.KeyTransformSanitize},
+				{StripPrefix: "db_", AddPrefix: "app_", Transform: secretsv1alpha1.KeyTransformUpper},
+			}
+			key, err := rewriteKey(rules, "prod/db/password")
+			Expect(err).To(BeNil())
+			Expect(key).Should(Equal("APP_PASSWORD"))
+
+			_, err = rewriteKey([]secretsv1alpha1.ExternalSecretRewrite{{Regexp: &secretsv1alpha1.ExternalSecretRewriteRegexp{Source: "("}}}, "key")
+			Expect(err).ShouldNot(BeNil())
+
+			_, err = rewriteKey([]secretsv1alpha1.ExternalSecretRewrite{{StripPrefix: "key"}}, "key")
+			Expect(err).ShouldNot(BeNil())
+
+			owners := newKeyOwners()
+			owners.claim("KEY", "b/key")
+			owners.claim("KEY", "a/key")
+			owners.claim("OTHER", "other")
+			Expect(owners.err()).ShouldNot(BeNil())
+			Expect(owners.err().Error()).Should(Equal("key collision: a/key, b/key are all written to key KEY"))
+
+			owners = newKeyOwners()
+			Expect(owners.claim("db/password", "prod/db/password")).To(BeFalse())
+			Expect(owners.err()).ShouldNot(BeNil())
+			Expect(owners.err().Error()).Should(Equal("invalid key: prod/db/password written to a key not valid in a Secret: db/password"))
+			Expect(owners.err().(*keyError).reason()).Should(Equal(secretsv1alpha1.ReasonInvalidKey))
+		})
+
+		It("Should report listed keys rewritten to the same key", func() {
+			randomObjSafeStr, err := utils.RandomStringObjectSafe(16)
+			Expect(err).To(BeNil())
+
+			secretStore := &storev1alpha1.SecretStore{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      SecretStoreName + randomObjSafeStr,
+					Namespace: ExternalSecretNamespace,
+				},
+				Spec: storev1alpha1.SecretStoreSpec{
+					Controller: StoreControllerName + randomObjSafeStr,
+					Store: runtime.RawExtension{
+						Raw: []byte(StoreConfig),
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, secretStore)).Should(Succeed())
+
+			externalSecret := &secretsv1alpha1.ExternalSecret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      ExternalSecretName + randomObjSafeStr,
+					Namespace: ExternalSecretNamespace,
+				},
+				Spec: secretsv1alpha1.ExternalSecretSpec{
+					StoreRef: secretsv1alpha1.ExternalSecretStoreRef{
+						Name: secretStore.Name,
+					},
+					DataFrom: []secretsv1alpha1.ExternalSecretDataFrom{{}},
+					Rewrite: []secretsv1alpha1.ExternalSecretRewrite{
+						{Regexp: &secretsv1alpha1.ExternalSecretRewriteRegexp{Source: "^.*listed-key$", Target: "LISTED"}},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, externalSecret)).Should(Succeed())
+
+			lookupKey := types.NamespacedName{Name: externalSecret.Name, Namespace: ExternalSecretNamespace}
+			Consistently(func() error {
+				return k8sClient.Get(ctx, lookupKey, &corev1.Secret{})
+			}, duration, interval).ShouldNot(Succeed())
+
+			Eventually(func() string {
+				es := &secretsv1alpha1.ExternalSecret{}
+				k8sClient.Get(ctx, lookupKey, es)
+				condition := meta.FindStatusCondition(es.Status.Conditions, secretsv1alpha1.ExternalSecretReady)
+				if condition == nil {
+					return ""
+				}
+				return condition.Reason
+			}, timeout, interval).Should(Equal(secretsv1alpha1.ReasonKeyCollision))
+
+			By("Rewriting the listed keys to distinct keys")
+			Eventually(func() error {
+				es := &secretsv1alpha1.ExternalSecret{}
+				if err := k8sClient.Get(ctx, lookupKey, es); err != nil {
+					return err
+				}
+				es.Spec.Rewrite = []secretsv1alpha1.ExternalSecretRewrite{
+					{Transform: secretsv1alpha1.KeyTransformSanitize},
+					{Regexp: &secretsv1alpha1.ExternalSecretRewriteRegexp{Source: "-", Target: "_"}, Transform: secretsv1alpha1.KeyTransformUpper},
+				}
+				return k8sClient.Update(ctx, es)
+			}, timeout, interval).Should(Succeed())
+
+			secret := &corev1.Secret{}
+			Eventually(func() error {
+				return k8sClient.Get(ctx, lookupKey, secret)
+			}, timeout, interval).Should(Succeed())
+			Expect(string(secret.Data["LISTED_KEY"])).Should(Equal("listed-keyTestParameter"))
+			Expect(string(secret.Data["OTHER_LISTED_KEY"])).Should(Equal("other-listed-keyTestParameter"))
+		})
+
+		It("Should report data and listed keys written to the same key, and invalid keys", func() {
+			randomObjSafeStr, err := utils.RandomStringObjectSafe(16)
+			Expect(err).To(BeNil())
+
+			secretStore := &storev1alpha1.SecretStore{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      SecretStoreName + randomObjSafeStr,
+					Namespace: ExternalSecretNamespace,
+				},
+				Spec: storev1alpha1.SecretStoreSpec{
+					Controller: StoreControllerName + randomObjSafeStr,
+					Store: runtime.RawExtension{
+						Raw: []byte(StoreConfig),
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, secretStore)).Should(Succeed())
+
+			externalSecret := &secretsv1alpha1.ExternalSecret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      ExternalSecretName + randomObjSafeStr,
+					Namespace: ExternalSecretNamespace,
+				},
+				Spec: secretsv1alpha1.ExternalSecretSpec{
+					StoreRef: secretsv1alpha1.ExternalSecretStoreRef{
+						Name: secretStore.Name,
+					},
+					Data:     []secretsv1alpha1.ExternalSecretData{{Key: ExternalSecretKey, SecretKey: "listed-key"}},
+					DataFrom: []secretsv1alpha1.ExternalSecretDataFrom{{}},
+				},
+			}
+			Expect(k8sClient.Create(ctx, externalSecret)).Should(Succeed())
+
+			lookupKey := types.NamespacedName{Name: externalSecret.Name, Namespace: ExternalSecretNamespace}
+			readyReason := func() string {
+				es := &secretsv1alpha1.ExternalSecret{}
+				k8sClient.Get(ctx, lookupKey, es)
+				condition := meta.FindStatusCondition(es.Status.Conditions, secretsv1alpha1.ExternalSecretReady)
+				if condition == nil {
+					return ""
+				}
+				return condition.Reason
+			}
+			Eventually(readyReason, timeout, interval).Should(Equal(secretsv1alpha1.ReasonKeyCollision))
+			Consistently(func() error {
+				return k8sClient.Get(ctx, lookupKey, &corev1.Secret{})
+			}, duration, interval).ShouldNot(Succeed())
+
+			By("Rewriting the listed keys to keys not valid in a Secret")
+			Eventually(func() error {
+				es := &secretsv1alpha1.ExternalSecret{}
+				if err := k8sClient.Get(ctx, lookupKey, es); err != nil {
+					return err
+				}
+				es.Spec.Data[0].SecretKey = "data-key"
+				es.Spec.Rewrite = []secretsv1alpha1.ExternalSecretRewrite{{AddPrefix: "listed/"}}
+				return k8sClient.Update(ctx, es)
+			}, timeout, interval).Should(Succeed())
+
+			Eventually(readyReason, timeout, interval).Should(Equal(secretsv1alpha1.ReasonInvalidKey))
+			Consistently(func() error {
+				return k8sClient.Get(ctx, lookupKey, &corev1.Secret{})
+			}, duration, interval).ShouldNot(Succeed())
+		})
+	})
+
+	Context("When the SecretStore has a policy", func() {
+		ctx := context.Background()
+
+		It("Should report the keys the policy denies", func() {
+			randomObjSafeStr, err := utils.RandomStringObjectSafe(16)
+			Expect(err).To(BeNil())
+
+			secretStore := &storev1alpha1.SecretStore{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      SecretStoreName + randomObjSafeStr,
+					Namespace: ExternalSecretNamespace,
+				},
+				Spec: storev1alpha1.SecretStoreSpec{
+					Controller: StoreControllerName + randomObjSafeStr,
+					Store: runtime.RawExtension{
+						Raw: []byte(StoreConfig),
+					},
+					Policy: &storev1alpha1.SecretStorePolicy{
+						Rules: []storev1alpha1.SecretStorePolicyRule{
+							{Action: storev1alpha1.PolicyActionAllow, Keys: []string{"listed-*"}},
+						},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, secretStore)).Should(Succeed())
+
+			externalSecret := &secretsv1alpha1.ExternalSecret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      ExternalSecretName + randomObjSafeStr,
+					Namespace: ExternalSecretNamespace,
+				},
+				Spec: secretsv1alpha1.ExternalSecretSpec{
+					StoreRef: secretsv1alpha1.ExternalSecretStoreRef{
+						Name: secretStore.Name,
+					},
+					Data: []secretsv1alpha1.ExternalSecretData{
+						{
+							Key: ExternalSecretKey,
+						},
+					},
+					DataFrom: []secretsv1alpha1.ExternalSecretDataFrom{{}},
+				},
+			}
+			Expect(k8sClient.Create(ctx, externalSecret)).Should(Succeed())
+
+			lookupKey := types.NamespacedName{Name: externalSecret.Name, Namespace: ExternalSecretNamespace}
+			Consistently(func() error {
+				return k8sClient.Get(ctx, lookupKey, &corev1.Secret{})
+			}, duration, interval).ShouldNot(Succeed())
+
+			Eventually(func() string {
+				es := &secretsv1alpha1.ExternalSecret{}
+				k8sClient.Get(ctx, lookupKey, es)
+				condition := meta.FindStatusCondition(es.Status.Conditions, secretsv1alpha1.ExternalSecretReady)
+				if condition == nil {
+					return ""
+				}
+				return condition.Reason
+			}, timeout, interval).Should(Equal(secretsv1alpha1.ReasonAccessDenied))
+
+			By("Only reading listed keys")
+			Eventually(func() error {
+				es := &secretsv1alpha1.ExternalSecret{}
+				if err := k8sClient.Get(ctx, lookupKey, es); err != nil {
+					return err
+				}
+				es.Spec.Data = nil
+				return k8sClient.Update(ctx, es)
+			}, timeout, interval).Should(Succeed())
+
+			secret := &corev1.Secret{}
+			Eventually(func() error {
+				return k8sClient.Get(ctx, lookupKey, secret)
+			}, timeout, interval).Should(Succeed())
+			// other-listed-key does not match the policy and is left out
+			Expect(secret.Data).Should(HaveLen(1))
+			Expect(string(secret.Data["listed-key"])).Should(Equal("listed-keyTestParameter"))
+
+			By("Not decoding the listed keys the policy denies")
+			Eventually(func() error {
+				es := &secretsv1alpha1.ExternalSecret{}
+				if err := k8sClient.Get(ctx, lookupKey, es); err != nil {
+					return err
+				}
+				// other-listed-key is not hexadecimal, decoding it would fail the sync
+				es.Spec.DataFrom = []secretsv1alpha1.ExternalSecretDataFrom{
+					{Prefix: "listed-"},
+					{Prefix: "other-", DecodingStrategy: secretsv1alpha1.DecodingStrategyHex},
+				}
+				return k8sClient.Update(ctx, es)
+			}, timeout, interval).Should(Succeed())
+
+			Consistently(func() metav1.ConditionStatus {
+				es := &secretsv1alpha1.ExternalSecret{}
+				k8sClient.Get(ctx, lookupKey, es)
+				condition := meta.FindStatusCondition(es.Status.Conditions, secretsv1alpha1.ExternalSecretReady)
+				if condition == nil {
+					return ""
+				}
+				return condition.Status
+			}, duration, interval).Should(Equal(metav1.ConditionTrue))
+		})
+	})
+
+	Context("When a selector is set", func() {
+		It("Should only select the ExternalSecrets matching it", func() {
+			selector, err := labels.Parse("controller in (team-a, team-b)")
+			Expect(err).To(BeNil())
+
+			r := &ExternalSecretReconciler{Selector: selector}
+			Expect(r.selects(&metav1.ObjectMeta{Labels: map[string]string{"controller": "team-b"}})).To(BeTrue())
+			Expect(r.selects(&metav1.ObjectMeta{Labels: map[string]string{"controller": "team-c"}})).To(BeFalse())
+
+			r.Selector = nil
+			Expect(r.selects(&metav1.ObjectMeta{})).To(BeTrue())
+		})
+	})
+
+	Context("When a controller class is set", func() {
+		It("Should only select the ExternalSecrets whose SecretStore is of its class", func() {
+			ctx := context.Background()
+
+			secretStore := &storev1alpha1.SecretStore{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "class-blue-store",
+					Namespace: ExternalSecretNamespace,
+				},
+				Spec: storev1alpha1.SecretStoreSpec{
+					Controller: "blue",
+					Store:      runtime.RawExtension{Raw: []byte(StoreConfig)},
+				},
+			}
+			Expect(k8sClient.Create(ctx, secretStore)).Should(Succeed())
+			defer func() {
+				Expect(k8sClient.Delete(ctx, secretStore)).Should(Succeed())
+			}()
+
+			externalSecret := &secretsv1alpha1.ExternalSecret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "class-blue-externalsecret",
+					Namespace: ExternalSecretNamespace,
+				},
+				Spec: secretsv1alpha1.ExternalSecretSpec{
+					StoreRef: secretsv1alpha1.ExternalSecretStoreRef{Name: secretStore.Name},
+				},
+			}
+
+			r := &ExternalSecretReconciler{Client: k8sClient, ControllerClass: "blue"}
+			Expect(r.inClass(ctx, externalSecret)).To(BeTrue())
+
+			r.ControllerClass = "green"
+			Expect(r.inClass(ctx, externalSecret)).To(BeFalse())
+
+			By("Leaving ExternalSecrets of missing SecretStores to instances without class")
+			externalSecret.Spec.StoreRef.Name = "missing-store"
+			Expect(r.inClass(ctx, externalSecret)).To(BeFalse())
+
+			r.ControllerClass = ""
+			Expect(r.inClass(ctx, externalSecret)).To(BeTrue())
+
+			By("Cleaning up deleted ExternalSecrets of missing SecretStores in every instance")
+			r.ControllerClass = "green"
+			externalSecret.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+			Expect(r.inClass(ctx, externalSecret)).To(BeTrue())
+		})
+	})
+
 })
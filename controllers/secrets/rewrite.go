@@ -0,0 +1,170 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	secretsv1alpha1 "github.com/containersolutions/externalsecret-operator/apis/secrets/v1alpha1"
+)
+
+// invalidKeyChars matches the characters not allowed in Secret keys
+var invalidKeyChars = regexp.MustCompile(`[^-._a-zA-Z0-9]`)
+
+// rewriteKey applies rules to key in order
+func rewriteKey(rules []secretsv1alpha1.ExternalSecretRewrite, key string) (string, error) {
+	rewritten := key
+	for _, rule := range rules {
+		if rule.Regexp != nil {
+			source, err := regexp.Compile(rule.Regexp.Source)
+			if err != nil {
+				return "", fmt.Errorf("invalid rewrite regexp %q: %v", rule.Regexp.Source, err)
+			}
+			rewritten = source.ReplaceAllString(rewritten, rule.Regexp.Target)
+		}
+
+		rewritten = strings.TrimPrefix(rewritten, rule.StripPrefix)
+		rewritten = rule.AddPrefix + rewritten
+
+		switch rule.Transform {
+		case secretsv1alpha1.KeyTransformUpper:
+			rewritten = strings.ToUpper(rewritten)
+		case secretsv1alpha1.KeyTransformLower:
+			rewritten = strings.ToLower(rewritten)
+		case secretsv1alpha1.KeyTransformSanitize:
+			rewritten = invalidKeyChars.ReplaceAllString(rewritten, "_")
+		}
+	}
+
+	if rewritten == "" {
+		return "", fmt.Errorf("key %s is rewritten to an empty key", key)
+	}
+	return rewritten, nil
+}
+
+// dataKey returns the key of the target Secret data is written to, its
+// secretKey or its rewritten remote key
+func dataKey(s *secretsv1alpha1.ExternalSecret, data secretsv1alpha1.ExternalSecretData) (string, error) {
+	if data.SecretKey != "" {
+		return data.SecretKey, nil
+	}
+	return rewriteKey(s.Spec.Rewrite, data.Key)
+}
+
+// keyError reports the keys of the target Secret several remote keys are
+// written to, and the remote keys written to keys not valid in a Secret
+type keyError struct {
+	collisions map[string][]string
+	invalid    map[string][]string
+}
+
+func (e *keyError) Error() string {
+	messages := []string{}
+	if len(e.collisions) > 0 {
+		messages = append(messages, "key collision: "+describeKeys(e.collisions, "are all written to key %s"))
+	}
+	if len(e.invalid) > 0 {
+		messages = append(messages, "invalid key: "+describeKeys(e.invalid, "written to a key not valid in a Secret: %s"))
+	}
+	return strings.Join(messages, "; ")
+}
+
+// reason returns the reason of the Ready condition reporting e
+func (e *keyError) reason() string {
+	if len(e.collisions) > 0 {
+		return secretsv1alpha1.ReasonKeyCollision
+	}
+	return secretsv1alpha1.ReasonInvalidKey
+}
+
+// describeKeys lists the remote keys of each key, sorted, with format
+// describing the key
+func describeKeys(remoteKeysByKey map[string][]string, format string) string {
+	keys := make([]string, 0, len(remoteKeysByKey))
+	for key := range remoteKeysByKey {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	messages := make([]string, 0, len(keys))
+	for _, key := range keys {
+		remoteKeys := append([]string{}, remoteKeysByKey[key]...)
+		sort.Strings(remoteKeys)
+		messages = append(messages, strings.Join(remoteKeys, ", ")+" "+fmt.Sprintf(format, key))
+	}
+	return strings.Join(messages, "; ")
+}
+
+// keyOwners tracks the remote key written to each key of the target Secret
+type keyOwners struct {
+	owners     map[string]string
+	collisions map[string][]string
+	invalid    map[string][]string
+}
+
+func newKeyOwners() *keyOwners {
+	return &keyOwners{owners: map[string]string{}, collisions: map[string][]string{}, invalid: map[string][]string{}}
+}
+
+// claim records remoteKey is written to key, a collision when another remote
+// key already is, and an invalid key when key is not a valid Secret key.
+// It returns false in both cases.
+func (o *keyOwners) claim(key string, remoteKey string) bool {
+	if errs := validation.IsConfigMapKey(key); len(errs) > 0 {
+		o.invalid[key] = append(o.invalid[key], remoteKey)
+		return false
+	}
+
+	owner, ok := o.owners[key]
+	if !ok {
+		o.owners[key] = remoteKey
+		return true
+	}
+	if len(o.collisions[key]) == 0 {
+		o.collisions[key] = []string{owner}
+	}
+	o.collisions[key] = append(o.collisions[key], remoteKey)
+	return false
+}
+
+// err returns a *keyError when collisions or invalid keys were recorded
+func (o *keyOwners) err() error {
+	if len(o.collisions) == 0 && len(o.invalid) == 0 {
+		return nil
+	}
+	return &keyError{collisions: o.collisions, invalid: o.invalid}
+}
+
+// reportKeyError reports keyErr in the Ready condition of s instead of
+// writing the target Secret. s is retried as the listed keys may change.
+func (r *ExternalSecretReconciler) reportKeyError(ctx context.Context, s *secretsv1alpha1.ExternalSecret, keyErr *keyError) (ctrl.Result, error) {
+	r.Log.Info("Invalid target keys", "reason", keyErr.Error())
+	err := r.setReadyCondition(ctx, s, metav1.ConditionFalse, keyErr.reason(), keyErr.Error())
+	if err != nil {
+		r.Log.Error(err, "Failed to update ExternalSecret status")
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{RequeueAfter: r.retryPeriod()}, nil
+}
@@ -0,0 +1,245 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	secretsv1alpha1 "github.com/containersolutions/externalsecret-operator/apis/secrets/v1alpha1"
+)
+
+const (
+	annotationPrefix = "secrets.externalsecret-operator.container-solutions.com/"
+
+	// RolloutAnnotation opts a Deployment, StatefulSet or DaemonSet in to a rolling restart
+	// whenever the data of one of the comma separated Secrets it lists is updated
+	RolloutAnnotation = annotationPrefix + "rollout-on-change"
+
+	// secretHashAnnotationName prefixes the name of the pod template annotation holding the data hash of a Secret
+	secretHashAnnotationName = "hash-"
+
+	maxAnnotationNameLength = 63
+
+	// rolloutSecretsField indexes workloads by the Secrets listed in their RolloutAnnotation
+	rolloutSecretsField = "metadata.annotations.rollout-on-change"
+)
+
+type workload struct {
+	kind     string
+	name     string
+	object   runtime.Object
+	template *corev1.PodTemplateSpec
+}
+
+// rollout restarts the workloads consuming secretName by setting the hash of
+// its data on their pod template. Nothing is looked up while the hash is the
+// one last rolled out, otherwise every workload without this hash is patched,
+// so that a reconcile failing after updating the Secret is rolled out by the
+// next one.
+func (r *ExternalSecretReconciler) rollout(ctx context.Context, s *secretsv1alpha1.ExternalSecret, secretName string, data map[string][]byte) error {
+	hash := dataHash(data)
+	if s.Status.RolloutHash == hash {
+		return nil
+	}
+
+	workloads, err := r.rolloutWorkloads(ctx, s, secretName)
+	if err != nil {
+		return err
+	}
+
+	annotation := secretHashAnnotation(secretName)
+
+	for _, w := range workloads {
+		if w.template.Annotations[annotation] == hash {
+			continue
+		}
+
+		patch := client.MergeFrom(w.object.DeepCopyObject())
+		if w.template.Annotations == nil {
+			w.template.Annotations = map[string]string{}
+		}
+		w.template.Annotations[annotation] = hash
+
+		r.Log.Info("Rolling out workload", "kind", w.kind, "name", w.name, "secret", secretName)
+		if err := r.Patch(ctx, w.object, patch); err != nil {
+			return err
+		}
+	}
+
+	s.Status.RolloutHash = hash
+	if s.Status.Conditions == nil {
+		s.Status.Conditions = []metav1.Condition{}
+	}
+	return r.Status().Update(ctx, s)
+}
+
+// rolloutWorkloads returns the workloads listed in rolloutTargets or annotated with secretName
+func (r *ExternalSecretReconciler) rolloutWorkloads(ctx context.Context, s *secretsv1alpha1.ExternalSecret, secretName string) ([]workload, error) {
+	workloads, err := r.listWorkloads(ctx, s.Namespace, client.MatchingFields{rolloutSecretsField: secretName})
+	if err != nil {
+		return nil, err
+	}
+
+	selected := map[string]bool{}
+	for _, w := range workloads {
+		selected[w.kind+"/"+w.name] = true
+	}
+
+	for _, target := range s.Spec.RolloutTargets {
+		if selected[target.Kind+"/"+target.Name] {
+			continue
+		}
+		w, found, err := r.getWorkload(ctx, s.Namespace, target.Kind, target.Name)
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			selected[target.Kind+"/"+target.Name] = true
+			workloads = append(workloads, w)
+		}
+	}
+	return workloads, nil
+}
+
+// getWorkload returns the workload kind/name, found is false when it does not exist
+func (r *ExternalSecretReconciler) getWorkload(ctx context.Context, namespace, kind, name string) (workload, bool, error) {
+	var w workload
+	switch kind {
+	case "Deployment":
+		d := &appsv1.Deployment{}
+		w = workload{kind: kind, name: name, object: d, template: &d.Spec.Template}
+	case "StatefulSet":
+		s := &appsv1.StatefulSet{}
+		w = workload{kind: kind, name: name, object: s, template: &s.Spec.Template}
+	case "DaemonSet":
+		d := &appsv1.DaemonSet{}
+		w = workload{kind: kind, name: name, object: d, template: &d.Spec.Template}
+	default:
+		return w, false, nil
+	}
+
+	err := r.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, w.object)
+	if errors.IsNotFound(err) {
+		return w, false, nil
+	}
+	return w, err == nil, err
+}
+
+func (r *ExternalSecretReconciler) listWorkloads(ctx context.Context, namespace string, opts ...client.ListOption) ([]workload, error) {
+	workloads := []workload{}
+	opts = append(opts, client.InNamespace(namespace))
+
+	deployments := &appsv1.DeploymentList{}
+	if err := r.List(ctx, deployments, opts...); err != nil {
+		return nil, err
+	}
+	for i := range deployments.Items {
+		d := &deployments.Items[i]
+		workloads = append(workloads, workload{kind: "Deployment", name: d.Name, object: d, template: &d.Spec.Template})
+	}
+
+	statefulSets := &appsv1.StatefulSetList{}
+	if err := r.List(ctx, statefulSets, opts...); err != nil {
+		return nil, err
+	}
+	for i := range statefulSets.Items {
+		s := &statefulSets.Items[i]
+		workloads = append(workloads, workload{kind: "StatefulSet", name: s.Name, object: s, template: &s.Spec.Template})
+	}
+
+	daemonSets := &appsv1.DaemonSetList{}
+	if err := r.List(ctx, daemonSets, opts...); err != nil {
+		return nil, err
+	}
+	for i := range daemonSets.Items {
+		d := &daemonSets.Items[i]
+		workloads = append(workloads, workload{kind: "DaemonSet", name: d.Name, object: d, template: &d.Spec.Template})
+	}
+
+	return workloads, nil
+}
+
+// indexRolloutSecrets registers the rolloutSecretsField index of the
+// Deployments, StatefulSets and DaemonSets annotated with RolloutAnnotation
+func indexRolloutSecrets(indexer client.FieldIndexer) error {
+	for _, obj := range []runtime.Object{&appsv1.Deployment{}, &appsv1.StatefulSet{}, &appsv1.DaemonSet{}} {
+		err := indexer.IndexField(context.Background(), obj, rolloutSecretsField, func(o runtime.Object) []string {
+			accessor, err := meta.Accessor(o)
+			if err != nil {
+				return nil
+			}
+			return rolloutSecrets(accessor.GetAnnotations())
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rolloutSecrets returns the names of the Secrets listed in RolloutAnnotation
+func rolloutSecrets(annotations map[string]string) []string {
+	names := []string{}
+	for _, name := range strings.Split(annotations[RolloutAnnotation], ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// secretHashAnnotation returns the pod template annotation for secretName,
+// shortened with a hash when the name does not fit in an annotation name
+func secretHashAnnotation(secretName string) string {
+	name := secretHashAnnotationName + secretName
+	if len(name) > maxAnnotationNameLength {
+		sum := sha256.Sum256([]byte(secretName))
+		name = name[:maxAnnotationNameLength-9] + "-" + hex.EncodeToString(sum[:])[:8]
+	}
+	return annotationPrefix + name
+}
+
+// dataHash returns a stable hash of Secret data
+func dataHash(data map[string][]byte) string {
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	hash := sha256.New()
+	for _, k := range keys {
+		hash.Write([]byte(k))
+		hash.Write([]byte{0})
+		hash.Write(data[k])
+		hash.Write([]byte{0})
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
@@ -0,0 +1,82 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+
+	secretsv1alpha1 "github.com/containersolutions/externalsecret-operator/apis/secrets/v1alpha1"
+)
+
+// secretType returns the type of the target Secret, Opaque by default
+func secretType(s *secretsv1alpha1.ExternalSecret) corev1.SecretType {
+	if s.Spec.Target.Type != "" {
+		return s.Spec.Target.Type
+	}
+	return corev1.SecretTypeOpaque
+}
+
+// sameSecretType tells whether a Secret of type current can hold data of type desired
+func sameSecretType(current corev1.SecretType, desired corev1.SecretType) bool {
+	if current == "" {
+		current = corev1.SecretTypeOpaque
+	}
+	return current == desired
+}
+
+// secretKey returns the key of the target Secret data is written to
+func secretKey(data secretsv1alpha1.ExternalSecretData) string {
+	if data.SecretKey != "" {
+		return data.SecretKey
+	}
+	return data.Key
+}
+
+// validateSecretData checks data holds the keys required by the Secret type,
+// so that invalid values are reported instead of being rejected by the API server
+func validateSecretData(secretType corev1.SecretType, data map[string][]byte) error {
+	switch secretType {
+	case corev1.SecretTypeTLS:
+		return requireKeys(secretType, data, corev1.TLSCertKey, corev1.TLSPrivateKeyKey)
+	case corev1.SecretTypeDockerConfigJson:
+		if err := requireKeys(secretType, data, corev1.DockerConfigJsonKey); err != nil {
+			return err
+		}
+		if !json.Valid(data[corev1.DockerConfigJsonKey]) {
+			return fmt.Errorf("%s secret: key %s is not valid JSON", secretType, corev1.DockerConfigJsonKey)
+		}
+	case corev1.SecretTypeBasicAuth:
+		if len(data[corev1.BasicAuthUsernameKey]) == 0 && len(data[corev1.BasicAuthPasswordKey]) == 0 {
+			return fmt.Errorf("%s secret: one of keys %s or %s is required", secretType, corev1.BasicAuthUsernameKey, corev1.BasicAuthPasswordKey)
+		}
+	case corev1.SecretTypeSSHAuth:
+		return requireKeys(secretType, data, corev1.SSHAuthPrivateKey)
+	}
+	return nil
+}
+
+func requireKeys(secretType corev1.SecretType, data map[string][]byte, keys ...string) error {
+	for _, key := range keys {
+		if len(data[key]) == 0 {
+			return fmt.Errorf("%s secret: key %s is required", secretType, key)
+		}
+	}
+	return nil
+}
@@ -37,6 +37,8 @@ import (
 	secretsv1alpha1 "github.com/containersolutions/externalsecret-operator/apis/secrets/v1alpha1"
 	storev1alpha1 "github.com/containersolutions/externalsecret-operator/apis/store/v1alpha1"
 	storecontroller "github.com/containersolutions/externalsecret-operator/controllers/store"
+	"github.com/containersolutions/externalsecret-operator/pkg/backend"
+	"github.com/containersolutions/externalsecret-operator/pkg/store"
 	// +kubebuilder:scaffold:imports
 )
 
@@ -46,6 +48,7 @@ import (
 var cfg *rest.Config
 var k8sClient client.Client
 var testEnv *envtest.Environment
+var backends *backend.Registry
 
 func TestAPIs(t *testing.T) {
 	RegisterFailHandler(Fail)
@@ -94,17 +97,25 @@ var _ = BeforeSuite(func(done Done) {
 	})
 	Expect(err).ToNot(HaveOccurred())
 
+	backendCache := backend.NewCache(backend.DefaultCacheTTL, backend.DefaultCacheSize)
+	backends = backend.NewRegistry()
+	stores := store.NewManager(k8sManager.GetClient(), ctrl.Log.WithName("store"), backends, backendCache)
+
 	err = (&storecontroller.SecretStoreReconciler{
 		Client: k8sManager.GetClient(),
 		Log:    ctrl.Log.WithName("controllers").WithName("SecretStore"),
 		Scheme: k8sManager.GetScheme(),
+		Stores: stores,
 	}).SetupWithManager(k8sManager)
 	Expect(err).ToNot(HaveOccurred())
 
 	err = (&ExternalSecretReconciler{
-		Client: k8sManager.GetClient(),
-		Log:    ctrl.Log.WithName("controllers").WithName("ExternalSecret"),
-		Scheme: k8sManager.GetScheme(),
+		Client:   k8sManager.GetClient(),
+		Log:      ctrl.Log.WithName("controllers").WithName("ExternalSecret"),
+		Scheme:   k8sManager.GetScheme(),
+		Cache:    backendCache,
+		Backends: backends,
+		Stores:   stores,
 	}).SetupWithManager(k8sManager)
 	Expect(err).ToNot(HaveOccurred())
 
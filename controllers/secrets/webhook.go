@@ -0,0 +1,90 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"net/http"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	secretsv1alpha1 "github.com/containersolutions/externalsecret-operator/apis/secrets/v1alpha1"
+	storev1alpha1 "github.com/containersolutions/externalsecret-operator/apis/store/v1alpha1"
+	"github.com/containersolutions/externalsecret-operator/pkg/policy"
+)
+
+// ValidatePath is the path the ExternalSecret validating webhook is served on
+const ValidatePath = "/validate-secrets-externalsecret-operator-container-solutions-com-v1alpha1-externalsecret"
+
+// +kubebuilder:webhook:path=/validate-secrets-externalsecret-operator-container-solutions-com-v1alpha1-externalsecret,mutating=false,failurePolicy=fail,groups=secrets.externalsecret-operator.container-solutions.com,resources=externalsecrets,verbs=create;update,versions=v1alpha1,name=vexternalsecret.externalsecret-operator.container-solutions.com
+
+// ExternalSecretValidator rejects ExternalSecrets reading keys the policy of
+// their SecretStore denies
+type ExternalSecretValidator struct {
+	Client  client.Client
+	decoder *admission.Decoder
+}
+
+// Handle validates the ExternalSecret of req against the policy of its SecretStore
+func (v *ExternalSecretValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	externalSecret := &secretsv1alpha1.ExternalSecret{}
+	if err := v.decoder.Decode(req, externalSecret); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	// The finalizer of the operator is added and removed by metadata updates,
+	// an ExternalSecret left behind by a tightened policy can still be deleted
+	if !externalSecret.DeletionTimestamp.IsZero() {
+		return admission.Allowed("ExternalSecret is being deleted")
+	}
+	if req.Operation == admissionv1beta1.Update && len(req.OldObject.Raw) > 0 {
+		oldExternalSecret := &secretsv1alpha1.ExternalSecret{}
+		if err := v.decoder.DecodeRaw(req.OldObject, oldExternalSecret); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		if equality.Semantic.DeepEqual(oldExternalSecret.Spec, externalSecret.Spec) {
+			// Reported by the controller in the Ready condition
+			return admission.Allowed("spec unchanged")
+		}
+	}
+
+	secretStore := &storev1alpha1.SecretStore{}
+	err := v.Client.Get(ctx, types.NamespacedName{Name: externalSecret.Spec.StoreRef.Name, Namespace: req.Namespace}, secretStore)
+	if errors.IsNotFound(err) {
+		// Reported by the controller in the Ready condition
+		return admission.Allowed("SecretStore not found")
+	}
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+
+	if err := policy.Check(secretStore, externalSecret); err != nil {
+		return admission.Denied(err.Error())
+	}
+	return admission.Allowed("")
+}
+
+// InjectDecoder injects the decoder of admission requests
+func (v *ExternalSecretValidator) InjectDecoder(d *admission.Decoder) error {
+	v.decoder = d
+	return nil
+}
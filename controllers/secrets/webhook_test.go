@@ -0,0 +1,156 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	secretsv1alpha1 "github.com/containersolutions/externalsecret-operator/apis/secrets/v1alpha1"
+	storev1alpha1 "github.com/containersolutions/externalsecret-operator/apis/store/v1alpha1"
+	"github.com/containersolutions/externalsecret-operator/pkg/utils"
+)
+
+var _ = Describe("ExternalSecretValidator", func() {
+	ctx := context.Background()
+
+	newRequest := func(s *secretsv1alpha1.ExternalSecret) admission.Request {
+		raw, err := json.Marshal(s)
+		Expect(err).To(BeNil())
+		return admission.Request{AdmissionRequest: admissionv1beta1.AdmissionRequest{
+			Namespace: s.Namespace,
+			Object:    runtime.RawExtension{Raw: raw},
+		}}
+	}
+
+	It("Should reject ExternalSecrets reading keys the SecretStore policy denies", func() {
+		randomObjSafeStr, err := utils.RandomStringObjectSafe(16)
+		Expect(err).To(BeNil())
+
+		secretStore := &storev1alpha1.SecretStore{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-webhook-store" + randomObjSafeStr,
+				Namespace: "default",
+			},
+			Spec: storev1alpha1.SecretStoreSpec{
+				Controller: "test-webhook-ctrl" + randomObjSafeStr,
+				Store:      runtime.RawExtension{Raw: []byte(`{"type": "dummy", "parameters": {"Suffix": "TestParameter"}}`)},
+				Policy: &storev1alpha1.SecretStorePolicy{
+					Rules: []storev1alpha1.SecretStorePolicyRule{
+						{Action: storev1alpha1.PolicyActionAllow, Keys: []string{"shared/*"}},
+					},
+				},
+			},
+		}
+		Expect(k8sClient.Create(ctx, secretStore)).Should(Succeed())
+
+		decoder, err := admission.NewDecoder(scheme.Scheme)
+		Expect(err).To(BeNil())
+		validator := &ExternalSecretValidator{Client: k8sClient}
+		Expect(validator.InjectDecoder(decoder)).To(Succeed())
+
+		externalSecret := &secretsv1alpha1.ExternalSecret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-webhook" + randomObjSafeStr,
+				Namespace: "default",
+			},
+			Spec: secretsv1alpha1.ExternalSecretSpec{
+				StoreRef: secretsv1alpha1.ExternalSecretStoreRef{Name: secretStore.Name},
+				Data:     []secretsv1alpha1.ExternalSecretData{{Key: "shared/db"}},
+			},
+		}
+		Expect(validator.Handle(ctx, newRequest(externalSecret)).Allowed).To(BeTrue())
+
+		externalSecret.Spec.Data = append(externalSecret.Spec.Data, secretsv1alpha1.ExternalSecretData{Key: "prod/db"})
+		response := validator.Handle(ctx, newRequest(externalSecret))
+		Expect(response.Allowed).To(BeFalse())
+		Expect(response.Result.Reason).To(BeEquivalentTo("SecretStore " + secretStore.Name + " denies reading keys prod/db"))
+
+		externalSecret.Spec.StoreRef.Name = "NonExistentStore"
+		Expect(validator.Handle(ctx, newRequest(externalSecret)).Allowed).To(BeTrue())
+	})
+
+	It("Should allow updates of ExternalSecrets the SecretStore policy denies since", func() {
+		randomObjSafeStr, err := utils.RandomStringObjectSafe(16)
+		Expect(err).To(BeNil())
+
+		secretStore := &storev1alpha1.SecretStore{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-webhook-store" + randomObjSafeStr,
+				Namespace: "default",
+			},
+			Spec: storev1alpha1.SecretStoreSpec{
+				Controller: "test-webhook-ctrl" + randomObjSafeStr,
+				Store:      runtime.RawExtension{Raw: []byte(`{"type": "dummy", "parameters": {"Suffix": "TestParameter"}}`)},
+				Policy: &storev1alpha1.SecretStorePolicy{
+					Rules: []storev1alpha1.SecretStorePolicyRule{
+						{Action: storev1alpha1.PolicyActionDeny, Keys: []string{"prod/*"}},
+					},
+				},
+			},
+		}
+		Expect(k8sClient.Create(ctx, secretStore)).Should(Succeed())
+
+		decoder, err := admission.NewDecoder(scheme.Scheme)
+		Expect(err).To(BeNil())
+		validator := &ExternalSecretValidator{Client: k8sClient}
+		Expect(validator.InjectDecoder(decoder)).To(Succeed())
+
+		oldExternalSecret := &secretsv1alpha1.ExternalSecret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "test-webhook" + randomObjSafeStr,
+				Namespace: "default",
+			},
+			Spec: secretsv1alpha1.ExternalSecretSpec{
+				StoreRef: secretsv1alpha1.ExternalSecretStoreRef{Name: secretStore.Name},
+				Data:     []secretsv1alpha1.ExternalSecretData{{Key: "prod/db"}},
+			},
+		}
+		newUpdate := func(s *secretsv1alpha1.ExternalSecret) admission.Request {
+			req := newRequest(s)
+			raw, err := json.Marshal(oldExternalSecret)
+			Expect(err).To(BeNil())
+			req.Operation = admissionv1beta1.Update
+			req.OldObject = runtime.RawExtension{Raw: raw}
+			return req
+		}
+
+		By("Removing the finalizer of a deleting ExternalSecret")
+		deleting := oldExternalSecret.DeepCopy()
+		now := metav1.Now()
+		deleting.DeletionTimestamp = &now
+		Expect(validator.Handle(ctx, newUpdate(deleting)).Allowed).To(BeTrue())
+
+		By("Adding a finalizer without changing the spec")
+		finalized := oldExternalSecret.DeepCopy()
+		finalized.Finalizers = []string{"test"}
+		Expect(validator.Handle(ctx, newUpdate(finalized)).Allowed).To(BeTrue())
+
+		By("Changing the spec")
+		changed := oldExternalSecret.DeepCopy()
+		changed.Spec.Data = append(changed.Spec.Data, secretsv1alpha1.ExternalSecretData{Key: "shared/db"})
+		Expect(validator.Handle(ctx, newUpdate(changed)).Allowed).To(BeFalse())
+	})
+})
@@ -0,0 +1,37 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	storev1alpha1 "github.com/containersolutions/externalsecret-operator/apis/store/v1alpha1"
+	"github.com/containersolutions/externalsecret-operator/pkg/utils"
+)
+
+// setReadyCondition records whether the backend of secretStore is initialized
+// and reachable
+func (r *SecretStoreReconciler) setReadyCondition(ctx context.Context, secretStore *storev1alpha1.SecretStore, status metav1.ConditionStatus, reason string, message string) error {
+	return utils.SetStatusCondition(ctx, r, secretStore, &secretStore.Status.Conditions, metav1.Condition{
+		Type:    storev1alpha1.SecretStoreReady,
+		Status:  status,
+		Reason:  reason,
+		Message: message,
+	})
+}
@@ -18,25 +18,42 @@ package controllers
 
 import (
 	"context"
+	"fmt"
 	"time"
 
+	secretsv1alpha1 "github.com/containersolutions/externalsecret-operator/apis/secrets/v1alpha1"
 	storev1alpha1 "github.com/containersolutions/externalsecret-operator/apis/store/v1alpha1"
 	"github.com/go-logr/logr"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	corev1 "k8s.io/api/core/v1"
-	// metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
-	"github.com/containersolutions/externalsecret-operator/pkg/backend"
-	config "github.com/containersolutions/externalsecret-operator/pkg/config"
+	"github.com/containersolutions/externalsecret-operator/pkg/store"
 )
 
 const (
 	defaulRetryPeriod = time.Second * 30
+
+	// backendFinalizer ensures the backend of a deleted SecretStore is closed and removed
+	backendFinalizer = "store.externalsecret-operator.container-solutions.com/backend-cleanup"
+
+	// credentialsSecretField indexes SecretStores by the name of their credentials Secret
+	credentialsSecretField = "spec.store.auth.secretRef.name"
 )
 
 // SecretStoreReconciler reconciles a SecretStore object
@@ -44,11 +61,28 @@ type SecretStoreReconciler struct {
 	client.Client
 	Log    logr.Logger
 	Scheme *runtime.Scheme
+	// Stores initializes the backends, it is shared with the ExternalSecretReconciler
+	Stores *store.Manager
+
+	// RetryPeriod is the delay before retrying a failed initialization, 30s when zero
+	RetryPeriod time.Duration
+	// HealthCheckInterval is the period of the backend health checks, zero disables them
+	HealthCheckInterval time.Duration
+	// MaxConcurrentReconciles is the number of SecretStores reconciled in parallel
+	MaxConcurrentReconciles int
+	// Selector restricts the reconciled SecretStores to those matching its
+	// labels, nil reconciles every SecretStore
+	Selector labels.Selector
+	// ControllerClass restricts the reconciled SecretStores to those whose
+	// controller is set to it, empty reconciles every SecretStore
+	ControllerClass string
 }
 
 // +kubebuilder:rbac:groups=store.externalsecret-operator.container-solutions.com,resources=secretstores,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=store.externalsecret-operator.container-solutions.com,resources=secretstores/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=secrets.externalsecret-operator.container-solutions.com,resources=externalsecrets,verbs=get;list;watch
+// +kubebuilder:rbac:groups=secrets.externalsecret-operator.container-solutions.com,resources=externalsecrets/status,verbs=get;update;patch
 
 func (r *SecretStoreReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 	ctx := context.Background()
@@ -73,40 +107,184 @@ func (r *SecretStoreReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error)
 		return ctrl.Result{}, err
 	}
 
-	contrl := secretStore.Spec.Controller
+	if !r.selects(secretStore) {
+		// The SecretStore may have been selected before, e.g. until its
+		// controller class changed, another instance now owns its backend
+		if err = r.Stores.Remove(secretStore); err != nil {
+			log.Error(err, "Failed to close backend")
+		}
+		log.Info("SecretStore not selected, ignoring it")
+		return ctrl.Result{}, nil
+	}
+
+	if !secretStore.ObjectMeta.DeletionTimestamp.IsZero() {
+		err = r.finalize(ctx, secretStore)
+		if err != nil {
+			log.Error(err, "Failed to remove backend")
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, nil
+	}
+
+	if !controllerutil.ContainsFinalizer(secretStore, backendFinalizer) {
+		controllerutil.AddFinalizer(secretStore, backendFinalizer)
+		err = r.Update(ctx, secretStore)
+		if err != nil {
+			log.Error(err, "Failed to add finalizer")
+			return ctrl.Result{}, err
+		}
+	}
+
+	err = r.Stores.Init(ctx, secretStore)
+	if err != nil {
+		log.Error(err, "Backend initialization failed")
+		if statusErr := r.setReadyCondition(ctx, secretStore, metav1.ConditionFalse, storev1alpha1.ReasonInitFailed, err.Error()); statusErr != nil {
+			log.Error(statusErr, "Failed to update SecretStore status")
+		}
+		return ctrl.Result{RequeueAfter: r.retryPeriod()}, err
+	}
 
-	storeConfig := secretStore.Spec.Store.Raw
+	if r.HealthCheckInterval == 0 {
+		err = r.setReadyCondition(ctx, secretStore, metav1.ConditionTrue, storev1alpha1.ReasonInitialized, "")
+		if err != nil {
+			log.Error(err, "Failed to update SecretStore status")
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, nil
+	}
 
-	config, err := config.ConfigFromCtrl(storeConfig)
+	checked, err := r.Stores.HealthCheck(ctx, secretStore)
+	switch {
+	case err != nil:
+		log.Error(err, "Backend health check failed")
+		err = r.setReadyCondition(ctx, secretStore, metav1.ConditionFalse, storev1alpha1.ReasonUnhealthy, err.Error())
+	case checked:
+		err = r.setReadyCondition(ctx, secretStore, metav1.ConditionTrue, storev1alpha1.ReasonHealthy, "")
+	default:
+		// Backends without health check are not checked again
+		err = r.setReadyCondition(ctx, secretStore, metav1.ConditionTrue, storev1alpha1.ReasonInitialized, "")
+		if err != nil {
+			log.Error(err, "Failed to update SecretStore status")
+		}
+		return ctrl.Result{}, err
+	}
 	if err != nil {
+		log.Error(err, "Failed to update SecretStore status")
 		return ctrl.Result{}, err
 	}
 
-	secretRef := config.Auth["secretRef"].(map[string]interface{})
-	secretRefName := secretRef["name"].(string)
+	return ctrl.Result{RequeueAfter: r.HealthCheckInterval}, nil
+}
+
+// finalize removes the backend of a deleted SecretStore and marks the
+// ExternalSecrets referencing it as StoreNotFound
+func (r *SecretStoreReconciler) finalize(ctx context.Context, secretStore *storev1alpha1.SecretStore) error {
+	if !controllerutil.ContainsFinalizer(secretStore, backendFinalizer) {
+		return nil
+	}
 
-	// Fetch credential Secret
-	credentialsSecret := &corev1.Secret{}
-	err = r.Get(ctx, types.NamespacedName{Name: secretRefName, Namespace: secretStore.Namespace}, credentialsSecret)
+	err := r.Stores.Remove(secretStore)
 	if err != nil {
-		// Error reading the object - requeue the request.
-		log.Error(err, "Failed to get credentials Secret")
-		return ctrl.Result{RequeueAfter: defaulRetryPeriod}, err
+		// The backend is forgotten even if closing it failed, there is nothing to retry
+		r.Log.Error(err, "Failed to close backend", "secretstore", secretStore.Name)
 	}
 
-	credentials := credentialsSecret.Data["credentials.json"]
+	externalSecrets := &secretsv1alpha1.ExternalSecretList{}
+	err = r.List(ctx, externalSecrets, client.InNamespace(secretStore.Namespace))
+	if err != nil {
+		return err
+	}
+
+	for i := range externalSecrets.Items {
+		externalSecret := &externalSecrets.Items[i]
+		if externalSecret.Spec.StoreRef.Name != secretStore.Name {
+			continue
+		}
+
+		if externalSecret.Status.Conditions == nil {
+			externalSecret.Status.Conditions = []metav1.Condition{}
+		}
+		meta.SetStatusCondition(&externalSecret.Status.Conditions, metav1.Condition{
+			Type:    secretsv1alpha1.ExternalSecretReady,
+			Status:  metav1.ConditionFalse,
+			Reason:  secretsv1alpha1.ReasonStoreNotFound,
+			Message: fmt.Sprintf("SecretStore %s was deleted", secretStore.Name),
+		})
+		err = r.Status().Update(ctx, externalSecret)
+		if err != nil {
+			return err
+		}
+	}
 
-	err = backend.InitFromCtrl(contrl, config, credentials)
+	controllerutil.RemoveFinalizer(secretStore, backendFinalizer)
+	return r.Update(ctx, secretStore)
+}
+
+func (r *SecretStoreReconciler) retryPeriod() time.Duration {
+	if r.RetryPeriod == 0 {
+		return defaulRetryPeriod
+	}
+	return r.RetryPeriod
+}
+
+// storesForSecret enqueues the SecretStores using a Secret as credentials
+func (r *SecretStoreReconciler) storesForSecret(o handler.MapObject) []reconcile.Request {
+	secretStores := &storev1alpha1.SecretStoreList{}
+	err := r.List(context.Background(), secretStores,
+		client.InNamespace(o.Meta.GetNamespace()),
+		client.MatchingFields{credentialsSecretField: o.Meta.GetName()})
 	if err != nil {
-		log.Error(err, "Backend initialization failed")
-		return ctrl.Result{}, err
+		r.Log.Error(err, "Failed to list SecretStores", "secret", o.Meta.GetName())
+		return nil
 	}
 
-	return ctrl.Result{}, nil
+	requests := []reconcile.Request{}
+	for _, secretStore := range secretStores.Items {
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{Name: secretStore.Name, Namespace: secretStore.Namespace},
+		})
+	}
+	return requests
 }
 
 func (r *SecretStoreReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	err := mgr.GetFieldIndexer().IndexField(context.Background(), &storev1alpha1.SecretStore{}, credentialsSecretField, func(o runtime.Object) []string {
+		name := store.CredentialsSecretName(o.(*storev1alpha1.SecretStore))
+		if name == "" {
+			return nil
+		}
+		return []string{name}
+	})
+	if err != nil {
+		return err
+	}
+
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&storev1alpha1.SecretStore{}).
+		For(&storev1alpha1.SecretStore{}, builder.WithPredicates(r.selectedPredicate())).
+		WithOptions(controller.Options{MaxConcurrentReconciles: r.MaxConcurrentReconciles}).
+		Watches(&source.Kind{Type: &corev1.Secret{}},
+			&handler.EnqueueRequestsFromMapFunc{ToRequests: handler.ToRequestsFunc(r.storesForSecret)}).
 		Complete(r)
 }
+
+// selectedPredicate filters out the events of the SecretStores r does not
+// select. Updates of SecretStores selected before the update are kept so that
+// their backend is removed.
+func (r *SecretStoreReconciler) selectedPredicate() predicate.Funcs {
+	selected := func(o runtime.Object) bool {
+		secretStore, ok := o.(*storev1alpha1.SecretStore)
+		return ok && r.selects(secretStore)
+	}
+	return predicate.Funcs{
+		CreateFunc:  func(e event.CreateEvent) bool { return selected(e.Object) },
+		UpdateFunc:  func(e event.UpdateEvent) bool { return selected(e.ObjectOld) || selected(e.ObjectNew) },
+		DeleteFunc:  func(e event.DeleteEvent) bool { return selected(e.Object) },
+		GenericFunc: func(e event.GenericEvent) bool { return selected(e.Object) },
+	}
+}
+
+// selects tells whether secretStore matches the Selector and the
+// ControllerClass of r
+func (r *SecretStoreReconciler) selects(secretStore *storev1alpha1.SecretStore) bool {
+	return store.Selected(secretStore, r.Selector, r.ControllerClass)
+}
@@ -4,8 +4,10 @@ import (
 	"context"
 	"time"
 
+	secretsv1alpha1 "github.com/containersolutions/externalsecret-operator/apis/secrets/v1alpha1"
 	storev1alpha1 "github.com/containersolutions/externalsecret-operator/apis/store/v1alpha1"
 	"github.com/containersolutions/externalsecret-operator/pkg/backend"
+	"github.com/containersolutions/externalsecret-operator/pkg/store"
 	"github.com/containersolutions/externalsecret-operator/pkg/utils"
 
 	. "github.com/onsi/ginkgo"
@@ -13,9 +15,13 @@ import (
 
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 )
 
 const SecretStoreNamespace = "default"
@@ -104,23 +110,74 @@ var _ = Describe("SecretstoreController", func() {
 			Expect(createdSecretStore.Spec.Controller).To(Equal(SecretStoreControllerName))
 
 			Eventually(func() bool {
-				_, found := backend.Instances[SecretStoreControllerName]
+				_, found := backends.Get(SecretStoreNamespace + "/" + SecretStoreName)
 
 				return found
 			}, timeout, interval).Should(BeTrue())
 
 			Eventually(func() string {
-				backend := backend.Instances[SecretStoreControllerName]
+				backend, _ := backends.Get(SecretStoreNamespace + "/" + SecretStoreName)
 				if backend == nil {
 					return ""
 				}
-				secretValue, err := backend.Get(KeyName, KeyVersion)
+				secretValue, err := backend.Get(ctx, KeyName, KeyVersion)
 				if err != nil {
 					return ""
 				}
-				return secretValue
+				return string(secretValue.Data)
 			}, timeout, interval).Should(Equal("test-store-secrettest-store-versionTestParameter"))
 
+			By("Checking the health of the backend")
+			Eventually(func() string {
+				ss := &storev1alpha1.SecretStore{}
+				if err := k8sClient.Get(ctx, secretStoreLookupKey, ss); err != nil {
+					return ""
+				}
+				condition := meta.FindStatusCondition(ss.Status.Conditions, storev1alpha1.SecretStoreReady)
+				if condition == nil || condition.Status != metav1.ConditionTrue {
+					return ""
+				}
+				return condition.Reason
+			}, timeout, interval).Should(Equal(storev1alpha1.ReasonHealthy))
+
+			By("Updating the credentials Secret")
+			initialized, _ := backends.Get(SecretStoreNamespace + "/" + SecretStoreName)
+			Eventually(func() error {
+				secret := &corev1.Secret{}
+				if err := k8sClient.Get(ctx, credentialsSecretLookupKey, secret); err != nil {
+					return err
+				}
+				secret.StringData = map[string]string{
+					"credentials.json": `{
+						"Credential": "-rotatedvalue"
+					}`,
+				}
+				return k8sClient.Update(ctx, secret)
+			}, timeout, interval).Should(Succeed())
+
+			Eventually(func() bool {
+				current, _ := backends.Get(SecretStoreNamespace + "/" + SecretStoreName)
+				return current != initialized
+			}, timeout, interval).Should(BeTrue())
+
+			externalSecret := &secretsv1alpha1.ExternalSecret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "externalsecret-of-deleted-store",
+					Namespace: SecretStoreNamespace,
+				},
+				Spec: secretsv1alpha1.ExternalSecretSpec{
+					StoreRef: secretsv1alpha1.ExternalSecretStoreRef{
+						Name: SecretStoreName,
+					},
+					Data: []secretsv1alpha1.ExternalSecretData{
+						{
+							Key: KeyName,
+						},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, externalSecret)).Should(Succeed())
+
 			By("Deleting the SecretStore")
 			Eventually(func() error {
 				ss := &storev1alpha1.SecretStore{}
@@ -132,6 +189,15 @@ var _ = Describe("SecretstoreController", func() {
 				ss := &storev1alpha1.SecretStore{}
 				return k8sClient.Get(context.Background(), secretStoreLookupKey, ss)
 			}, timeout, interval).ShouldNot(Succeed())
+
+			_, found := backends.Get(SecretStoreNamespace + "/" + SecretStoreName)
+			Expect(found).To(BeFalse())
+
+			es := &secretsv1alpha1.ExternalSecret{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: externalSecret.Name, Namespace: SecretStoreNamespace}, es)).Should(Succeed())
+			condition := meta.FindStatusCondition(es.Status.Conditions, secretsv1alpha1.ExternalSecretReady)
+			Expect(condition).ToNot(BeNil())
+			Expect(condition.Reason).To(Equal(secretsv1alpha1.ReasonStoreNotFound))
 		})
 	})
 
@@ -242,4 +308,86 @@ var _ = Describe("SecretstoreController", func() {
 			Expect(k8sClient.Create(ctx, secretStore)).Should(Succeed())
 		})
 	})
+
+	Context("When a selector is set", func() {
+		It("Should only select the SecretStores matching it", func() {
+			selector, err := labels.Parse("controller=team-a")
+			Expect(err).To(BeNil())
+
+			r := &SecretStoreReconciler{Selector: selector}
+			Expect(r.selects(&storev1alpha1.SecretStore{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"controller": "team-a"}}})).To(BeTrue())
+			Expect(r.selects(&storev1alpha1.SecretStore{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"controller": "team-b"}}})).To(BeFalse())
+			Expect(r.selects(&storev1alpha1.SecretStore{})).To(BeFalse())
+
+			r.Selector = nil
+			Expect(r.selects(&storev1alpha1.SecretStore{})).To(BeTrue())
+		})
+	})
+
+	Context("When a controller class is set", func() {
+		It("Should only select the SecretStores of its class", func() {
+			r := &SecretStoreReconciler{ControllerClass: "blue"}
+			Expect(r.selects(&storev1alpha1.SecretStore{Spec: storev1alpha1.SecretStoreSpec{Controller: "blue"}})).To(BeTrue())
+			Expect(r.selects(&storev1alpha1.SecretStore{Spec: storev1alpha1.SecretStoreSpec{Controller: "green"}})).To(BeFalse())
+
+			r.ControllerClass = ""
+			Expect(r.selects(&storev1alpha1.SecretStore{Spec: storev1alpha1.SecretStoreSpec{Controller: "green"}})).To(BeTrue())
+		})
+
+		It("Should remove the backend of a SecretStore moving to another class", func() {
+			ctx := context.Background()
+			classBackends := backend.NewRegistry()
+			r := &SecretStoreReconciler{
+				Client:          k8sClient,
+				Log:             ctrl.Log.WithName("test"),
+				Stores:          store.NewManager(k8sClient, ctrl.Log.WithName("test"), classBackends, nil),
+				ControllerClass: "blue",
+			}
+
+			secretStore := &storev1alpha1.SecretStore{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "class-blue-store",
+					Namespace: SecretStoreNamespace,
+				},
+				Spec: storev1alpha1.SecretStoreSpec{
+					Controller: "blue",
+					Store:      runtime.RawExtension{Raw: []byte(`{"type": "dummy", "parameters": {"Suffix": "blue"}}`)},
+				},
+			}
+			Expect(k8sClient.Create(ctx, secretStore)).Should(Succeed())
+			lookupKey := types.NamespacedName{Name: secretStore.Name, Namespace: SecretStoreNamespace}
+			req := ctrl.Request{NamespacedName: lookupKey}
+
+			Eventually(func() bool {
+				r.Reconcile(req)
+				_, found := classBackends.Get(store.BackendName(secretStore))
+				return found
+			}, timeout, interval).Should(BeTrue())
+
+			By("Moving the SecretStore to another class")
+			green := secretStore.DeepCopy()
+			green.Spec.Controller = "green"
+			Expect(r.selectedPredicate().Update(event.UpdateEvent{
+				MetaOld: secretStore, ObjectOld: secretStore,
+				MetaNew: green, ObjectNew: green,
+			})).To(BeTrue())
+
+			Eventually(func() error {
+				current := &storev1alpha1.SecretStore{}
+				if err := k8sClient.Get(ctx, lookupKey, current); err != nil {
+					return err
+				}
+				current.Spec.Controller = "green"
+				return k8sClient.Update(ctx, current)
+			}, timeout, interval).Should(Succeed())
+
+			Eventually(func() bool {
+				r.Reconcile(req)
+				_, found := classBackends.Get(store.BackendName(secretStore))
+				return found
+			}, timeout, interval).Should(BeFalse())
+
+			Expect(k8sClient.Delete(ctx, secretStore)).Should(Succeed())
+		})
+	})
 })
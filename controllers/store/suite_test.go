@@ -19,6 +19,7 @@ package controllers
 import (
 	"path/filepath"
 	"testing"
+	"time"
 
 	_ "github.com/containersolutions/externalsecret-operator/pkg/register"
 
@@ -33,7 +34,10 @@ import (
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 
+	secretsv1alpha1 "github.com/containersolutions/externalsecret-operator/apis/secrets/v1alpha1"
 	storev1alpha1 "github.com/containersolutions/externalsecret-operator/apis/store/v1alpha1"
+	"github.com/containersolutions/externalsecret-operator/pkg/backend"
+	"github.com/containersolutions/externalsecret-operator/pkg/store"
 	// +kubebuilder:scaffold:imports
 )
 
@@ -43,6 +47,7 @@ import (
 var cfg *rest.Config
 var k8sClient client.Client
 var testEnv *envtest.Environment
+var backends *backend.Registry
 
 func TestAPIs(t *testing.T) {
 	RegisterFailHandler(Fail)
@@ -77,6 +82,9 @@ var _ = BeforeSuite(func(done Done) {
 	err = storev1alpha1.AddToScheme(scheme.Scheme)
 	Expect(err).NotTo(HaveOccurred())
 
+	err = secretsv1alpha1.AddToScheme(scheme.Scheme)
+	Expect(err).NotTo(HaveOccurred())
+
 	// +kubebuilder:scaffold:scheme
 
 	k8sClient, err = client.New(cfg, client.Options{Scheme: scheme.Scheme})
@@ -89,10 +97,15 @@ var _ = BeforeSuite(func(done Done) {
 	})
 	Expect(err).ToNot(HaveOccurred())
 
+	backends = backend.NewRegistry()
+
 	err = (&SecretStoreReconciler{
 		Client: k8sManager.GetClient(),
 		Log:    ctrl.Log.WithName("controllers").WithName("SecretStore"),
 		Scheme: k8sManager.GetScheme(),
+		Stores: store.NewManager(k8sManager.GetClient(), ctrl.Log.WithName("store"), backends, nil),
+
+		HealthCheckInterval: time.Minute,
 	}).SetupWithManager(k8sManager)
 	Expect(err).ToNot(HaveOccurred())
 
@@ -6,6 +6,7 @@ require (
 	cloud.google.com/go v0.66.0
 	github.com/Azure/azure-sdk-for-go v48.2.0+incompatible
 	github.com/Azure/go-autorest/autorest/azure/auth v0.5.3 // indirect
+	github.com/Azure/go-autorest/autorest/date v0.3.0
 	github.com/Azure/go-autorest/autorest/to v0.4.0 // indirect
 	github.com/Azure/go-autorest/autorest/validation v0.3.0 // indirect
 	github.com/apex/log v1.9.0
@@ -15,11 +16,13 @@ require (
 	github.com/googleapis/gax-go v1.0.3
 	github.com/onsi/ginkgo v1.14.1
 	github.com/onsi/gomega v1.10.2
-	github.com/prometheus/common v0.13.0
+	github.com/prometheus/common v0.13.0 // indirect
 	github.com/smartystreets/goconvey v1.6.4
 	github.com/versent/unicreds v1.5.1-0.20180327234242-7135c859e003
 	github.com/xanzy/go-gitlab v0.39.0
+	golang.org/x/crypto v0.0.0-20201002170205-7f63de1d35b0
 	golang.org/x/oauth2 v0.0.0-20200902213428-5d25da1a8d43
+	golang.org/x/sync v0.0.0-20200625203802-6e8e738ad208
 	google.golang.org/api v0.32.0
 	google.golang.org/genproto v0.0.0-20200921165018-b9da36f5f452
 	google.golang.org/grpc v1.31.1
@@ -18,21 +18,33 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"os"
+	"strings"
+	"time"
 
 	_ "github.com/containersolutions/externalsecret-operator/pkg/register"
 
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
 
 	secretsv1alpha1 "github.com/containersolutions/externalsecret-operator/apis/secrets/v1alpha1"
 	storev1alpha1 "github.com/containersolutions/externalsecret-operator/apis/store/v1alpha1"
 	secretscontroller "github.com/containersolutions/externalsecret-operator/controllers/secrets"
 	storecontroller "github.com/containersolutions/externalsecret-operator/controllers/store"
+	"github.com/containersolutions/externalsecret-operator/pkg/backend"
+	"github.com/containersolutions/externalsecret-operator/pkg/logging"
+	"github.com/containersolutions/externalsecret-operator/pkg/notification"
+	"github.com/containersolutions/externalsecret-operator/pkg/store"
 	// +kubebuilder:scaffold:imports
 )
 
@@ -51,46 +63,213 @@ func init() {
 
 func main() {
 	var metricsAddr string
+	var probeAddr string
+	var notificationAddr string
 	var enableLeaderElection bool
+	var watchNamespaces string
+	var externalSecretConcurrency int
+	var secretStoreConcurrency int
+	var refreshInterval time.Duration
+	var retryPeriod time.Duration
+	var providerTimeout time.Duration
+	var providerTimeouts string
+	var healthCheckInterval time.Duration
+	var readinessRequireStores bool
+	var enableWebhook bool
+	var allowAmbientCredentials bool
+	var selector string
+	var controllerClass string
+	var leaderElectionID string
 	// var LeaderElectionID = "36af4962.externalsecret-operator.container-solutions.com"
+
+	// Flags registered by dependencies, e.g. -kubeconfig, are not read from the
+	// environment, KUBECONFIG in particular keeps its own meaning
+	dependencyFlags := map[string]bool{}
+	flag.VisitAll(func(f *flag.Flag) { dependencyFlags[f.Name] = true })
+
 	flag.StringVar(&metricsAddr, "metrics-addr", ":8080", "The address the metric endpoint binds to.")
+	flag.StringVar(&probeAddr, "health-probe-addr", ":8081", "The address the liveness and readiness probe endpoints bind to.")
+	flag.StringVar(&notificationAddr, "notification-addr", "",
+		"The address the provider change notification receiver binds to. "+
+			"Leave empty to disable it. It requires a shared token in the NOTIFICATION_TOKEN environment variable.")
 	flag.BoolVar(&enableLeaderElection, "enable-leader-election", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.StringVar(&leaderElectionID, "leader-election-id", "36af4962.externalsecret-operator.container-solutions.com",
+		"The name of the leader election lock, distinct for each operator instance running in the same namespace.")
+	flag.StringVar(&watchNamespaces, "watch-namespaces", "",
+		"Comma separated list of the namespaces watched by the operator. Leave empty to watch all namespaces.")
+	flag.StringVar(&selector, "selector", "",
+		"Label selector restricting the reconciled SecretStores and ExternalSecrets, e.g. controller=team-a, "+
+			"so that several operator instances can share namespaces. Leave empty to reconcile all of them.")
+	flag.StringVar(&controllerClass, "controller-class", "",
+		"Controller class of the reconciled SecretStores, matched against their spec.controller, and of the ExternalSecrets using them. "+
+			"Leave empty to reconcile all of them.")
+	flag.IntVar(&externalSecretConcurrency, "externalsecret-max-concurrent-reconciles", 1,
+		"The number of ExternalSecrets reconciled in parallel.")
+	flag.IntVar(&secretStoreConcurrency, "secretstore-max-concurrent-reconciles", 1,
+		"The number of SecretStores reconciled in parallel.")
+	flag.DurationVar(&refreshInterval, "default-refresh-interval", time.Hour,
+		"The refresh interval of ExternalSecrets that do not set one.")
+	flag.DurationVar(&retryPeriod, "retry-period", 30*time.Second,
+		"The delay before retrying a failed reconcile.")
+	flag.DurationVar(&providerTimeout, "provider-timeout", 30*time.Second,
+		"The timeout of the requests made to providers, 0 disables it.")
+	flag.StringVar(&providerTimeouts, "provider-timeouts", "",
+		"Comma separated list of timeouts overriding -provider-timeout per backend type, e.g. asm=10s,gsm=5s.")
+	flag.DurationVar(&healthCheckInterval, "store-health-check-interval", 5*time.Minute,
+		"The period of the SecretStore backend health checks, 0 disables them.")
+	flag.BoolVar(&readinessRequireStores, "readiness-require-stores", false,
+		"Report the operator ready only once every SecretStore backend is initialized and healthy.")
+	flag.BoolVar(&enableWebhook, "enable-webhook", false,
+		"Serve the ExternalSecret validating webhook enforcing SecretStore policies. "+
+			"It requires a serving certificate in /tmp/k8s-webhook-server/serving-certs.")
+	flag.BoolVar(&allowAmbientCredentials, "allow-ambient-credentials", false,
+		"Allow SecretStores without credentials to use the credentials of the operator, "+
+			"e.g. its IAM role or its Google service account. Any SecretStore can then read what the operator can.")
+
+	// The flags above can also be set from the environment, command line
+	// arguments take precedence. The logging flags bound below cannot.
+	if err := setFlagsFromEnv(flag.CommandLine, dependencyFlags); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	logOpts := zap.Options{}
+	logOpts.BindFlags(flag.CommandLine)
 	flag.Parse()
 
-	ctrl.SetLogger(zap.New(zap.UseDevMode(true)))
+	ctrl.SetLogger(logging.New(&logOpts))
 
-	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
-		Scheme:             scheme,
-		MetricsBindAddress: metricsAddr,
-		Port:               9443,
-		LeaderElection:     enableLeaderElection,
-		LeaderElectionID:   "36af4962.externalsecret-operator.container-solutions.com",
-	})
+	timeouts, err := backend.ParseTimeouts(providerTimeouts)
+	if err != nil {
+		setupLog.Error(err, "invalid -provider-timeouts")
+		os.Exit(1)
+	}
+
+	var labelSelector labels.Selector
+	if selector != "" {
+		labelSelector, err = labels.Parse(selector)
+		if err != nil {
+			setupLog.Error(err, "invalid -selector")
+			os.Exit(1)
+		}
+		setupLog.Info("reconciling selected objects", "selector", labelSelector.String())
+	}
+	if controllerClass != "" {
+		setupLog.Info("reconciling controller class", "class", controllerClass)
+	}
+
+	options := ctrl.Options{
+		Scheme:                 scheme,
+		MetricsBindAddress:     metricsAddr,
+		HealthProbeBindAddress: probeAddr,
+		Port:                   9443,
+		LeaderElection:         enableLeaderElection,
+		LeaderElectionID:       leaderElectionID,
+	}
+
+	namespaces := splitList(watchNamespaces)
+	switch len(namespaces) {
+	case 0:
+	case 1:
+		options.Namespace = namespaces[0]
+	default:
+		options.NewCache = cache.MultiNamespacedCacheBuilder(namespaces)
+	}
+	if len(namespaces) > 0 {
+		setupLog.Info("watching namespaces", "namespaces", namespaces)
+	}
+
+	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), options)
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
 		os.Exit(1)
 	}
 
+	backendCache := backend.NewCache(backend.DefaultCacheTTL, backend.DefaultCacheSize)
+
+	var notificationEvents chan event.GenericEvent
+	if notificationAddr != "" {
+		if os.Getenv("NOTIFICATION_TOKEN") == "" {
+			setupLog.Error(fmt.Errorf("NOTIFICATION_TOKEN is not set"), "the notification receiver needs a token")
+			os.Exit(1)
+		}
+		notificationEvents = make(chan event.GenericEvent)
+		if err = mgr.Add(&notification.Receiver{
+			Client: mgr.GetClient(),
+			Log:    ctrl.Log.WithName("notification"),
+			Addr:   notificationAddr,
+			Token:  os.Getenv("NOTIFICATION_TOKEN"),
+			Cache:  backendCache,
+			Events: notificationEvents,
+		}); err != nil {
+			setupLog.Error(err, "unable to add notification receiver")
+			os.Exit(1)
+		}
+	}
+
+	backends := backend.NewRegistry()
+	backends.SetTimeouts(backend.Timeouts{Default: providerTimeout, Types: timeouts})
+	backends.SetAmbientCredentials(allowAmbientCredentials)
+	stores := store.NewManager(mgr.GetClient(), ctrl.Log.WithName("store"), backends, backendCache)
+	stores.Select(labelSelector, controllerClass)
+	stores.SetElected(mgr.Elected())
+
+	if err = mgr.AddHealthzCheck("ping", healthz.Ping); err != nil {
+		setupLog.Error(err, "unable to add health check")
+		os.Exit(1)
+	}
+	readyzName, readyzCheck := "ping", healthz.Ping
+	if readinessRequireStores {
+		readyzName, readyzCheck = "stores", stores.ReadyzCheck
+	}
+	if err = mgr.AddReadyzCheck(readyzName, readyzCheck); err != nil {
+		setupLog.Error(err, "unable to add readiness check")
+		os.Exit(1)
+	}
+
 	if err = (&storecontroller.SecretStoreReconciler{
 		Client: mgr.GetClient(),
 		Log:    ctrl.Log.WithName("controllers").WithName("SecretStore"),
 		Scheme: mgr.GetScheme(),
+		Stores: stores,
+
+		RetryPeriod:             retryPeriod,
+		HealthCheckInterval:     healthCheckInterval,
+		MaxConcurrentReconciles: secretStoreConcurrency,
+		Selector:                labelSelector,
+		ControllerClass:         controllerClass,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "SecretStore")
 		os.Exit(1)
 	}
 
 	if err = (&secretscontroller.ExternalSecretReconciler{
-		Client: mgr.GetClient(),
-		Log:    ctrl.Log.WithName("controllers").WithName("ExternalSecret"),
-		Scheme: mgr.GetScheme(),
+		Client:   mgr.GetClient(),
+		Log:      ctrl.Log.WithName("controllers").WithName("ExternalSecret"),
+		Scheme:   mgr.GetScheme(),
+		Cache:    backendCache,
+		Backends: backends,
+		Stores:   stores,
+		Events:   notificationEvents,
+
+		RetryPeriod:             retryPeriod,
+		RefreshInterval:         refreshInterval,
+		MaxConcurrentReconciles: externalSecretConcurrency,
+		Selector:                labelSelector,
+		ControllerClass:         controllerClass,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "ExternalSecret")
 		os.Exit(1)
 	}
 
+	if enableWebhook {
+		mgr.GetWebhookServer().Register(secretscontroller.ValidatePath, &webhook.Admission{
+			Handler: &secretscontroller.ExternalSecretValidator{Client: mgr.GetClient()},
+		})
+	}
+
 	// +kubebuilder:scaffold:builder
 
 	setupLog.Info("starting manager")
@@ -99,3 +278,34 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// setFlagsFromEnv sets the flags of fs, except the ignored ones, from the
+// environment variable named after them, e.g. -retry-period from RETRY_PERIOD
+func setFlagsFromEnv(fs *flag.FlagSet, ignored map[string]bool) error {
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		if ignored[f.Name] {
+			return
+		}
+		name := strings.ToUpper(strings.ReplaceAll(f.Name, "-", "_"))
+		value, found := os.LookupEnv(name)
+		if !found || err != nil {
+			return
+		}
+		if setErr := fs.Set(f.Name, value); setErr != nil {
+			err = fmt.Errorf("invalid value %q for %s: %w", value, name, setErr)
+		}
+	})
+	return err
+}
+
+// splitList splits a comma separated list, ignoring empty items
+func splitList(s string) []string {
+	items := []string{}
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -1,13 +1,23 @@
-// Package akv implements backend for Azure Key Vault secrets
+// Package akv implements backend for Azure Key Vault secrets, certificates
+// and keys
 package akv
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
 	"encoding/json"
+	"encoding/pem"
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"math/big"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/Azure/azure-sdk-for-go/profiles/latest/keyvault/keyvault"
 	kvauth "github.com/Azure/azure-sdk-for-go/services/keyvault/auth"
@@ -15,10 +25,28 @@ import (
 	ctrl "sigs.k8s.io/controller-runtime"
 )
 
+const (
+	// PrefixSecret selects a Key Vault secret, the default for keys without prefix
+	PrefixSecret = "secret/"
+	// PrefixCertificate selects a Key Vault certificate along with its private key
+	PrefixCertificate = "cert/"
+	// PrefixKey selects the public part of a Key Vault key
+	PrefixKey = "key/"
+
+	// PropertyPFX holds the PKCS#12 archive of a certificate stored as PFX
+	PropertyPFX = "pfx"
+
+	contentTypePFX = "application/x-pkcs12"
+)
+
 var log = ctrl.Log.WithName("akv")
 
 type ClientInterface interface {
 	GetSecret(context context.Context, url string, key string, version string) (keyvault.SecretBundle, error)
+	GetSecrets(context context.Context, url string, maxresults *int32) (keyvault.SecretListResultPage, error)
+	GetSecretVersions(context context.Context, url string, key string, maxresults *int32) (keyvault.SecretListResultPage, error)
+	GetCertificate(context context.Context, url string, name string, version string) (keyvault.CertificateBundle, error)
+	GetKey(context context.Context, url string, name string, version string) (keyvault.KeyBundle, error)
 }
 
 // Backend represents a backend for Azure Key Vault
@@ -37,7 +65,7 @@ func init() {
 }
 
 // Init initializes the Backend for Azure Key Vault
-func (a *Backend) Init(parameters map[string]interface{}, credentials []byte) error {
+func (a *Backend) Init(ctx context.Context, parameters map[string]interface{}, credentials []byte) error {
 
 	akvCred := AzureCredentials{}
 	err := json.Unmarshal(credentials, &akvCred)
@@ -77,22 +105,200 @@ func (a *Backend) Init(parameters map[string]interface{}, credentials []byte) er
 	return nil
 }
 
-// Get retrieves the secret associated with key from Azure Key Vault
-func (a *Backend) Get(key string, version string) (string, error) {
+// Get retrieves the secret, certificate or key associated with key from
+// Azure Key Vault, depending on its prefix
+func (a *Backend) Get(ctx context.Context, key string, version string) (*backend.Value, error) {
 
 	if a.Client == nil {
-		return "", errors.New("Azure Key Vault backend not initialized")
+		return nil, errors.New("Azure Key Vault backend not initialized")
+	}
+
+	parsed, err := backend.ParseVersion(version)
+	if err != nil {
+		return nil, err
+	}
+	if parsed.Kind != backend.VersionKindLatest && parsed.Kind != backend.VersionKindID {
+		return nil, fmt.Errorf("version %q: %s versions are not supported by Azure Key Vault", version, parsed.Kind)
 	}
 
-	secretResp, err := a.Client.GetSecret(context.Background(), fmt.Sprintf("https://%s.vault.azure.net", a.keyvault), key, version)
+	// The empty version is the latest one
+	var value *backend.Value
+	switch {
+	case strings.HasPrefix(key, PrefixCertificate):
+		value, err = a.getCertificate(ctx, strings.TrimPrefix(key, PrefixCertificate), parsed.Value)
+	case strings.HasPrefix(key, PrefixKey):
+		value, err = a.getKey(ctx, strings.TrimPrefix(key, PrefixKey), parsed.Value)
+	default:
+		value, err = a.getSecret(ctx, strings.TrimPrefix(key, PrefixSecret), parsed.Value)
+	}
 	if err != nil {
 		log.Error(err, "")
-		return "", err
+		return nil, err
 	}
 
 	log.Info("Get secret succeeded")
 
-	return *secretResp.Value, nil
+	return value, nil
+}
+
+func (a *Backend) getSecret(ctx context.Context, name string, version string) (*backend.Value, error) {
+	secretResp, err := a.Client.GetSecret(ctx, a.vaultURL(), name, version)
+	if err != nil {
+		return nil, err
+	}
+	if secretResp.Value == nil {
+		return nil, fmt.Errorf("secret %s has no value", name)
+	}
+
+	return backend.NewValue([]byte(*secretResp.Value), secretVersion(secretResp.ID)), nil
+}
+
+// getCertificate reads the secret backing a certificate, which holds the
+// certificate chain and the private key as PEM or PFX
+func (a *Backend) getCertificate(ctx context.Context, name string, version string) (*backend.Value, error) {
+	certResp, err := a.Client.GetCertificate(ctx, a.vaultURL(), name, version)
+	if err != nil {
+		return nil, err
+	}
+
+	secretResp, err := a.Client.GetSecret(ctx, a.vaultURL(), name, secretVersion(certResp.Sid))
+	if err != nil {
+		return nil, err
+	}
+	if secretResp.Value == nil {
+		return nil, fmt.Errorf("certificate %s has no value", name)
+	}
+
+	var leaf []byte
+	if certResp.Cer != nil {
+		leaf = *certResp.Cer
+	}
+
+	var certs, key []byte
+	properties := map[string][]byte{}
+	if secretResp.ContentType != nil && *secretResp.ContentType == contentTypePFX {
+		pfx, err := base64.StdEncoding.DecodeString(*secretResp.Value)
+		if err != nil {
+			return nil, fmt.Errorf("decoding PFX of certificate %s: %w", name, err)
+		}
+		certs, key, err = backend.PKCS12ToPEM(pfx, leaf)
+		if err != nil {
+			return nil, fmt.Errorf("decoding PFX of certificate %s: %w", name, err)
+		}
+		properties[PropertyPFX] = pfx
+	} else {
+		var blocks []*pem.Block
+		rest := []byte(*secretResp.Value)
+		for {
+			var block *pem.Block
+			block, rest = pem.Decode(rest)
+			if block == nil {
+				break
+			}
+			blocks = append(blocks, block)
+		}
+		certs, key = backend.SplitPEM(blocks, leaf)
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("certificate %s has no PEM certificate", name)
+	}
+
+	properties[backend.PropertyCertificate] = certs
+	if len(key) > 0 {
+		properties[backend.PropertyPrivateKey] = key
+	}
+
+	value := backend.NewValue(append(append([]byte{}, certs...), key...), secretVersion(certResp.ID))
+	value.Properties = properties
+	return value, nil
+}
+
+// getKey returns the public part of a key as a PEM encoded PKIX public key
+func (a *Backend) getKey(ctx context.Context, name string, version string) (*backend.Value, error) {
+	keyResp, err := a.Client.GetKey(ctx, a.vaultURL(), name, version)
+	if err != nil {
+		return nil, err
+	}
+	if keyResp.Key == nil {
+		return nil, fmt.Errorf("key %s has no value", name)
+	}
+
+	publicKey, err := jwkPublicKey(keyResp.Key)
+	if err != nil {
+		return nil, fmt.Errorf("key %s: %w", name, err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(publicKey)
+	if err != nil {
+		return nil, fmt.Errorf("key %s: %w", name, err)
+	}
+
+	return backend.NewValue(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), secretVersion(keyResp.Key.Kid)), nil
+}
+
+// GetPrevious retrieves the most recent enabled version of key created before
+// current. Certificates share their versions with their backing secret.
+func (a *Backend) GetPrevious(ctx context.Context, key string, current *backend.Value) (*backend.Value, error) {
+	if a.Client == nil {
+		return nil, errors.New("Azure Key Vault backend not initialized")
+	}
+	if strings.HasPrefix(key, PrefixKey) {
+		return nil, fmt.Errorf("previous versions of keys are not supported by Azure Key Vault backend")
+	}
+
+	name := strings.TrimPrefix(strings.TrimPrefix(key, PrefixCertificate), PrefixSecret)
+	page, err := a.Client.GetSecretVersions(ctx, a.vaultURL(), name, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []keyvault.SecretItem
+	for page.NotDone() {
+		versions = append(versions, page.Values()...)
+		if err := page.NextWithContext(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	currentVersion := current.Metadata[backend.MetadataVersion]
+	var currentCreated *time.Time
+	for _, version := range versions {
+		if secretVersion(version.ID) == currentVersion && version.Attributes != nil && version.Attributes.Created != nil {
+			created := time.Time(*version.Attributes.Created)
+			currentCreated = &created
+		}
+	}
+	if currentCreated == nil {
+		return nil, fmt.Errorf("version %s of %s not found", currentVersion, key)
+	}
+
+	var previous *keyvault.SecretItem
+	var previousCreated time.Time
+	for i, version := range versions {
+		attributes := version.Attributes
+		if attributes == nil || attributes.Created == nil || (attributes.Enabled != nil && !*attributes.Enabled) {
+			continue
+		}
+		created := time.Time(*attributes.Created)
+		if created.Before(*currentCreated) && (previous == nil || created.After(previousCreated)) {
+			previous, previousCreated = &versions[i], created
+		}
+	}
+	if previous == nil {
+		return nil, nil
+	}
+
+	return a.Get(ctx, key, secretVersion(previous.ID))
+}
+
+// HealthCheck lists at most one secret to check the Azure Key Vault is reachable
+func (a *Backend) HealthCheck(ctx context.Context) error {
+	if a.Client == nil {
+		return errors.New("Azure Key Vault backend not initialized")
+	}
+
+	maxResults := int32(1)
+	_, err := a.Client.GetSecrets(ctx, a.vaultURL(), &maxResults)
+	return err
 }
 
 // AzureCredentials represents expected credentials
@@ -102,3 +308,72 @@ type AzureCredentials struct {
 	ClientSecret string `json:"clientSecret"`
 	Keyvault     string `json:"keyvault"`
 }
+
+// secretVersion returns the version from a secret identifier
+// https://{vault}.vault.azure.net/secrets/{name}/{version}
+func secretVersion(id *string) string {
+	if id == nil {
+		return ""
+	}
+	parts := strings.Split(*id, "/")
+	if len(parts) < 6 {
+		return ""
+	}
+	return parts[5]
+}
+
+// vaultURL returns the base URL of the Key Vault
+func (a *Backend) vaultURL() string {
+	return fmt.Sprintf("https://%s.vault.azure.net", a.keyvault)
+}
+
+// jwkPublicKey returns the public key of an RSA or EC JSON web key
+func jwkPublicKey(jwk *keyvault.JSONWebKey) (interface{}, error) {
+	switch jwk.Kty {
+	case keyvault.RSA, keyvault.RSAHSM:
+		n, err := jwkInt(jwk.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := jwkInt(jwk.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case keyvault.EC, keyvault.ECHSM:
+		var curve elliptic.Curve
+		switch jwk.Crv {
+		case keyvault.P256:
+			curve = elliptic.P256()
+		case keyvault.P384:
+			curve = elliptic.P384()
+		case keyvault.P521:
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", jwk.Crv)
+		}
+		x, err := jwkInt(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := jwkInt(jwk.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", jwk.Kty)
+	}
+}
+
+// jwkInt decodes a base64url encoded JSON web key parameter
+func jwkInt(value *string) (*big.Int, error) {
+	if value == nil {
+		return nil, errors.New("missing key parameter")
+	}
+	decoded, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(*value, "="))
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(decoded), nil
+}
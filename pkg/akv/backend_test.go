@@ -1,18 +1,36 @@
 package akv
 
 import (
+	"bytes"
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/Azure/azure-sdk-for-go/profiles/latest/keyvault/keyvault"
+	"github.com/Azure/go-autorest/autorest/date"
+
+	backendpkg "github.com/containersolutions/externalsecret-operator/pkg/backend"
 )
 
 type mockedClient struct {
+	secrets      map[string]keyvault.SecretBundle
+	certificates map[string]keyvault.CertificateBundle
+	keys         map[string]keyvault.KeyBundle
 }
 
 func TestNewBackend(t *testing.T) {
 	backend := Backend{}
-	_, err := backend.Get("hello", "")
+	_, err := backend.Get(context.Background(), "hello", "")
 
 	if err == nil {
 		t.Errorf("There should have been an error because the backend has not been initialized")
@@ -44,18 +62,251 @@ func TestGetSecret(t *testing.T) {
 	for _, tt := range flagtests {
 		t.Run(tt.in, func(t *testing.T) {
 
-			result, err := backend.Get(tt.in, "")
+			result, err := backend.Get(context.Background(), tt.in, "")
 
 			if err != nil {
 				t.Error(err)
-			} else if (result == tt.out) != tt.pass {
-				t.Errorf("Expected: %s, got: %s", tt.out, result)
+			} else if (string(result.Data) == tt.out) != tt.pass {
+				t.Errorf("Expected: %s, got: %s", tt.out, result.Data)
 			}
 		})
 	}
 }
 
 func (m *mockedClient) GetSecret(context context.Context, url string, key string, version string) (keyvault.SecretBundle, error) {
+	if secret, ok := m.secrets[key+"/"+version]; ok {
+		return secret, nil
+	}
+	id := fmt.Sprintf("%s/secrets/%s/%s", url, key, version)
+	return keyvault.SecretBundle{Value: &key, ID: &id}, nil
+}
+
+func (m *mockedClient) GetSecretVersions(ctx context.Context, url string, key string, maxresults *int32) (keyvault.SecretListResultPage, error) {
+	item := func(version string, created int64, enabled bool) keyvault.SecretItem {
+		id := fmt.Sprintf("%s/secrets/%s/%s", url, key, version)
+		createdAt := date.UnixTime(time.Unix(created, 0))
+		return keyvault.SecretItem{ID: &id, Attributes: &keyvault.SecretAttributes{Created: &createdAt, Enabled: &enabled}}
+	}
+	versions := []keyvault.SecretItem{
+		item("v3", 300, true),
+		item("v1", 100, true),
+		item("v2", 200, false),
+		item("v4", 400, true),
+	}
+
+	page := keyvault.NewSecretListResultPage(func(ctx context.Context, current keyvault.SecretListResult) (keyvault.SecretListResult, error) {
+		if current.Value == nil {
+			return keyvault.SecretListResult{Value: &versions}, nil
+		}
+		return keyvault.SecretListResult{}, nil
+	})
+	return page, page.NextWithContext(ctx)
+}
+
+func (m *mockedClient) GetSecrets(context context.Context, url string, maxresults *int32) (keyvault.SecretListResultPage, error) {
+	return keyvault.SecretListResultPage{}, nil
+}
 
-	return keyvault.SecretBundle{Value: &key}, nil
+func (m *mockedClient) GetCertificate(context context.Context, url string, name string, version string) (keyvault.CertificateBundle, error) {
+	certificate, ok := m.certificates[name]
+	if !ok {
+		return keyvault.CertificateBundle{}, fmt.Errorf("certificate %s not found", name)
+	}
+	return certificate, nil
+}
+
+func (m *mockedClient) GetKey(context context.Context, url string, name string, version string) (keyvault.KeyBundle, error) {
+	key, ok := m.keys[name]
+	if !ok {
+		return keyvault.KeyBundle{}, fmt.Errorf("key %s not found", name)
+	}
+	return key, nil
+}
+
+// Windows Azure Tools certificate with an empty password, from the
+// golang.org/x/crypto/pkcs12 tests
+const testPFX = `MIIKDAIBAzCCCcwGCSqGSIb3DQEHAaCCCb0Eggm5MIIJtTCCBe4GCSqGSIb3DQEHAaCCBd8EggXbMIIF1zCCBdMGCyqGSIb3DQEMCgECoIIE7jCCBOowHAYKKoZIhvcNAQwBAzAOBAhStUNnlTGV+gICB9AEggTIJ81JIossF6boFWpPtkiQRPtI6DW6e9QD4/WvHAVrM2bKdpMzSMsCML5NyuddANTKHBVq00Jc9keqGNAqJPKkjhSUebzQFyhe0E1oI9T4zY5UKr/I8JclOeccH4QQnsySzYUG2SnniXnQ+JrG3juetli7EKth9h6jLc6xbubPadY5HMB3wL/eG/kJymiXwU2KQ9Mgd4X6jbcV+NNCE/8jbZHvSTCPeYTJIjxfeX61Sj5kFKUCzERbsnpyevhY3X0eYtEDezZQarvGmXtMMdzf8HJHkWRdk9VLDLgjk8uiJif/+X4FohZ37ig0CpgC2+dP4DGugaZZ51hb8tN9GeCKIsrmWogMXDIVd0OACBp/EjJVmFB6y0kUCXxUE0TZt0XA1tjAGJcjDUpBvTntZjPsnH/4ZySy+s2d9OOhJ6pzRQBRm360TzkFdSwk9DLiLdGfv4pwMMu/vNGBlqjP/1sQtj+jprJiD1sDbCl4AdQZVoMBQHadF2uSD4/o17XG/Ci0r2h6Htc2yvZMAbEY4zMjjIn2a+vqIxD6onexaek1R3zbkS9j19D6EN9EWn8xgz80YRCyW65znZk8xaIhhvlU/mg7sTxeyuqroBZNcq6uDaQTehDpyH7bY2l4zWRpoj10a6JfH2q5shYz8Y6UZC/kOTfuGqbZDNZWro/9pYquvNNW0M847E5t9bsf9VkAAMHRGBbWoVoU9VpI0UnoXSfvpOo+aXa2DSq5sHHUTVY7A9eov3z5IqT+pligx11xcs+YhDWcU8di3BTJisohKvv5Y8WSkm/rloiZd4ig269k0jTRk1olP/vCksPli4wKG2wdsd5o42nX1yL7mFfXocOANZbB+5qMkiwdyoQSk+Vq+C8nAZx2bbKhUq2MbrORGMzOe0Hh0x2a0PeObycN1Bpyv7Mp3ZI9h5hBnONKCnqMhtyQHUj/nNvbJUnDVYNfoOEqDiEqqEwB7YqWzAKz8KW0OIqdlM8uiQ4JqZZlFllnWJUfaiDrdFM3lYSnFQBkzeVlts6GpDOOBjCYd7dcCNS6kq6pZC6p6HN60Twu0JnurZD6RT7rrPkIGE8vAenFt4iGe/yF52fahCSY8Ws4K0UTwN7bAS+4xRHVCWvE8sMRZsRCHizb5laYsVrPZJhE6+hux6OBb6w8kwPYXc+ud5v6UxawUWgt6uPwl8mlAtU9Z7Miw4Nn/wtBkiLL/ke1UI1gqJtcQXgHxx6mzsjh41+nAgTvdbsSEyU6vfOmxGj3Rwc1eOrIhJUqn5YjOWfzzsz/D5DzWKmwXIwdspt1p+u+kol1N3f2wT9fKPnd/RGCb4g/1hc3Aju4DQYgGY782l89CEEdalpQ/35bQczMFk6Fje12HykakWEXd/bGm9Unh82gH84USiRpeOfQvBDYoqEyrY3zkFZzBjhDqa+jEcAj41tcGx47oSfDq3iVYCdL7HSIjtnyEktVXd7mISZLoMt20JACFcMw+mrbjlug+eU7o2GR7T+LwtOp/p4LZqyLa7oQJDwde1BNZtm3TCK2P1mW94QDL0nDUps5KLtr1DaZXEkRbjSJub2ZE9WqDHyU3KA8G84Tq/rN1IoNu/if45jacyPje1Npj9IftUZSP22nV7HMwZtwQ4P4MYHRMBMGCSqGSIb3DQEJFTEGBAQBAAAAMFsGCSqGSIb3DQEJFDFOHkwAewBCADQAQQA0AEYARQBCADAALQBBADEAOABBAC0ANAA0AEIAQgAtAEIANQBGADIALQA0ADkAMQBFAEYAMQA1ADIAQgBBADEANgB9MF0GCSsGAQQBgjcRATFQHk4ATQBpAGMAcgBvAHMAbwBmAHQAIABTAG8AZgB0AHcAYQByAGUAIABLAGUAeQAgAFMAdABvAHIAYQBnAGUAIABQAHIAbwB2AGkAZABlAHIwggO/BgkqhkiG9w0BBwagggOwMIIDrAIBADCCA6UGCSqGSIb3DQEHATAcBgoqhkiG9w0BDAEGMA4ECEBk5ZAYpu0WAgIH0ICCA3hik4mQFGpw9Ha8TQPtk+j2jwWdxfF0+sTk6S8PTsEfIhB7wPltjiCK92Uv2tCBQnodBUmatIfkpnRDEySmgmdglmOCzj204lWAMRs94PoALGn3JVBXbO1vIDCbAPOZ7Z0Hd0/1t2hmk8v3//QJGUg+qr59/4y/MuVfIg4qfkPcC2QSvYWcK3oTf6SFi5rv9B1IOWFgN5D0+C+x/9Lb/myPYX+rbOHrwtJ4W1fWKoz9g7wwmGFA9IJ2DYGuH8ifVFbDFT1Vcgsvs8arSX7oBsJVW0qrP7XkuDRe3EqCmKW7rBEwYrFznhxZcRDEpMwbFoSvgSIZ4XhFY9VKYglT+JpNH5iDceYEBOQL4vBLpxNUk3l5jKaBNxVa14AIBxq18bVHJ+STInhLhad4u10v/Xbx7wIL3f9DX1yLAkPrpBYbNHS2/ew6H/ySDJnoIDxkw2zZ4qJ+qUJZ1S0lbZVG+VT0OP5uF6tyOSpbMlcGkdl3z254n6MlCrTifcwkzscysDsgKXaYQw06rzrPW6RDub+t+hXzGny799fS9jhQMLDmOggaQ7+LA4oEZsfT89HLMWxJYDqjo3gIfjciV2mV54R684qLDS+AO09U49e6yEbwGlq8lpmO/pbXCbpGbB1b3EomcQbxdWxW2WEkkEd/VBn81K4M3obmywwXJkw+tPXDXfBmzzaqqCR+onMQ5ME1nMkY8ybnfoCc1bDIupjVWsEL2Wvq752RgI6KqzVNr1ew1IdqV5AWN2fOfek+0vi3Jd9FHF3hx8JMwjJL9dZsETV5kHtYJtE7wJ23J68BnCt2eI0GEuwXcCf5EdSKN/xXCTlIokc4Qk/gzRdIZsvcEJ6B1lGovKG54X4IohikqTjiepjbsMWj38yxDmK3mtENZ9ci8FPfbbvIEcOCZIinuY3qFUlRSbx7VUerEoV1IP3clUwexVQo4lHFee2jd7ocWsdSqSapW7OWUupBtDzRkqVhE7tGria+i1W2d6YLlJ21QTjyapWJehAMO637OdbJCCzDs1cXbodRRE7bsP492ocJy8OX66rKdhYbg8srSFNKdb3pF3UDNbN9jhI/t8iagRhNBhlQtTr1me2E/c86Q18qcRXl4bcXTt6acgCeffK6Y26LcVlrgjlD33AEYRRUeyC+rpxbT0aMjdFderlndKRIyG23mSp0HaUwNzAfMAcGBSsOAwIaBBRlviCbIyRrhIysg2dc/KbLFTc2vQQUg4rfwHMM4IKYRD/fsd1x6dda+wQ=`
+
+func newTestCertificate(t *testing.T, name string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  parent == nil,
+		BasicConstraintsValid: true,
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return certificate, key, der
+}
+
+func TestGetCertificate(t *testing.T) {
+	ca, caKey, caDER := newTestCertificate(t, "ca", nil, nil)
+	_, leafKey, leafDER := newTestCertificate(t, "leaf", ca, caKey)
+	leafKeyDER, err := x509.MarshalPKCS8PrivateKey(leafKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER})
+	leafPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: leafDER})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: leafKeyDER})
+
+	pfx, err := base64.StdEncoding.DecodeString(testPFX)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pemID := "https://vault.vault.azure.net/certificates/pem/v1"
+	pemSid := "https://vault.vault.azure.net/secrets/pem/v1"
+	pfxID := "https://vault.vault.azure.net/certificates/pfx/v2"
+	pfxSid := "https://vault.vault.azure.net/secrets/pfx/v2"
+	// Key Vault returns the key first and the chain in any order
+	pemValue := string(keyPEM) + string(caPEM) + string(leafPEM)
+	pemType := "application/x-pem-file"
+	pfxValue := testPFX
+	pfxType := contentTypePFX
+
+	backend := Backend{keyvault: "vault"}
+	backend.Client = &mockedClient{
+		secrets: map[string]keyvault.SecretBundle{
+			"pem/v1": {ID: &pemSid, Value: &pemValue, ContentType: &pemType},
+			"pfx/v2": {ID: &pfxSid, Value: &pfxValue, ContentType: &pfxType},
+		},
+		certificates: map[string]keyvault.CertificateBundle{
+			"pem": {ID: &pemID, Sid: &pemSid, Cer: &leafDER},
+			"pfx": {ID: &pfxID, Sid: &pfxSid},
+		},
+	}
+
+	value, err := backend.Get(context.Background(), "cert/pem", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if crt := value.Properties[backendpkg.PropertyCertificate]; !bytes.Equal(crt, append(append([]byte{}, leafPEM...), caPEM...)) {
+		t.Errorf("Expected the leaf certificate first, got: %s", crt)
+	}
+	if key := value.Properties[backendpkg.PropertyPrivateKey]; !bytes.Equal(key, keyPEM) {
+		t.Errorf("Expected: %s, got: %s", keyPEM, key)
+	}
+	if _, ok := value.Properties[PropertyPFX]; ok {
+		t.Errorf("Expected no PFX property for a PEM certificate")
+	}
+	if version := value.Metadata[backendpkg.MetadataVersion]; version != "v1" {
+		t.Errorf("Expected: v1, got: %s", version)
+	}
+
+	value, err = backend.Get(context.Background(), "cert/pfx", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(value.Properties[PropertyPFX], pfx) {
+		t.Errorf("Expected the PFX archive as property")
+	}
+	block, _ := pem.Decode(value.Properties[backendpkg.PropertyCertificate])
+	if block == nil || block.Type != "CERTIFICATE" || len(block.Headers) != 0 {
+		t.Errorf("Expected a PEM certificate without headers, got: %s", value.Properties[backendpkg.PropertyCertificate])
+	}
+	if key := value.Properties[backendpkg.PropertyPrivateKey]; !strings.Contains(string(key), "PRIVATE KEY") {
+		t.Errorf("Expected a PEM private key, got: %s", key)
+	}
+	if !bytes.Equal(value.Data, append(append([]byte{}, value.Properties[backendpkg.PropertyCertificate]...), value.Properties[backendpkg.PropertyPrivateKey]...)) {
+		t.Errorf("Expected the certificate chain followed by the private key as data")
+	}
+
+	if _, err := backend.Get(context.Background(), "cert/missing", ""); err == nil {
+		t.Errorf("There should have been an error because the certificate does not exist")
+	}
+}
+
+func TestGetKey(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	x := base64.RawURLEncoding.EncodeToString(key.X.Bytes())
+	y := base64.RawURLEncoding.EncodeToString(key.Y.Bytes())
+	kid := "https://vault.vault.azure.net/keys/ec/v3"
+
+	backend := Backend{keyvault: "vault"}
+	backend.Client = &mockedClient{
+		keys: map[string]keyvault.KeyBundle{
+			"ec":  {Key: &keyvault.JSONWebKey{Kid: &kid, Kty: keyvault.EC, Crv: keyvault.P256, X: &x, Y: &y}},
+			"oct": {Key: &keyvault.JSONWebKey{Kid: &kid, Kty: keyvault.Oct}},
+		},
+	}
+
+	value, err := backend.Get(context.Background(), "key/ec", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, _ := pem.Decode(value.Data)
+	if block == nil || block.Type != "PUBLIC KEY" {
+		t.Fatalf("Expected a PEM public key, got: %s", value.Data)
+	}
+	publicKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ecKey, ok := publicKey.(*ecdsa.PublicKey); !ok || ecKey.X.Cmp(key.X) != 0 || ecKey.Y.Cmp(key.Y) != 0 {
+		t.Errorf("Expected the public key of the JSON web key")
+	}
+	if version := value.Metadata[backendpkg.MetadataVersion]; version != "v3" {
+		t.Errorf("Expected: v3, got: %s", version)
+	}
+
+	if _, err := backend.Get(context.Background(), "key/oct", ""); err == nil {
+		t.Errorf("There should have been an error because symmetric keys have no public key")
+	}
+	if _, err := backend.GetPrevious(context.Background(), "key/ec", value); err == nil {
+		t.Errorf("There should have been an error because previous versions of keys are not supported")
+	}
+}
+
+func TestHealthCheck(t *testing.T) {
+	backend := Backend{}
+	if err := backend.HealthCheck(context.Background()); err == nil {
+		t.Errorf("There should have been an error because the backend has not been initialized")
+	}
+
+	backend.Client = &mockedClient{}
+	if err := backend.HealthCheck(context.Background()); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestGetPrevious(t *testing.T) {
+	backend := Backend{keyvault: "vault"}
+	backend.Client = &mockedClient{}
+
+	previous, err := backend.GetPrevious(context.Background(), "hello", backendpkg.NewValue([]byte("hello"), "v3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// v2 is disabled
+	if version := previous.Metadata[backendpkg.MetadataVersion]; version != "v1" {
+		t.Errorf("Expected: v1, got: %s", version)
+	}
+
+	previous, err = backend.GetPrevious(context.Background(), "hello", backendpkg.NewValue([]byte("hello"), "v1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if previous != nil {
+		t.Errorf("Expected no previous version of the first version, got: %s", previous.Metadata[backendpkg.MetadataVersion])
+	}
 }
@@ -2,10 +2,12 @@
 package asm
 
 import (
-	"encoding/base64"
+	"context"
 	"fmt"
+	"strings"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/secretsmanager"
 	"github.com/aws/aws-sdk-go/service/secretsmanager/secretsmanageriface"
@@ -16,6 +18,10 @@ import (
 
 const (
 	defaultRegion = "eu-west-2"
+
+	// metadataStages is the Value metadata holding the comma separated
+	// stages of the version retrieved
+	metadataStages = "stages"
 )
 
 var (
@@ -38,10 +44,10 @@ func NewBackend() backend.Backend {
 }
 
 // Init initializes the Backend for AWS Secret Manager
-func (s *Backend) Init(parameters map[string]interface{}, credentials []byte) error {
+func (s *Backend) Init(ctx context.Context, parameters map[string]interface{}, credentials []byte) error {
 	var err error
 
-	s.session, err = utils.GetAWSSession(parameters, credentials, defaultRegion)
+	s.session, err = utils.GetAWSSession(ctx, parameters, credentials, defaultRegion)
 	if err != nil {
 		return err
 	}
@@ -50,42 +56,148 @@ func (s *Backend) Init(parameters map[string]interface{}, credentials []byte) er
 	return nil
 }
 
-// Get retrieves the secret associated with key from AWS Secrets Manager
-func (s *Backend) Get(key string, version string) (string, error) {
-	_ = version
+// VersionKinds implements backend.VersionKinds, AWS Secrets Manager retrieves
+// versions by VersionId or version stage
+func (s *Backend) VersionKinds() []backend.VersionKind {
+	return []backend.VersionKind{backend.VersionKindLatest, backend.VersionKindID, backend.VersionKindStage}
+}
 
+// Get retrieves the secret associated with key from AWS Secrets Manager. The
+// latest version is the one staged AWSCURRENT.
+func (s *Backend) Get(ctx context.Context, key string, version string) (*backend.Value, error) {
 	input := &secretsmanager.GetSecretValueInput{
 		SecretId: aws.String(key),
 	}
-	err := input.Validate()
+
+	parsed, err := backend.ParseVersion(version)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	switch parsed.Kind {
+	case backend.VersionKindID:
+		input.VersionId = aws.String(parsed.Value)
+	case backend.VersionKindStage:
+		input.VersionStage = aws.String(parsed.Value)
+	case backend.VersionKindAlias:
+		return nil, fmt.Errorf("version %q: alias versions are not supported by AWS Secrets Manager", version)
+	}
+
+	err = input.Validate()
+	if err != nil {
+		return nil, err
 	}
 
 	if s.SecretsManager == nil {
 		log.Error(fmt.Errorf("error"), "backend not initialized")
-		return "", fmt.Errorf("backend not initialized")
+		return nil, fmt.Errorf("backend not initialized")
 	}
 
-	result, err := s.SecretsManager.GetSecretValue(input)
+	result, err := s.SecretsManager.GetSecretValueWithContext(ctx, input)
 	if err != nil {
 		log.Error(err, "Error getting secret value")
-		return "", err
+		return nil, err
 	}
 
 	// https: //docs.aws.amazon.com/secretsmanager/latest/apireference/API_CreateSecret.html
 	// TLDR: Either SecretString or SecretBinary must have a value, but not both. They cannot both be empty.
-	var secretValue string
+	// SecretBinary is already base64 decoded by the SDK.
+	var secretValue []byte
 	if result.SecretString != nil {
-		secretValue = *result.SecretString
+		secretValue = []byte(*result.SecretString)
 	} else {
-		decodedBinarySecretBytes := make([]byte, base64.StdEncoding.DecodedLen(len(result.SecretBinary)))
-		len, err := base64.StdEncoding.Decode(decodedBinarySecretBytes, result.SecretBinary)
+		secretValue = result.SecretBinary
+	}
+	value := backend.NewValue(secretValue, aws.StringValue(result.VersionId))
+	value.Metadata[metadataStages] = strings.Join(aws.StringValueSlice(result.VersionStages), ",")
+	return value, nil
+}
+
+// HealthCheck lists at most one secret to check AWS Secrets Manager is reachable
+func (s *Backend) HealthCheck(ctx context.Context) error {
+	if s.SecretsManager == nil {
+		return fmt.Errorf("backend not initialized")
+	}
+
+	_, err := s.SecretsManager.ListSecretsWithContext(ctx, &secretsmanager.ListSecretsInput{
+		MaxResults: aws.Int64(1),
+	})
+	return err
+}
+
+// GetPrevious retrieves the version of key created last before current. The
+// previous version of the version staged AWSCURRENT is the one staged
+// AWSPREVIOUS, the previous version of other versions, e.g. pinned by VersionId,
+// is found by listing the versions of key.
+func (s *Backend) GetPrevious(ctx context.Context, key string, current *backend.Value) (*backend.Value, error) {
+	if current == nil || hasStage(current, "AWSCURRENT") {
+		previous, err := s.Get(ctx, key, "AWSPREVIOUS")
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == secretsmanager.ErrCodeResourceNotFoundException {
+			return nil, nil
+		}
 		if err != nil {
-			log.Error(err, "Base64 Decode Error:")
-			return "", err
+			return nil, err
+		}
+		return previous, nil
+	}
+
+	previousID, err := s.previousVersionID(ctx, key, current.Metadata[backend.MetadataVersion])
+	if err != nil || previousID == "" {
+		return nil, err
+	}
+	return s.Get(ctx, key, previousID)
+}
+
+// previousVersionID returns the id of the version of key created last before
+// the version currentID, empty when currentID is the first version
+func (s *Backend) previousVersionID(ctx context.Context, key string, currentID string) (string, error) {
+	if s.SecretsManager == nil {
+		return "", fmt.Errorf("backend not initialized")
+	}
+
+	versions := []*secretsmanager.SecretVersionsListEntry{}
+	err := s.SecretsManager.ListSecretVersionIdsPagesWithContext(ctx, &secretsmanager.ListSecretVersionIdsInput{
+		SecretId:          aws.String(key),
+		IncludeDeprecated: aws.Bool(true),
+	}, func(page *secretsmanager.ListSecretVersionIdsOutput, _ bool) bool {
+		versions = append(versions, page.Versions...)
+		return true
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var current *secretsmanager.SecretVersionsListEntry
+	for _, version := range versions {
+		if aws.StringValue(version.VersionId) == currentID {
+			current = version
+		}
+	}
+	if current == nil {
+		return "", fmt.Errorf("version %s of %s not found", currentID, key)
+	}
+
+	var previous *secretsmanager.SecretVersionsListEntry
+	for _, version := range versions {
+		created := aws.TimeValue(version.CreatedDate)
+		if !created.Before(aws.TimeValue(current.CreatedDate)) {
+			continue
+		}
+		if previous == nil || created.After(aws.TimeValue(previous.CreatedDate)) {
+			previous = version
+		}
+	}
+	if previous == nil {
+		return "", nil
+	}
+	return aws.StringValue(previous.VersionId), nil
+}
+
+// hasStage tells whether value is the version staged stage
+func hasStage(value *backend.Value, stage string) bool {
+	for _, s := range strings.Split(value.Metadata[metadataStages], ",") {
+		if s == stage {
+			return true
 		}
-		secretValue = string(decodedBinarySecretBytes[:len])
 	}
-	return secretValue, nil
+	return false
 }
@@ -1,9 +1,14 @@
 package asm
 
 import (
+	"context"
 	"errors"
 	"testing"
+	"time"
 
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/service/secretsmanager"
 	"github.com/aws/aws-sdk-go/service/secretsmanager/secretsmanageriface"
 	. "github.com/smartystreets/goconvey/convey"
@@ -11,15 +16,30 @@ import (
 
 type mockedSecretsManager struct {
 	secretsmanageriface.SecretsManagerAPI
-	withError bool
+	withError  bool
+	noPrevious bool
+	input      *secretsmanager.GetSecretValueInput
 }
 
-func (m *mockedSecretsManager) GetSecretValue(input *secretsmanager.GetSecretValueInput) (*secretsmanager.GetSecretValueOutput, error) {
+func (m *mockedSecretsManager) GetSecretValueWithContext(ctx aws.Context, input *secretsmanager.GetSecretValueInput, opts ...request.Option) (*secretsmanager.GetSecretValueOutput, error) {
+	m.input = input
+	if m.noPrevious && aws.StringValue(input.VersionStage) == "AWSPREVIOUS" {
+		return nil, awserr.New(secretsmanager.ErrCodeResourceNotFoundException, "no version staged AWSPREVIOUS", nil)
+	}
 	mockSecretString := *input.SecretId + "Value"
-	mockedSecretBinary := []byte("b2ggbm8gVGhleSBjYW4gc2VlIHVzIG5vdw==")
+	mockedSecretBinary := []byte{0x6f, 0x68, 0x00, 0xff, 0xfe}
 
 	output := &secretsmanager.GetSecretValueOutput{
-		Name: input.SecretId,
+		Name:          input.SecretId,
+		VersionId:     aws.String("version-id"),
+		VersionStages: aws.StringSlice([]string{"AWSCURRENT"}),
+	}
+	if input.VersionId != nil {
+		output.VersionId = input.VersionId
+		output.VersionStages = nil
+	}
+	if input.VersionStage != nil {
+		output.VersionStages = aws.StringSlice([]string{*input.VersionStage})
 	}
 
 	if *input.SecretId == "secretKeyBinary" {
@@ -33,6 +53,30 @@ func (m *mockedSecretsManager) GetSecretValue(input *secretsmanager.GetSecretVal
 	return output, nil
 }
 
+// ListSecretVersionIdsPagesWithContext lists the versions EXAMPLE1, EXAMPLE2 and
+// EXAMPLE3, created in that order, over two pages
+func (m *mockedSecretsManager) ListSecretVersionIdsPagesWithContext(ctx aws.Context, input *secretsmanager.ListSecretVersionIdsInput, fn func(*secretsmanager.ListSecretVersionIdsOutput, bool) bool, opts ...request.Option) error {
+	if m.withError {
+		return errors.New("oops")
+	}
+	created := time.Date(2021, time.January, 1, 0, 0, 0, 0, time.UTC)
+	version := func(id string, days int) *secretsmanager.SecretVersionsListEntry {
+		return &secretsmanager.SecretVersionsListEntry{VersionId: aws.String(id), CreatedDate: aws.Time(created.AddDate(0, 0, days))}
+	}
+	if !fn(&secretsmanager.ListSecretVersionIdsOutput{Versions: []*secretsmanager.SecretVersionsListEntry{version("EXAMPLE3-90ab-cdef-fedc-ba987EXAMPLE", 2), version("EXAMPLE1-90ab-cdef-fedc-ba987EXAMPLE", 0)}}, false) {
+		return nil
+	}
+	fn(&secretsmanager.ListSecretVersionIdsOutput{Versions: []*secretsmanager.SecretVersionsListEntry{version("EXAMPLE2-90ab-cdef-fedc-ba987EXAMPLE", 1)}}, true)
+	return nil
+}
+
+func (m *mockedSecretsManager) ListSecretsWithContext(ctx aws.Context, input *secretsmanager.ListSecretsInput, opts ...request.Option) (*secretsmanager.ListSecretsOutput, error) {
+	if m.withError {
+		return nil, errors.New("oops")
+	}
+	return &secretsmanager.ListSecretsOutput{}, nil
+}
+
 func TestNewBackend(t *testing.T) {
 	Convey("When creating a new ASM backend", t, func() {
 		backend := NewBackend()
@@ -47,12 +91,12 @@ func TestGet(t *testing.T) {
 	keyVersion := ""
 	secretValue := "secretValue"
 	expectedValue := secretValue
-	expectedSecretBinaryValue := "oh no They can see us now"
+	expectedSecretBinaryValue := []byte{0x6f, 0x68, 0x00, 0xff, 0xfe}
 
 	Convey("Given an uninitialized AWSSecretsManagerBackend", t, func() {
 		backend := Backend{}
 		Convey("When retrieving a secret", func() {
-			_, err := backend.Get(secretKey, keyVersion)
+			_, err := backend.Get(context.Background(), secretKey, keyVersion)
 			Convey("Then an error is returned", func() {
 				So(err, ShouldNotBeNil)
 				So(err.Error(), ShouldEqual, "backend not initialized")
@@ -64,10 +108,11 @@ func TestGet(t *testing.T) {
 		backend := Backend{}
 		backend.SecretsManager = &mockedSecretsManager{}
 		Convey("When retrieving a secret", func() {
-			actualValue, err := backend.Get(secretKey, keyVersion)
+			actualValue, err := backend.Get(context.Background(), secretKey, keyVersion)
 			Convey("Then no error is returned", func() {
 				So(err, ShouldBeNil)
-				So(actualValue, ShouldEqual, expectedValue)
+				So(string(actualValue.Data), ShouldEqual, expectedValue)
+				So(actualValue.Metadata, ShouldResemble, map[string]string{"version": "version-id", "stages": "AWSCURRENT"})
 			})
 		})
 	})
@@ -76,10 +121,44 @@ func TestGet(t *testing.T) {
 		backend := Backend{}
 		backend.SecretsManager = &mockedSecretsManager{}
 		Convey("When retrieving a binary secret", func() {
-			actualValue, err := backend.Get(secretKeyBinary, keyVersion)
-			Convey("Then no error is returned", func() {
+			actualValue, err := backend.Get(context.Background(), secretKeyBinary, keyVersion)
+			Convey("Then the binary value is returned as is", func() {
 				So(err, ShouldBeNil)
-				So(actualValue, ShouldEqual, expectedSecretBinaryValue)
+				So(actualValue.Data, ShouldResemble, expectedSecretBinaryValue)
+			})
+		})
+	})
+
+	Convey("Given an initialized AWSSecretsManagerBackend", t, func() {
+		secretsManager := &mockedSecretsManager{}
+		backend := Backend{SecretsManager: secretsManager}
+		Convey("When retrieving a secret by version id", func() {
+			_, err := backend.Get(context.Background(), secretKey, "EXAMPLE1-90ab-cdef-fedc-ba987EXAMPLE")
+			Convey("Then the version id is requested", func() {
+				So(err, ShouldBeNil)
+				So(aws.StringValue(secretsManager.input.VersionId), ShouldEqual, "EXAMPLE1-90ab-cdef-fedc-ba987EXAMPLE")
+				So(secretsManager.input.VersionStage, ShouldBeNil)
+			})
+		})
+		Convey("When retrieving a secret by version stage", func() {
+			_, err := backend.Get(context.Background(), secretKey, "AWSPREVIOUS")
+			Convey("Then the version stage is requested", func() {
+				So(err, ShouldBeNil)
+				So(aws.StringValue(secretsManager.input.VersionStage), ShouldEqual, "AWSPREVIOUS")
+				So(secretsManager.input.VersionId, ShouldBeNil)
+			})
+		})
+		Convey("When retrieving a secret by custom version stage", func() {
+			_, err := backend.Get(context.Background(), secretKey, "stage:blue")
+			Convey("Then the version stage is requested", func() {
+				So(err, ShouldBeNil)
+				So(aws.StringValue(secretsManager.input.VersionStage), ShouldEqual, "blue")
+			})
+		})
+		Convey("When retrieving a secret by alias", func() {
+			_, err := backend.Get(context.Background(), secretKey, "alias:prod")
+			Convey("Then an error is returned", func() {
+				So(err, ShouldNotBeNil)
 			})
 		})
 	})
@@ -88,7 +167,121 @@ func TestGet(t *testing.T) {
 		backend := Backend{}
 		backend.SecretsManager = &mockedSecretsManager{withError: true}
 		Convey("When retrieving a secret", func() {
-			_, err := backend.Get(secretKey, keyVersion)
+			_, err := backend.Get(context.Background(), secretKey, keyVersion)
+			Convey("Then an error is returned", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+}
+
+func TestGetPrevious(t *testing.T) {
+	Convey("Given an initialized AWSSecretsManagerBackend", t, func() {
+		secretsManager := &mockedSecretsManager{}
+		backend := Backend{SecretsManager: secretsManager}
+		Convey("When retrieving the previous version of a secret", func() {
+			previous, err := backend.GetPrevious(context.Background(), "secret", nil)
+			Convey("Then the version staged AWSPREVIOUS is returned", func() {
+				So(err, ShouldBeNil)
+				So(previous, ShouldNotBeNil)
+				So(aws.StringValue(secretsManager.input.VersionStage), ShouldEqual, "AWSPREVIOUS")
+			})
+		})
+	})
+
+	Convey("Given a version pinned by VersionId", t, func() {
+		secretsManager := &mockedSecretsManager{}
+		backend := Backend{SecretsManager: secretsManager}
+		current, err := backend.Get(context.Background(), "secret", "EXAMPLE2-90ab-cdef-fedc-ba987EXAMPLE")
+		So(err, ShouldBeNil)
+		Convey("When retrieving its previous version", func() {
+			previous, err := backend.GetPrevious(context.Background(), "secret", current)
+			Convey("Then the version created before it is returned", func() {
+				So(err, ShouldBeNil)
+				So(previous.Metadata["version"], ShouldEqual, "EXAMPLE1-90ab-cdef-fedc-ba987EXAMPLE")
+				So(aws.StringValue(secretsManager.input.VersionId), ShouldEqual, "EXAMPLE1-90ab-cdef-fedc-ba987EXAMPLE")
+			})
+		})
+	})
+
+	Convey("Given the version staged AWSCURRENT", t, func() {
+		secretsManager := &mockedSecretsManager{}
+		backend := Backend{SecretsManager: secretsManager}
+		current, err := backend.Get(context.Background(), "secret", "")
+		So(err, ShouldBeNil)
+		Convey("When retrieving its previous version", func() {
+			previous, err := backend.GetPrevious(context.Background(), "secret", current)
+			Convey("Then the version staged AWSPREVIOUS is returned", func() {
+				So(err, ShouldBeNil)
+				So(previous.Metadata["stages"], ShouldEqual, "AWSPREVIOUS")
+				So(aws.StringValue(secretsManager.input.VersionStage), ShouldEqual, "AWSPREVIOUS")
+			})
+		})
+	})
+
+	Convey("Given the first version of a secret", t, func() {
+		backend := Backend{SecretsManager: &mockedSecretsManager{}}
+		current, err := backend.Get(context.Background(), "secret", "EXAMPLE1-90ab-cdef-fedc-ba987EXAMPLE")
+		So(err, ShouldBeNil)
+		Convey("When retrieving its previous version", func() {
+			previous, err := backend.GetPrevious(context.Background(), "secret", current)
+			Convey("Then there is none", func() {
+				So(err, ShouldBeNil)
+				So(previous, ShouldBeNil)
+			})
+		})
+	})
+
+	Convey("Given a version missing from the listed versions", t, func() {
+		backend := Backend{SecretsManager: &mockedSecretsManager{}}
+		current, err := backend.Get(context.Background(), "secret", "EXAMPLE9-90ab-cdef-fedc-ba987EXAMPLE")
+		So(err, ShouldBeNil)
+		Convey("When retrieving its previous version", func() {
+			_, err := backend.GetPrevious(context.Background(), "secret", current)
+			Convey("Then an error is returned", func() {
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldEqual, "version EXAMPLE9-90ab-cdef-fedc-ba987EXAMPLE of secret not found")
+			})
+		})
+	})
+
+	Convey("Given a secret that was never rotated", t, func() {
+		backend := Backend{SecretsManager: &mockedSecretsManager{noPrevious: true}}
+		Convey("When retrieving its previous version", func() {
+			previous, err := backend.GetPrevious(context.Background(), "secret", nil)
+			Convey("Then there is none", func() {
+				So(err, ShouldBeNil)
+				So(previous, ShouldBeNil)
+			})
+		})
+	})
+}
+
+func TestHealthCheck(t *testing.T) {
+	Convey("Given an uninitialized AWSSecretsManagerBackend", t, func() {
+		backend := Backend{}
+		Convey("When checking its health", func() {
+			err := backend.HealthCheck(context.Background())
+			Convey("Then an error is returned", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+
+	Convey("Given an initialized AWSSecretsManagerBackend", t, func() {
+		backend := Backend{SecretsManager: &mockedSecretsManager{}}
+		Convey("When checking its health", func() {
+			err := backend.HealthCheck(context.Background())
+			Convey("Then no error is returned", func() {
+				So(err, ShouldBeNil)
+			})
+		})
+	})
+
+	Convey("Given an unreachable AWSSecretsManagerBackend", t, func() {
+		backend := Backend{SecretsManager: &mockedSecretsManager{withError: true}}
+		Convey("When checking its health", func() {
+			err := backend.HealthCheck(context.Background())
 			Convey("Then an error is returned", func() {
 				So(err, ShouldNotBeNil)
 			})
@@ -190,7 +383,7 @@ func TestInit(t *testing.T) {
 
 			Convey("When initializing an ASM backend", func() {
 				b := Backend{}
-				err := b.Init(test.parameters, []byte(test.credentials))
+				err := b.Init(context.Background(), test.parameters, []byte(test.credentials))
 				So(err, ShouldBeNil)
 				Convey("Then credentials are reflected in the AWS session", func() {
 					actualCredentials, err := b.session.Config.Credentials.Get()
@@ -220,7 +413,7 @@ func TestInit(t *testing.T) {
 		}
 
 		b := Backend{}
-		err := b.Init(testParams.parameters, []byte(testParams.credentials))
+		err := b.Init(context.Background(), testParams.parameters, []byte(testParams.credentials))
 		Convey("Then an error is returned", func() {
 			So(err, ShouldNotBeNil)
 			So(err.Error(), ShouldEqual, "AWS region parameter missing")
@@ -229,8 +422,8 @@ func TestInit(t *testing.T) {
 
 	Convey("When invalid credentials are passed", t, func() {
 		testParams := credentialsAndParametersTest{
-			credentials:             "",
-			parameters:              map[string]interface{}{},
+			credentials:             "{",
+			parameters:              map[string]interface{}{"region": "eu-west-2"},
 			expectedAccessKeyID:     "AKIABLABLA",
 			expectedRegion:          "eu-mediterranean-1",
 			expectedSecretAccessKey: "SMMSsecrets",
@@ -238,7 +431,7 @@ func TestInit(t *testing.T) {
 		}
 
 		b := Backend{}
-		err := b.Init(testParams.parameters, []byte(testParams.credentials))
+		err := b.Init(context.Background(), testParams.parameters, []byte(testParams.credentials))
 		Convey("Then an error is returned", func() {
 			So(err, ShouldNotBeNil)
 			So(err.Error(), ShouldEqual, "unexpected end of JSON input")
@@ -0,0 +1,21 @@
+package backend
+
+import "context"
+
+// ambientCredentialsKey is the context key marking backends may use ambient credentials
+type ambientCredentialsKey struct{}
+
+// WithAmbientCredentials returns a copy of ctx allowing the backends initialized
+// with it to use ambient credentials
+func WithAmbientCredentials(ctx context.Context) context.Context {
+	return context.WithValue(ctx, ambientCredentialsKey{}, true)
+}
+
+// AmbientCredentials tells whether backends initialized with ctx may use the
+// ambient credentials of the operator, e.g. the cloud identity of its pod,
+// instead of the credentials of their SecretStore. It is opt-in as any
+// SecretStore omitting credentials would otherwise act as the operator.
+func AmbientCredentials(ctx context.Context) bool {
+	allowed, _ := ctx.Value(ambientCredentialsKey{}).(bool)
+	return allowed
+}
@@ -1,46 +1,96 @@
 package backend
 
 import (
+	"context"
 	"fmt"
-	"strings"
-	"sync"
 
-	config "github.com/containersolutions/externalsecret-operator/pkg/config"
 	ctrl "sigs.k8s.io/controller-runtime"
 )
 
 var log = ctrl.Log.WithName("backend")
 
-// Backend is an abstract backend interface
+// MetadataVersion is the Value metadata holding the version actually retrieved
+const MetadataVersion = "version"
+
+// Backend is an abstract backend interface. The context passed to its methods
+// is cancelled with the reconcile calling them.
 type Backend interface {
-	Init(map[string]interface{}, []byte) error
-	Get(string, string) (string, error)
+	Init(context.Context, map[string]interface{}, []byte) error
+	Get(context.Context, string, string) (*Value, error)
 }
 
-// Instances are instantiated secret backends
-var Instances map[string]Backend
+// Closer is implemented by backends holding clients or connections to release
+// when their SecretStore is deleted
+type Closer interface {
+	Close() error
+}
 
-// Functions is a map of labelled functions that return secret backend instances
-var Functions map[string]func() Backend
+// HealthChecker is implemented by backends able to check they can reach their
+// provider with a cheap request, without retrieving any secret value
+type HealthChecker interface {
+	HealthCheck(context.Context) error
+}
 
-var initLock sync.Mutex
+// PreviousGetter is implemented by backends able to retrieve the version that
+// preceded current, the value of a secret being rotated. It returns nil, and
+// no error, when current is the first version.
+type PreviousGetter interface {
+	GetPrevious(ctx context.Context, key string, current *Value) (*Value, error)
+}
+
+// Lister is implemented by backends able to list all the secrets they can
+// retrieve, with their latest value, so that they are synced at once
+type Lister interface {
+	List(ctx context.Context) (map[string]*Value, error)
+}
 
-// Instantiate instantiates a Backend of type `backendType`
-func Instantiate(name string, backendType string) error {
-	if Instances == nil {
-		Instances = make(map[string]Backend)
+// Value is a secret value retrieved from a Backend. Data is kept as is,
+// binary values are never converted to strings.
+type Value struct {
+	Data     []byte
+	Metadata map[string]string
+	// Properties are named parts of Data, e.g. the certificate chain and the
+	// private key of a TLS certificate, that ExternalSecret keys can select
+	Properties map[string][]byte
+}
+
+// Property returns the property name of v, or its Data when name is empty
+func (v *Value) Property(name string) ([]byte, error) {
+	if name == "" {
+		return v.Data, nil
+	}
+	data, ok := v.Properties[name]
+	if !ok {
+		return nil, fmt.Errorf("property %q not found", name)
+	}
+	return data, nil
+}
+
+// NewValue returns a Value holding data, retrieved with version when not empty
+func NewValue(data []byte, version string) *Value {
+	value := &Value{
+		Data:     data,
+		Metadata: map[string]string{},
+	}
+	if version != "" {
+		value.Metadata[MetadataVersion] = version
 	}
+	return value
+}
+
+// Functions is a map of labelled functions that return secret backend instances
+var Functions map[string]func() Backend
 
+// Instantiate returns a new uninitialized Backend of type `backendType`
+func Instantiate(backendType string) (Backend, error) {
 	function, found := Functions[backendType]
 	if !found {
 		log.Error(fmt.Errorf("error"), fmt.Sprintf("unknown backend type: '%v'", backendType))
-		return fmt.Errorf("unknown backend type: '%v'", backendType)
+		return nil, fmt.Errorf("unknown backend type: '%v'", backendType)
 	}
 
-	log.Info("Instantiate", "name", name, "type", backendType)
-	Instances[name] = function()
-
-	return nil
+	log.Info("Instantiate", "type", backendType)
+	return function(), nil
 }
 
 // Register registers a new backend type with name `name`staging
@@ -54,47 +104,6 @@ func Register(name string, function func() Backend) {
 	Functions[name] = function
 }
 
-// InitFromEnv initializes a backend looking into Env for config data
-func InitFromEnv(leaderID string) error {
-	initLock.Lock()
-	defer initLock.Unlock()
-	log.Info("InitFromEnv", "availableBackends", strings.Join(availableBackends(), ","))
-
-	config, err := config.ConfigFromEnv()
-	if err != nil {
-		return err
-	}
-
-	err = Instantiate(leaderID, config.Type)
-	if err != nil {
-		log.Error(err, "")
-		return err
-	}
-
-	log.Info("Initialize", "name", leaderID)
-	err = Instances[leaderID].Init(config.Parameters, []byte(""))
-
-	return err
-}
-
-// InitFromCtrl initializes within a controller
-func InitFromCtrl(contrl string, config *config.Config, credentials []byte) error {
-	initLock.Lock()
-	defer initLock.Unlock()
-	log.Info("InitFromCtrl", "availableBackends", strings.Join(availableBackends(), ","))
-
-	err := Instantiate(contrl, config.Type)
-	if err != nil {
-		log.Error(err, "")
-		return err
-	}
-
-	log.Info("Initialize", "name", contrl)
-	err = Instances[contrl].Init(config.Parameters, credentials)
-
-	return err
-}
-
 func availableBackends() []string {
 	backends := []string{}
 	for k := range Functions {
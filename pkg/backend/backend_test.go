@@ -1,9 +1,11 @@
 package backend
 
 import (
+	"context"
 	"encoding/json"
 	"os"
 	"reflect"
+	"sync"
 	"testing"
 
 	config "github.com/containersolutions/externalsecret-operator/pkg/config"
@@ -18,13 +20,60 @@ func NewBackend() Backend {
 	return &MockBackend{}
 }
 
-func (m *MockBackend) Init(params map[string]interface{}, credentials []byte) error {
+func (m *MockBackend) Init(ctx context.Context, params map[string]interface{}, credentials []byte) error {
 	m.Param1 = params["Param1"].(string)
 	return nil
 }
 
-func (m *MockBackend) Get(key string, version string) (string, error) {
-	return m.Param1, nil
+func (m *MockBackend) Get(ctx context.Context, key string, version string) (*Value, error) {
+	return NewValue([]byte(m.Param1), version), nil
+}
+
+type ClosingMockBackend struct {
+	MockBackend
+	closed bool
+}
+
+func (m *ClosingMockBackend) Close() error {
+	m.closed = true
+	return nil
+}
+
+func TestNewValue(t *testing.T) {
+	Convey("When creating a value with a version", t, func() {
+		value := NewValue([]byte{0xff, 0x00}, "v1")
+		Convey("Then the data is kept as is and the version recorded", func() {
+			So(value.Data, ShouldResemble, []byte{0xff, 0x00})
+			So(value.Metadata[MetadataVersion], ShouldEqual, "v1")
+		})
+	})
+
+	Convey("When creating a value without a version", t, func() {
+		value := NewValue([]byte("data"), "")
+		Convey("Then no version is recorded", func() {
+			So(value.Metadata, ShouldBeEmpty)
+		})
+	})
+}
+
+func TestValueProperty(t *testing.T) {
+	Convey("Given a value with properties", t, func() {
+		value := NewValue([]byte("bundle"), "")
+		value.Properties = map[string][]byte{"tls.key": []byte("key")}
+		Convey("When selecting properties", func() {
+			data, err := value.Property("")
+			key, errKey := value.Property("tls.key")
+			_, errMissing := value.Property("tls.crt")
+			Convey("Then the data or the property is returned", func() {
+				So(err, ShouldBeNil)
+				So(string(data), ShouldEqual, "bundle")
+				So(errKey, ShouldBeNil)
+				So(string(key), ShouldEqual, "key")
+				So(errMissing, ShouldNotBeNil)
+				So(errMissing.Error(), ShouldEqual, `property "tls.crt" not found`)
+			})
+		})
+	})
 }
 
 func TestRegister(t *testing.T) {
@@ -44,16 +93,14 @@ func TestInstantiate(t *testing.T) {
 	Convey("Given a registered backend type", t, func() {
 		Register("mock", NewBackend)
 		Convey("When Instantiating it using the right label", func() {
-			err := Instantiate("mock-backend", "mock")
+			backend, err := Instantiate("mock")
 			So(err, ShouldBeNil)
-			Convey("Then a backend is instantiated with the right label", func() {
-				backend, found := Instances["mock-backend"]
-				So(found, ShouldBeTrue)
+			Convey("Then a backend of the right type is instantiated", func() {
 				So(reflect.TypeOf(backend), ShouldEqual, reflect.TypeOf(&MockBackend{}))
 			})
 		})
 		Convey("When Instantiating it using the wrong label", func() {
-			err := Instantiate("mock-backend", "mock-wrong-label")
+			_, err := Instantiate("mock-wrong-label")
 			Convey("Then an error is returned", func() {
 				So(err, ShouldNotBeNil)
 				So(err.Error(), ShouldEqual, "unknown backend type: 'mock-wrong-label'")
@@ -73,18 +120,19 @@ func TestInitFromEnv(t *testing.T) {
 
 	Convey("Given a registered backend type", t, func() {
 		Register("mock", NewBackend)
+		registry := NewRegistry()
 		Convey("Given a valid config", func() {
 			configData, _ := json.Marshal(configStruct)
 			os.Setenv("OPERATOR_CONFIG", string(configData))
 			Convey("When initializing backend from env", func() {
-				err := InitFromEnv("mock-backend")
+				err := registry.InitFromEnv(context.Background(), "mock-backend")
 				So(err, ShouldBeNil)
 				Convey("Then a backend is instantiated and initialized correctly", func() {
-					backend, found := Instances["mock-backend"]
+					backend, found := registry.Get("mock-backend")
 					So(found, ShouldBeTrue)
 					So(reflect.TypeOf(backend), ShouldEqual, reflect.TypeOf(&MockBackend{}))
-					value, _ := backend.Get("", "")
-					So(value, ShouldEqual, "Value1")
+					value, _ := backend.Get(context.Background(), "", "")
+					So(string(value.Data), ShouldEqual, "Value1")
 				})
 			})
 		})
@@ -94,7 +142,7 @@ func TestInitFromEnv(t *testing.T) {
 			configData, _ := json.Marshal(configStruct)
 			os.Setenv("OPERATOR_CONFIG", string(configData))
 			Convey("When initializing backend from env", func() {
-				err := InitFromEnv("mock-backend")
+				err := registry.InitFromEnv(context.Background(), "mock-backend")
 				So(err, ShouldNotBeNil)
 				Convey("Then an error message is returned", func() {
 					So(err.Error(), ShouldEqual, "unknown backend type: 'unknown'")
@@ -105,7 +153,7 @@ func TestInitFromEnv(t *testing.T) {
 		Convey("Given an invalid config", func() {
 			os.Setenv("OPERATOR_CONFIG", "garbage")
 			Convey("When initializing backend from env", func() {
-				err := InitFromEnv("mock-backend")
+				err := registry.InitFromEnv(context.Background(), "mock-backend")
 				So(err, ShouldNotBeNil)
 				Convey("Then an error is returned", func() {
 					So(err.Error(), ShouldStartWith, "invalid")
@@ -116,7 +164,7 @@ func TestInitFromEnv(t *testing.T) {
 		Convey("Given a missing config", func() {
 			os.Unsetenv("OPERATOR_CONFIG")
 			Convey("When initializing backend from env", func() {
-				err := InitFromEnv("mock-backend")
+				err := registry.InitFromEnv(context.Background(), "mock-backend")
 				So(err, ShouldNotBeNil)
 				Convey("Then an error is returned", func() {
 					So(err.Error(), ShouldStartWith, "cannot find config")
@@ -126,7 +174,7 @@ func TestInitFromEnv(t *testing.T) {
 	})
 }
 
-func TestInitFromCtrl(t *testing.T) {
+func TestRegistryInit(t *testing.T) {
 	var (
 		initConfig = config.Config{
 			Type: "mock",
@@ -143,16 +191,17 @@ func TestInitFromCtrl(t *testing.T) {
 
 	Convey("Given a registered backend type", t, func() {
 		Register("mock", NewBackend)
+		registry := NewRegistry()
 		Convey("Given a valid config", func() {
 			Convey("When initializing backend from contrl", func() {
-				err := InitFromCtrl("test-ctrl", &initConfig, []byte(credentials))
+				err := registry.Init(context.Background(), "test-ctrl", &initConfig, []byte(credentials))
 				So(err, ShouldBeNil)
 				Convey("Then a backend is instantiated and initialized correctly", func() {
-					backend, found := Instances["test-ctrl"]
+					backend, found := registry.Get("test-ctrl")
 					So(found, ShouldBeTrue)
 					So(reflect.TypeOf(backend), ShouldEqual, reflect.TypeOf(&MockBackend{}))
-					value, _ := backend.Get("", "")
-					So(value, ShouldEqual, "Value1")
+					value, _ := backend.Get(context.Background(), "", "")
+					So(string(value.Data), ShouldEqual, "Value1")
 				})
 			})
 		})
@@ -161,13 +210,114 @@ func TestInitFromCtrl(t *testing.T) {
 			initConfig.Type = "unknown"
 
 			Convey("When initializing backend from env", func() {
-				err := InitFromCtrl("test-ctrl", &initConfig, []byte(credentials))
+				err := registry.Init(context.Background(), "test-ctrl", &initConfig, []byte(credentials))
 				So(err, ShouldNotBeNil)
 				Convey("Then an error message is returned", func() {
 					So(err.Error(), ShouldEqual, "unknown backend type: 'unknown'")
 				})
 			})
 		})
+
+		Convey("When reading and initializing backends concurrently", func() {
+			initConfig.Type = "mock"
+			var wg sync.WaitGroup
+			for i := 0; i < 10; i++ {
+				wg.Add(2)
+				go func() {
+					defer wg.Done()
+					registry.Init(context.Background(), "test-ctrl", &initConfig, []byte(credentials))
+				}()
+				go func() {
+					defer wg.Done()
+					registry.Get("test-ctrl")
+				}()
+			}
+			wg.Wait()
+			Convey("Then the backend is registered", func() {
+				_, found := registry.Get("test-ctrl")
+				So(found, ShouldBeTrue)
+			})
+		})
+	})
+
+}
+
+func TestRemove(t *testing.T) {
+	Convey("Given a backend instance holding clients", t, func() {
+		instance := &ClosingMockBackend{}
+		Register("closing-mock", func() Backend { return instance })
+		registry := NewRegistry()
+		So(registry.Init(context.Background(), "closing-backend", &config.Config{
+			Type:       "closing-mock",
+			Parameters: map[string]interface{}{"Param1": "Value1"},
+		}, nil), ShouldBeNil)
+
+		Convey("When removing it", func() {
+			err := registry.Remove("closing-backend")
+			Convey("Then it is closed and forgotten", func() {
+				So(err, ShouldBeNil)
+				So(instance.closed, ShouldBeTrue)
+				_, found := registry.Get("closing-backend")
+				So(found, ShouldBeFalse)
+			})
+		})
+
+		Convey("When initializing it again", func() {
+			replacement := &ClosingMockBackend{}
+			Register("closing-mock", func() Backend { return replacement })
+			err := registry.Init(context.Background(), "closing-backend", &config.Config{
+				Type:       "closing-mock",
+				Parameters: map[string]interface{}{"Param1": "Value2"},
+			}, nil)
+			Convey("Then the replaced instance is closed", func() {
+				So(err, ShouldBeNil)
+				So(instance.closed, ShouldBeTrue)
+				So(replacement.closed, ShouldBeFalse)
+				current, _ := registry.Get("closing-backend")
+				So(current, ShouldEqual, replacement)
+			})
+		})
+
+		Convey("When removing an unknown backend", func() {
+			err := registry.Remove("unknown-backend")
+			Convey("Then nothing happens", func() {
+				So(err, ShouldBeNil)
+				So(instance.closed, ShouldBeFalse)
+			})
+		})
 	})
+}
+
+type AmbientMockBackend struct {
+	MockBackend
+	ambient bool
+}
+
+func (m *AmbientMockBackend) Init(ctx context.Context, params map[string]interface{}, credentials []byte) error {
+	m.ambient = AmbientCredentials(ctx)
+	return nil
+}
+
+func TestAmbientCredentials(t *testing.T) {
+	Convey("Given a registry", t, func() {
+		instance := &AmbientMockBackend{}
+		Register("ambient-mock", func() Backend { return instance })
+		registry := NewRegistry()
+		ambientConfig := &config.Config{Type: "ambient-mock"}
 
+		Convey("When initializing a backend", func() {
+			So(registry.Init(context.Background(), "ambient-backend", ambientConfig, nil), ShouldBeNil)
+			Convey("Then ambient credentials are not allowed", func() {
+				So(instance.ambient, ShouldBeFalse)
+			})
+		})
+
+		Convey("When ambient credentials are allowed", func() {
+			registry.SetAmbientCredentials(true)
+			So(registry.Init(context.Background(), "ambient-backend", ambientConfig, nil), ShouldBeNil)
+			Convey("Then the backend may use them", func() {
+				So(instance.ambient, ShouldBeTrue)
+			})
+		})
+	})
 }
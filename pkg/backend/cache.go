@@ -0,0 +1,186 @@
+package backend
+
+import (
+	"container/list"
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	// DefaultCacheTTL is how long a value retrieved from a backend is reused
+	DefaultCacheTTL = time.Minute
+	// DefaultCacheSize is the maximum number of values held in the cache
+	DefaultCacheSize = 1024
+)
+
+// Cache is an in-memory TTL cache of values retrieved from backends, shared
+// between ExternalSecrets so that a remote key referenced many times is only
+// fetched once per TTL. Concurrent lookups of the same key are deduplicated.
+// A nil *Cache is valid and disables caching.
+type Cache struct {
+	ttl     time.Duration
+	maxSize int
+
+	lock        sync.Mutex
+	entries     map[cacheKey]*list.Element
+	lru         *list.List
+	generations map[string]uint64
+	// keyGeneration is bumped by every InvalidateKey, values fetched across
+	// an invalidation are not cached
+	keyGeneration uint64
+	group         singleflight.Group
+
+	now func() time.Time
+}
+
+type cacheKey struct {
+	store   string
+	key     string
+	version string
+}
+
+type cacheEntry struct {
+	key     cacheKey
+	value   *Value
+	expires time.Time
+}
+
+// NewCache returns a Cache holding at most maxSize values for ttl each
+func NewCache(ttl time.Duration, maxSize int) *Cache {
+	return &Cache{
+		ttl:         ttl,
+		maxSize:     maxSize,
+		entries:     make(map[cacheKey]*list.Element),
+		lru:         list.New(),
+		generations: make(map[string]uint64),
+		now:         time.Now,
+	}
+}
+
+// Get returns the cached value of key/version in store, calling fetch on a miss.
+// Errors returned by fetch are not cached.
+func (c *Cache) Get(store string, key string, version string, fetch func() (*Value, error)) (*Value, error) {
+	if c == nil || c.ttl <= 0 || c.maxSize <= 0 {
+		return fetch()
+	}
+
+	k := cacheKey{store: store, key: key, version: version}
+
+	c.lock.Lock()
+	if value, ok := c.lookup(k); ok {
+		c.lock.Unlock()
+		return value, nil
+	}
+	generation := c.generations[store]
+	keyGeneration := c.keyGeneration
+	c.lock.Unlock()
+
+	// Lookups started after an invalidation do not join fetches started before it
+	flightKey := fmt.Sprintf("%d\x00%d\x00%s\x00%s\x00%s", generation, keyGeneration, store, key, version)
+	value, err, _ := c.group.Do(flightKey, func() (interface{}, error) {
+		value, err := fetch()
+		if err != nil {
+			return nil, err
+		}
+
+		c.lock.Lock()
+		defer c.lock.Unlock()
+		// The store was re-initialized while fetching, the value may come from
+		// stale credentials, or a key was invalidated, the value may be outdated
+		if c.generations[store] == generation && c.keyGeneration == keyGeneration {
+			c.add(k, value)
+		}
+		return value, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return value.(*Value), nil
+}
+
+// Invalidate drops every cached value of store
+func (c *Cache) Invalidate(store string) {
+	if c == nil {
+		return
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.generations[store]++
+	for k, element := range c.entries {
+		if k.store == store {
+			c.remove(element)
+		}
+	}
+}
+
+// InvalidateKey drops every cached version of key in store
+func (c *Cache) InvalidateKey(store string, key string) {
+	if c == nil {
+		return
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.keyGeneration++
+	for k, element := range c.entries {
+		if k.store == store && k.key == key {
+			c.remove(element)
+		}
+	}
+}
+
+// Len returns the number of values in the cache, including expired ones not yet evicted
+func (c *Cache) Len() int {
+	if c == nil {
+		return 0
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.lru.Len()
+}
+
+func (c *Cache) lookup(k cacheKey) (*Value, bool) {
+	element, ok := c.entries[k]
+	if !ok {
+		return nil, false
+	}
+
+	entry := element.Value.(*cacheEntry)
+	if !c.now().Before(entry.expires) {
+		c.remove(element)
+		return nil, false
+	}
+
+	c.lru.MoveToFront(element)
+	return entry.value, true
+}
+
+func (c *Cache) add(k cacheKey, value *Value) {
+	if element, ok := c.entries[k]; ok {
+		c.remove(element)
+	}
+
+	for c.lru.Len() >= c.maxSize {
+		c.remove(c.lru.Back())
+	}
+
+	c.entries[k] = c.lru.PushFront(&cacheEntry{
+		key:     k,
+		value:   value,
+		expires: c.now().Add(c.ttl),
+	})
+}
+
+func (c *Cache) remove(element *list.Element) {
+	entry := c.lru.Remove(element).(*cacheEntry)
+	delete(c.entries, entry.key)
+}
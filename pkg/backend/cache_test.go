@@ -0,0 +1,167 @@
+package backend
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCache(t *testing.T) {
+	Convey("Given a cache", t, func() {
+		var calls int32
+		now := time.Now()
+		cache := NewCache(time.Minute, 2)
+		cache.now = func() time.Time { return now }
+
+		fetch := func(value string) func() (*Value, error) {
+			return func() (*Value, error) {
+				atomic.AddInt32(&calls, 1)
+				return NewValue([]byte(value), ""), nil
+			}
+		}
+
+		Convey("When getting the same key twice", func() {
+			first, err := cache.Get("store", "key", "", fetch("value1"))
+			So(err, ShouldBeNil)
+			second, err := cache.Get("store", "key", "", fetch("value2"))
+			So(err, ShouldBeNil)
+			Convey("Then the backend is only called once", func() {
+				So(string(first.Data), ShouldEqual, "value1")
+				So(string(second.Data), ShouldEqual, "value1")
+				So(calls, ShouldEqual, 1)
+			})
+		})
+
+		Convey("When getting different versions or stores of a key", func() {
+			cache.Get("store", "key", "1", fetch("value1"))
+			cache.Get("store", "key", "2", fetch("value2"))
+			cache.Get("other-store", "key", "1", fetch("value3"))
+			Convey("Then each one is fetched", func() {
+				So(calls, ShouldEqual, 3)
+			})
+		})
+
+		Convey("When the ttl expires", func() {
+			cache.Get("store", "key", "", fetch("value1"))
+			now = now.Add(time.Minute)
+			value, _ := cache.Get("store", "key", "", fetch("value2"))
+			Convey("Then the value is fetched again", func() {
+				So(string(value.Data), ShouldEqual, "value2")
+				So(calls, ShouldEqual, 2)
+			})
+		})
+
+		Convey("When the store is invalidated", func() {
+			cache.Get("store", "key", "", fetch("value1"))
+			cache.Get("other-store", "key", "", fetch("value1"))
+			cache.Invalidate("store")
+			value, _ := cache.Get("store", "key", "", fetch("value2"))
+			cache.Get("other-store", "key", "", fetch("value2"))
+			Convey("Then only values of that store are fetched again", func() {
+				So(string(value.Data), ShouldEqual, "value2")
+				So(calls, ShouldEqual, 3)
+			})
+		})
+
+		Convey("When a key is invalidated", func() {
+			cache.Get("store", "key", "1", fetch("value1"))
+			cache.Get("other-store", "key", "", fetch("value1"))
+			cache.Get("store", "other-key", "", fetch("value1"))
+			cache.InvalidateKey("store", "key")
+			Convey("Then every version of it is dropped in that store", func() {
+				So(cache.Len(), ShouldEqual, 2)
+			})
+		})
+
+		Convey("When a key is invalidated while it is fetched", func() {
+			entered := make(chan struct{})
+			release := make(chan struct{})
+			done := make(chan *Value)
+			go func() {
+				value, _ := cache.Get("store", "key", "", func() (*Value, error) {
+					close(entered)
+					<-release
+					return NewValue([]byte("stale"), ""), nil
+				})
+				done <- value
+			}()
+			<-entered
+			cache.InvalidateKey("store", "key")
+
+			fresh, _ := cache.Get("store", "key", "", fetch("fresh"))
+			close(release)
+			stale := <-done
+			Convey("Then the lookups after the invalidation do not get the value fetched before it", func() {
+				So(string(stale.Data), ShouldEqual, "stale")
+				So(string(fresh.Data), ShouldEqual, "fresh")
+				value, _ := cache.Get("store", "key", "", fetch("newer"))
+				So(string(value.Data), ShouldEqual, "fresh")
+			})
+		})
+
+		Convey("When more keys than the size limit are added", func() {
+			cache.Get("store", "key1", "", fetch("value1"))
+			cache.Get("store", "key2", "", fetch("value2"))
+			cache.Get("store", "key1", "", fetch("value1"))
+			cache.Get("store", "key3", "", fetch("value3"))
+			Convey("Then the least recently used key is evicted", func() {
+				So(cache.Len(), ShouldEqual, 2)
+				cache.Get("store", "key1", "", fetch("value1"))
+				So(calls, ShouldEqual, 3)
+				cache.Get("store", "key2", "", fetch("value2"))
+				So(calls, ShouldEqual, 4)
+			})
+		})
+
+		Convey("When the backend returns an error", func() {
+			_, err := cache.Get("store", "key", "", func() (*Value, error) {
+				return nil, fmt.Errorf("oops")
+			})
+			Convey("Then the error is returned and not cached", func() {
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldEqual, "oops")
+				So(cache.Len(), ShouldEqual, 0)
+			})
+		})
+
+		Convey("When the same key is requested concurrently", func() {
+			release := make(chan struct{})
+			var wg sync.WaitGroup
+			for i := 0; i < 10; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					cache.Get("store", "key", "", func() (*Value, error) {
+						<-release
+						atomic.AddInt32(&calls, 1)
+						return NewValue([]byte("value"), ""), nil
+					})
+				}()
+			}
+			time.Sleep(50 * time.Millisecond)
+			close(release)
+			wg.Wait()
+			Convey("Then the backend is called far fewer times than requested", func() {
+				So(calls, ShouldBeLessThan, 10)
+			})
+		})
+	})
+
+	Convey("Given a nil cache", t, func() {
+		var cache *Cache
+		Convey("When getting a key", func() {
+			value, err := cache.Get("store", "key", "", func() (*Value, error) {
+				return NewValue([]byte("value"), ""), nil
+			})
+			Convey("Then the backend value is returned", func() {
+				So(err, ShouldBeNil)
+				So(string(value.Data), ShouldEqual, "value")
+				So(cache.Len(), ShouldEqual, 0)
+			})
+		})
+	})
+}
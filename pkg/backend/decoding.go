@@ -0,0 +1,142 @@
+package backend
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/pkcs12"
+)
+
+// DecodingStrategy is how the value of an ExternalSecret key is decoded before
+// it is written to the target Secret
+type DecodingStrategy string
+
+const (
+	// DecodingNone writes values as retrieved, this is the default
+	DecodingNone DecodingStrategy = "None"
+	// DecodingBase64 decodes standard base64 values
+	DecodingBase64 DecodingStrategy = "Base64"
+	// DecodingBase64URL decodes URL safe base64 values, padded or not
+	DecodingBase64URL DecodingStrategy = "Base64URL"
+	// DecodingHex decodes hexadecimal values
+	DecodingHex DecodingStrategy = "Hex"
+	// DecodingPKCS12 unpacks PKCS#12 archives, raw or base64 encoded, into the
+	// PEM certificate chain and private key
+	DecodingPKCS12 DecodingStrategy = "PKCS12"
+	// DecodingAuto decodes padded base64 then URL safe base64 values, and
+	// writes other values as retrieved. It cannot tell plain text that happens
+	// to be valid base64, e.g. admin123, from base64.
+	DecodingAuto DecodingStrategy = "Auto"
+)
+
+const (
+	// PropertyCertificate holds the PEM certificate chain of a certificate, leaf first
+	PropertyCertificate = "tls.crt"
+	// PropertyPrivateKey holds the PEM private key of a certificate
+	PropertyPrivateKey = "tls.key"
+)
+
+// Decode returns a copy of value with its data decoded with strategy. PKCS#12
+// archives are written as the certificate chain followed by the private key,
+// both available as properties.
+func Decode(value *Value, strategy DecodingStrategy) (*Value, error) {
+	if value == nil || strategy == "" || strategy == DecodingNone {
+		return value, nil
+	}
+
+	decoded := &Value{Metadata: value.Metadata, Properties: value.Properties}
+	data := strings.TrimSpace(string(value.Data))
+	var err error
+
+	switch strategy {
+	case DecodingBase64:
+		decoded.Data, err = base64.StdEncoding.DecodeString(data)
+	case DecodingBase64URL:
+		decoded.Data, err = base64.RawURLEncoding.DecodeString(strings.TrimRight(data, "="))
+	case DecodingHex:
+		decoded.Data, err = decodeHex(data)
+	case DecodingPKCS12:
+		pfx := value.Data
+		if raw, errBase64 := base64.StdEncoding.DecodeString(data); errBase64 == nil {
+			pfx = raw
+		}
+		var certs, key []byte
+		certs, key, err = PKCS12ToPEM(pfx, nil)
+		if err != nil {
+			break
+		}
+		decoded.Data = append(append([]byte{}, certs...), key...)
+		decoded.Properties = map[string][]byte{PropertyCertificate: certs}
+		if len(key) > 0 {
+			decoded.Properties[PropertyPrivateKey] = key
+		}
+	case DecodingAuto:
+		// Only canonical padded values are decoded, unpadded base64 would match
+		// most alphanumeric values
+		for _, encoding := range []*base64.Encoding{base64.StdEncoding, base64.URLEncoding} {
+			raw, errAuto := encoding.Strict().DecodeString(data)
+			if errAuto == nil && encoding.EncodeToString(raw) == data {
+				decoded.Data = raw
+				return decoded, nil
+			}
+		}
+		decoded.Data = value.Data
+	default:
+		return nil, fmt.Errorf("unknown decoding strategy %q", strategy)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("decoding value as %s: %w", strategy, err)
+	}
+
+	return decoded, nil
+}
+
+// decodeHex decodes the hexadecimal data. Its errors give the position of
+// invalid characters rather than the characters, which are part of a secret.
+func decodeHex(data string) ([]byte, error) {
+	decoded, err := hex.DecodeString(data)
+	var invalid hex.InvalidByteError
+	if errors.As(err, &invalid) {
+		return nil, fmt.Errorf("invalid hexadecimal character at offset %d", strings.IndexByte(data, byte(invalid)))
+	}
+	return decoded, err
+}
+
+// PKCS12ToPEM unpacks a PKCS#12 archive protected by an empty password into
+// the PEM certificate chain, with the certificate matching leaf first, and the
+// PEM private key
+func PKCS12ToPEM(pfx []byte, leaf []byte) ([]byte, []byte, error) {
+	blocks, err := pkcs12.ToPEM(pfx, "")
+	if err != nil {
+		return nil, nil, err
+	}
+	certs, key := SplitPEM(blocks, leaf)
+	if len(certs) == 0 {
+		return nil, nil, fmt.Errorf("no certificate found")
+	}
+	return certs, key, nil
+}
+
+// SplitPEM splits blocks into the PEM certificate chain, with the certificate
+// matching leaf first, and the PEM private key. Headers such as the PKCS#12
+// bag attributes are dropped.
+func SplitPEM(blocks []*pem.Block, leaf []byte) ([]byte, []byte) {
+	var certs, chain, key []byte
+	for _, block := range blocks {
+		encoded := pem.EncodeToMemory(&pem.Block{Type: block.Type, Bytes: block.Bytes})
+		switch {
+		case block.Type == "CERTIFICATE" && len(leaf) > 0 && bytes.Equal(block.Bytes, leaf):
+			certs = append(encoded, certs...)
+		case block.Type == "CERTIFICATE":
+			chain = append(chain, encoded...)
+		case strings.HasSuffix(block.Type, "PRIVATE KEY"):
+			key = append(key, encoded...)
+		}
+	}
+	return append(certs, chain...), key
+}
@@ -0,0 +1,94 @@
+package backend
+
+import (
+	"encoding/base64"
+	"encoding/pem"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// Windows Azure Tools certificate with an empty password, from the
+// golang.org/x/crypto/pkcs12 tests
+const testPFX = `MIIKDAIBAzCCCcwGCSqGSIb3DQEHAaCCCb0Eggm5MIIJtTCCBe4GCSqGSIb3DQEHAaCCBd8EggXbMIIF1zCCBdMGCyqGSIb3DQEMCgECoIIE7jCCBOowHAYKKoZIhvcNAQwBAzAOBAhStUNnlTGV+gICB9AEggTIJ81JIossF6boFWpPtkiQRPtI6DW6e9QD4/WvHAVrM2bKdpMzSMsCML5NyuddANTKHBVq00Jc9keqGNAqJPKkjhSUebzQFyhe0E1oI9T4zY5UKr/I8JclOeccH4QQnsySzYUG2SnniXnQ+JrG3juetli7EKth9h6jLc6xbubPadY5HMB3wL/eG/kJymiXwU2KQ9Mgd4X6jbcV+NNCE/8jbZHvSTCPeYTJIjxfeX61Sj5kFKUCzERbsnpyevhY3X0eYtEDezZQarvGmXtMMdzf8HJHkWRdk9VLDLgjk8uiJif/+X4FohZ37ig0CpgC2+dP4DGugaZZ51hb8tN9GeCKIsrmWogMXDIVd0OACBp/EjJVmFB6y0kUCXxUE0TZt0XA1tjAGJcjDUpBvTntZjPsnH/4ZySy+s2d9OOhJ6pzRQBRm360TzkFdSwk9DLiLdGfv4pwMMu/vNGBlqjP/1sQtj+jprJiD1sDbCl4AdQZVoMBQHadF2uSD4/o17XG/Ci0r2h6Htc2yvZMAbEY4zMjjIn2a+vqIxD6onexaek1R3zbkS9j19D6EN9EWn8xgz80YRCyW65znZk8xaIhhvlU/mg7sTxeyuqroBZNcq6uDaQTehDpyH7bY2l4zWRpoj10a6JfH2q5shYz8Y6UZC/kOTfuGqbZDNZWro/9pYquvNNW0M847E5t9bsf9VkAAMHRGBbWoVoU9VpI0UnoXSfvpOo+aXa2DSq5sHHUTVY7A9eov3z5IqT+pligx11xcs+YhDWcU8di3BTJisohKvv5Y8WSkm/rloiZd4ig269k0jTRk1olP/vCksPli4wKG2wdsd5o42nX1yL7mFfXocOANZbB+5qMkiwdyoQSk+Vq+C8nAZx2bbKhUq2MbrORGMzOe0Hh0x2a0PeObycN1Bpyv7Mp3ZI9h5hBnONKCnqMhtyQHUj/nNvbJUnDVYNfoOEqDiEqqEwB7YqWzAKz8KW0OIqdlM8uiQ4JqZZlFllnWJUfaiDrdFM3lYSnFQBkzeVlts6GpDOOBjCYd7dcCNS6kq6pZC6p6HN60Twu0JnurZD6RT7rrPkIGE8vAenFt4iGe/yF52fahCSY8Ws4K0UTwN7bAS+4xRHVCWvE8sMRZsRCHizb5laYsVrPZJhE6+hux6OBb6w8kwPYXc+ud5v6UxawUWgt6uPwl8mlAtU9Z7Miw4Nn/wtBkiLL/ke1UI1gqJtcQXgHxx6mzsjh41+nAgTvdbsSEyU6vfOmxGj3Rwc1eOrIhJUqn5YjOWfzzsz/D5DzWKmwXIwdspt1p+u+kol1N3f2wT9fKPnd/RGCb4g/1hc3Aju4DQYgGY782l89CEEdalpQ/35bQczMFk6Fje12HykakWEXd/bGm9Unh82gH84USiRpeOfQvBDYoqEyrY3zkFZzBjhDqa+jEcAj41tcGx47oSfDq3iVYCdL7HSIjtnyEktVXd7mISZLoMt20JACFcMw+mrbjlug+eU7o2GR7T+LwtOp/p4LZqyLa7oQJDwde1BNZtm3TCK2P1mW94QDL0nDUps5KLtr1DaZXEkRbjSJub2ZE9WqDHyU3KA8G84Tq/rN1IoNu/if45jacyPje1Npj9IftUZSP22nV7HMwZtwQ4P4MYHRMBMGCSqGSIb3DQEJFTEGBAQBAAAAMFsGCSqGSIb3DQEJFDFOHkwAewBCADQAQQA0AEYARQBCADAALQBBADEAOABBAC0ANAA0AEIAQgAtAEIANQBGADIALQA0ADkAMQBFAEYAMQA1ADIAQgBBADEANgB9MF0GCSsGAQQBgjcRATFQHk4ATQBpAGMAcgBvAHMAbwBmAHQAIABTAG8AZgB0AHcAYQByAGUAIABLAGUAeQAgAFMAdABvAHIAYQBnAGUAIABQAHIAbwB2AGkAZABlAHIwggO/BgkqhkiG9w0BBwagggOwMIIDrAIBADCCA6UGCSqGSIb3DQEHATAcBgoqhkiG9w0BDAEGMA4ECEBk5ZAYpu0WAgIH0ICCA3hik4mQFGpw9Ha8TQPtk+j2jwWdxfF0+sTk6S8PTsEfIhB7wPltjiCK92Uv2tCBQnodBUmatIfkpnRDEySmgmdglmOCzj204lWAMRs94PoALGn3JVBXbO1vIDCbAPOZ7Z0Hd0/1t2hmk8v3//QJGUg+qr59/4y/MuVfIg4qfkPcC2QSvYWcK3oTf6SFi5rv9B1IOWFgN5D0+C+x/9Lb/myPYX+rbOHrwtJ4W1fWKoz9g7wwmGFA9IJ2DYGuH8ifVFbDFT1Vcgsvs8arSX7oBsJVW0qrP7XkuDRe3EqCmKW7rBEwYrFznhxZcRDEpMwbFoSvgSIZ4XhFY9VKYglT+JpNH5iDceYEBOQL4vBLpxNUk3l5jKaBNxVa14AIBxq18bVHJ+STInhLhad4u10v/Xbx7wIL3f9DX1yLAkPrpBYbNHS2/ew6H/ySDJnoIDxkw2zZ4qJ+qUJZ1S0lbZVG+VT0OP5uF6tyOSpbMlcGkdl3z254n6MlCrTifcwkzscysDsgKXaYQw06rzrPW6RDub+t+hXzGny799fS9jhQMLDmOggaQ7+LA4oEZsfT89HLMWxJYDqjo3gIfjciV2mV54R684qLDS+AO09U49e6yEbwGlq8lpmO/pbXCbpGbB1b3EomcQbxdWxW2WEkkEd/VBn81K4M3obmywwXJkw+tPXDXfBmzzaqqCR+onMQ5ME1nMkY8ybnfoCc1bDIupjVWsEL2Wvq752RgI6KqzVNr1ew1IdqV5AWN2fOfek+0vi3Jd9FHF3hx8JMwjJL9dZsETV5kHtYJtE7wJ23J68BnCt2eI0GEuwXcCf5EdSKN/xXCTlIokc4Qk/gzRdIZsvcEJ6B1lGovKG54X4IohikqTjiepjbsMWj38yxDmK3mtENZ9ci8FPfbbvIEcOCZIinuY3qFUlRSbx7VUerEoV1IP3clUwexVQo4lHFee2jd7ocWsdSqSapW7OWUupBtDzRkqVhE7tGria+i1W2d6YLlJ21QTjyapWJehAMO637OdbJCCzDs1cXbodRRE7bsP492ocJy8OX66rKdhYbg8srSFNKdb3pF3UDNbN9jhI/t8iagRhNBhlQtTr1me2E/c86Q18qcRXl4bcXTt6acgCeffK6Y26LcVlrgjlD33AEYRRUeyC+rpxbT0aMjdFderlndKRIyG23mSp0HaUwNzAfMAcGBSsOAwIaBBRlviCbIyRrhIysg2dc/KbLFTc2vQQUg4rfwHMM4IKYRD/fsd1x6dda+wQ=`
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		strategy DecodingStrategy
+		in       string
+		expected string
+	}{
+		{"", "aGVsbG8=", "aGVsbG8="},
+		{DecodingNone, "aGVsbG8=", "aGVsbG8="},
+		{DecodingBase64, "aGVsbG8=\n", "hello"},
+		{DecodingBase64URL, "_-8", "\xff\xef"},
+		{DecodingBase64URL, "_-8=", "\xff\xef"},
+		{DecodingHex, "68656c6c6f", "hello"},
+		{DecodingAuto, "aGVsbG8=", "hello"},
+		{DecodingAuto, "_-8=", "\xff\xef"},
+		{DecodingAuto, "_-8", "_-8"},
+		{DecodingAuto, "hello12", "hello12"},
+		{DecodingAuto, "aGVsbG9=", "aGVsbG9="},
+		{DecodingAuto, "hello world", "hello world"},
+	}
+
+	Convey("When decoding values", t, func() {
+		for _, tt := range tests {
+			value := NewValue([]byte(tt.in), "1")
+			decoded, err := Decode(value, tt.strategy)
+			So(err, ShouldBeNil)
+			So(string(decoded.Data), ShouldEqual, tt.expected)
+			So(decoded.Metadata[MetadataVersion], ShouldEqual, "1")
+			So(string(value.Data), ShouldEqual, tt.in)
+		}
+	})
+
+	Convey("When decoding invalid values", t, func() {
+		_, errBase64 := Decode(NewValue([]byte("not base64!"), ""), DecodingBase64)
+		_, errHex := Decode(NewValue([]byte("xyz"), ""), DecodingHex)
+		_, errPKCS12 := Decode(NewValue([]byte("not a PFX"), ""), DecodingPKCS12)
+		_, errStrategy := Decode(NewValue([]byte("hello"), ""), "Rot13")
+		Convey("Then an error is returned", func() {
+			So(errBase64, ShouldNotBeNil)
+			So(errHex, ShouldNotBeNil)
+			So(errPKCS12, ShouldNotBeNil)
+			So(errStrategy, ShouldNotBeNil)
+		})
+	})
+
+	Convey("When decoding a value with an invalid hexadecimal character", t, func() {
+		_, err := Decode(NewValue([]byte("68656c6c6g"), ""), DecodingHex)
+		Convey("Then the error does not leak the character", func() {
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldEqual, "decoding value as Hex: invalid hexadecimal character at offset 9")
+		})
+	})
+
+	Convey("Given a PKCS#12 archive", t, func() {
+		raw, err := base64.StdEncoding.DecodeString(testPFX)
+		So(err, ShouldBeNil)
+
+		for name, data := range map[string][]byte{"raw": raw, "base64": []byte(testPFX)} {
+			Convey("When decoding the "+name+" archive", func() {
+				decoded, err := Decode(NewValue(data, ""), DecodingPKCS12)
+				So(err, ShouldBeNil)
+				Convey("Then the certificate and the private key are written as PEM", func() {
+					crt, err := decoded.Property(PropertyCertificate)
+					So(err, ShouldBeNil)
+					block, _ := pem.Decode(crt)
+					So(block, ShouldNotBeNil)
+					So(block.Type, ShouldEqual, "CERTIFICATE")
+					So(block.Headers, ShouldBeEmpty)
+
+					key, err := decoded.Property(PropertyPrivateKey)
+					So(err, ShouldBeNil)
+					block, _ = pem.Decode(key)
+					So(block, ShouldNotBeNil)
+					So(block.Type, ShouldEndWith, "PRIVATE KEY")
+
+					So(string(decoded.Data), ShouldEqual, string(crt)+string(key))
+				})
+			})
+		}
+	})
+}
@@ -0,0 +1,137 @@
+package backend
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	config "github.com/containersolutions/externalsecret-operator/pkg/config"
+)
+
+// Registry holds the initialized backend instances, keyed by the namespace and
+// name of their SecretStore. It is safe for concurrent use by reconcilers.
+type Registry struct {
+	lock      sync.RWMutex
+	instances map[string]Backend
+	// types holds the backend type of each instance, to look up its timeout
+	types    map[string]string
+	timeouts Timeouts
+	// ambientCredentials allows backends to use the credentials of the operator
+	ambientCredentials bool
+}
+
+// NewRegistry returns an empty Registry
+func NewRegistry() *Registry {
+	return &Registry{
+		instances: make(map[string]Backend),
+		types:     make(map[string]string),
+	}
+}
+
+// Get returns the backend instance named name
+func (r *Registry) Get(name string) (Backend, bool) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	instance, found := r.instances[name]
+	return instance, found
+}
+
+// SetTimeouts sets the timeouts applied to the requests of the backends
+func (r *Registry) SetTimeouts(timeouts Timeouts) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.timeouts = timeouts
+}
+
+// SetAmbientCredentials allows or forbids the backends initialized afterwards
+// to use ambient credentials, see AmbientCredentials
+func (r *Registry) SetAmbientCredentials(allowed bool) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.ambientCredentials = allowed
+}
+
+// Context returns a context bounded by the timeout of the backend named name,
+// it is used for each request made to the provider
+func (r *Registry) Context(ctx context.Context, name string) (context.Context, context.CancelFunc) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	return r.timeouts.context(ctx, r.types[name])
+}
+
+// Init instantiates and initializes a backend named name from config. The
+// instance is only registered once initialized, the instance it replaces is
+// then closed.
+func (r *Registry) Init(ctx context.Context, name string, config *config.Config, credentials []byte) error {
+	log.Info("Init", "availableBackends", strings.Join(availableBackends(), ","))
+
+	instance, err := Instantiate(config.Type)
+	if err != nil {
+		log.Error(err, "")
+		return err
+	}
+
+	r.lock.RLock()
+	timeouts := r.timeouts
+	ambientCredentials := r.ambientCredentials
+	r.lock.RUnlock()
+
+	initCtx, cancel := timeouts.context(ctx, config.Type)
+	defer cancel()
+	if ambientCredentials {
+		initCtx = WithAmbientCredentials(initCtx)
+	}
+
+	log.Info("Initialize", "name", name)
+	err = instance.Init(initCtx, config.Parameters, credentials)
+	if err != nil {
+		return err
+	}
+
+	r.lock.Lock()
+	previous, found := r.instances[name]
+	r.instances[name] = instance
+	r.types[name] = config.Type
+	r.lock.Unlock()
+
+	if closer, ok := previous.(Closer); found && ok {
+		if err := closer.Close(); err != nil {
+			// The new instance is registered, there is nothing to retry
+			log.Error(err, "Failed to close replaced backend", "name", name)
+		}
+	}
+	return nil
+}
+
+// InitFromEnv initializes a backend named name looking into Env for config data
+func (r *Registry) InitFromEnv(ctx context.Context, name string) error {
+	config, err := config.ConfigFromEnv()
+	if err != nil {
+		return err
+	}
+
+	return r.Init(ctx, name, config, []byte(""))
+}
+
+// Remove closes and forgets the backend instance named name
+func (r *Registry) Remove(name string) error {
+	r.lock.Lock()
+	instance, found := r.instances[name]
+	delete(r.instances, name)
+	delete(r.types, name)
+	r.lock.Unlock()
+
+	if !found {
+		return nil
+	}
+
+	log.Info("Remove", "name", name)
+	if closer, ok := instance.(Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Timeouts bounds the duration of the requests made to providers. A zero
+// duration leaves requests bounded only by the context of the reconcile.
+type Timeouts struct {
+	// Default applies to the backend types missing from Types
+	Default time.Duration
+	// Types holds the timeouts of specific backend types
+	Types map[string]time.Duration
+}
+
+// For returns the timeout of requests made by backends of type backendType
+func (t Timeouts) For(backendType string) time.Duration {
+	if timeout, found := t.Types[backendType]; found {
+		return timeout
+	}
+	return t.Default
+}
+
+func (t Timeouts) context(ctx context.Context, backendType string) (context.Context, context.CancelFunc) {
+	timeout := t.For(backendType)
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// ParseTimeouts parses a comma separated list of type=duration pairs,
+// e.g. "asm=10s,gsm=5s"
+func ParseTimeouts(s string) (map[string]time.Duration, error) {
+	timeouts := map[string]time.Duration{}
+	if strings.TrimSpace(s) == "" {
+		return timeouts, nil
+	}
+
+	for _, pair := range strings.Split(s, ",") {
+		parts := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("invalid provider timeout %q, expected type=duration", pair)
+		}
+
+		timeout, err := time.ParseDuration(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid provider timeout %q: %w", pair, err)
+		}
+		timeouts[parts[0]] = timeout
+	}
+
+	return timeouts, nil
+}
@@ -0,0 +1,87 @@
+package backend
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	config "github.com/containersolutions/externalsecret-operator/pkg/config"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestParseTimeouts(t *testing.T) {
+	Convey("When parsing provider timeouts", t, func() {
+		timeouts, err := ParseTimeouts("asm=10s, gsm=500ms")
+		Convey("Then each backend type has its timeout", func() {
+			So(err, ShouldBeNil)
+			So(timeouts, ShouldResemble, map[string]time.Duration{
+				"asm": 10 * time.Second,
+				"gsm": 500 * time.Millisecond,
+			})
+		})
+	})
+
+	Convey("When parsing empty provider timeouts", t, func() {
+		timeouts, err := ParseTimeouts("")
+		Convey("Then there is no timeout", func() {
+			So(err, ShouldBeNil)
+			So(timeouts, ShouldBeEmpty)
+		})
+	})
+
+	Convey("When parsing invalid provider timeouts", t, func() {
+		_, errPair := ParseTimeouts("asm")
+		_, errDuration := ParseTimeouts("asm=10")
+		Convey("Then an error is returned", func() {
+			So(errPair, ShouldNotBeNil)
+			So(errDuration, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestRegistryContext(t *testing.T) {
+	Convey("Given a registry with provider timeouts", t, func() {
+		Register("mock", NewBackend)
+		registry := NewRegistry()
+		registry.SetTimeouts(Timeouts{
+			Default: time.Minute,
+			Types:   map[string]time.Duration{"mock": time.Second},
+		})
+		So(registry.Init(context.Background(), "test-ctrl", &config.Config{
+			Type:       "mock",
+			Parameters: map[string]interface{}{"Param1": "Value1"},
+		}, nil), ShouldBeNil)
+
+		Convey("When getting the context of a backend", func() {
+			ctx, cancel := registry.Context(context.Background(), "test-ctrl")
+			defer cancel()
+			deadline, ok := ctx.Deadline()
+			Convey("Then it is bounded by the timeout of its type", func() {
+				So(ok, ShouldBeTrue)
+				So(time.Until(deadline), ShouldBeLessThanOrEqualTo, time.Second)
+			})
+		})
+
+		Convey("When getting the context of an unknown backend", func() {
+			ctx, cancel := registry.Context(context.Background(), "unknown-ctrl")
+			defer cancel()
+			deadline, ok := ctx.Deadline()
+			Convey("Then it is bounded by the default timeout", func() {
+				So(ok, ShouldBeTrue)
+				So(time.Until(deadline), ShouldBeGreaterThan, time.Second)
+			})
+		})
+	})
+
+	Convey("Given a registry without timeouts", t, func() {
+		registry := NewRegistry()
+		Convey("When getting the context of a backend", func() {
+			ctx, cancel := registry.Context(context.Background(), "test-ctrl")
+			defer cancel()
+			_, ok := ctx.Deadline()
+			Convey("Then it has no deadline", func() {
+				So(ok, ShouldBeFalse)
+			})
+		})
+	})
+}
@@ -0,0 +1,79 @@
+package backend
+
+import (
+	"fmt"
+	"strings"
+)
+
+// VersionKind is the form of the version of an ExternalSecret key
+type VersionKind string
+
+const (
+	// VersionKindLatest retrieves the latest version, it is the empty version or "latest"
+	VersionKindLatest VersionKind = "latest"
+	// VersionKindID retrieves an explicit version id, e.g. a GSM version number,
+	// an ASM VersionId or an AKV version
+	VersionKindID VersionKind = "id"
+	// VersionKindStage retrieves the version holding an AWS version stage,
+	// "stage:<name>" or one of AWSCURRENT and AWSPREVIOUS
+	VersionKindStage VersionKind = "stage"
+	// VersionKindAlias retrieves the version a GSM alias points to, "alias:<name>"
+	VersionKindAlias VersionKind = "alias"
+)
+
+// Version is a parsed ExternalSecret key version
+type Version struct {
+	Kind VersionKind
+	// Value is the version id, stage or alias, empty for the latest version
+	Value string
+}
+
+// VersionKinds is implemented by backends supporting other version forms than
+// the latest version and version ids
+type VersionKinds interface {
+	VersionKinds() []VersionKind
+}
+
+// ParseVersion parses the version of an ExternalSecret key
+func ParseVersion(version string) (Version, error) {
+	switch {
+	case version == "" || version == string(VersionKindLatest):
+		return Version{Kind: VersionKindLatest}, nil
+	case version == "AWSCURRENT" || version == "AWSPREVIOUS":
+		return Version{Kind: VersionKindStage, Value: version}, nil
+	}
+
+	for _, kind := range []VersionKind{VersionKindStage, VersionKindAlias} {
+		prefix := string(kind) + ":"
+		if !strings.HasPrefix(version, prefix) {
+			continue
+		}
+		value := strings.TrimPrefix(version, prefix)
+		if value == "" {
+			return Version{}, fmt.Errorf("version %q: missing %s name", version, kind)
+		}
+		return Version{Kind: kind, Value: value}, nil
+	}
+
+	return Version{Kind: VersionKindID, Value: version}, nil
+}
+
+// SupportsVersion returns an error when b does not support the form of version
+func SupportsVersion(b Backend, version string) error {
+	parsed, err := ParseVersion(version)
+	if err != nil {
+		return err
+	}
+
+	kinds := []VersionKind{VersionKindLatest, VersionKindID}
+	if withKinds, ok := b.(VersionKinds); ok {
+		kinds = withKinds.VersionKinds()
+	}
+
+	for _, kind := range kinds {
+		if kind == parsed.Kind {
+			return nil
+		}
+	}
+	return fmt.Errorf("version %q: %s versions are not supported by this backend", version, parsed.Kind)
+}
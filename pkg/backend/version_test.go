@@ -0,0 +1,70 @@
+package backend
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type stagedMockBackend struct {
+	MockBackend
+}
+
+func (m *stagedMockBackend) VersionKinds() []VersionKind {
+	return []VersionKind{VersionKindLatest, VersionKindStage}
+}
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		in       string
+		expected Version
+	}{
+		{"", Version{Kind: VersionKindLatest}},
+		{"latest", Version{Kind: VersionKindLatest}},
+		{"3", Version{Kind: VersionKindID, Value: "3"}},
+		{"AWSCURRENT", Version{Kind: VersionKindStage, Value: "AWSCURRENT"}},
+		{"AWSPREVIOUS", Version{Kind: VersionKindStage, Value: "AWSPREVIOUS"}},
+		{"stage:blue", Version{Kind: VersionKindStage, Value: "blue"}},
+		{"alias:prod", Version{Kind: VersionKindAlias, Value: "prod"}},
+	}
+
+	Convey("When parsing versions", t, func() {
+		for _, tt := range tests {
+			version, err := ParseVersion(tt.in)
+			So(err, ShouldBeNil)
+			So(version, ShouldResemble, tt.expected)
+		}
+	})
+
+	Convey("When parsing a stage or alias without name", t, func() {
+		_, errStage := ParseVersion("stage:")
+		_, errAlias := ParseVersion("alias:")
+		Convey("Then an error is returned", func() {
+			So(errStage, ShouldNotBeNil)
+			So(errAlias, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestSupportsVersion(t *testing.T) {
+	Convey("Given a backend without declared version kinds", t, func() {
+		b := &MockBackend{}
+		Convey("Then latest versions and version ids are supported", func() {
+			So(SupportsVersion(b, ""), ShouldBeNil)
+			So(SupportsVersion(b, "2"), ShouldBeNil)
+			So(SupportsVersion(b, "AWSCURRENT"), ShouldNotBeNil)
+			So(SupportsVersion(b, "alias:prod"), ShouldNotBeNil)
+		})
+	})
+
+	Convey("Given a backend declaring its version kinds", t, func() {
+		b := &stagedMockBackend{}
+		Convey("Then only those are supported", func() {
+			So(SupportsVersion(b, "latest"), ShouldBeNil)
+			So(SupportsVersion(b, "stage:blue"), ShouldBeNil)
+			err := SupportsVersion(b, "2")
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldEqual, `version "2": id versions are not supported by this backend`)
+		})
+	})
+}
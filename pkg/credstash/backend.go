@@ -3,11 +3,18 @@
 package credstash
 
 import (
+	"context"
+	"crypto/hmac"
+	"encoding/base64"
 	"fmt"
 	"strconv"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/aws/aws-sdk-go/service/kms/kmsiface"
 	"github.com/containersolutions/externalsecret-operator/pkg/backend"
 	"github.com/containersolutions/externalsecret-operator/pkg/utils"
 	unicreds "github.com/versent/unicreds"
@@ -17,51 +24,123 @@ import (
 
 const (
 	defaultRegion          = "eu-west-2"
+	defaultTable           = "credential-store"
 	credstashVersionLength = 19
 )
 
-var (
-	log                     = ctrl.Log.WithName("credstash")
-	table                   = ""
-	configEncryptionContext = make(map[string]string)
-)
+var log = ctrl.Log.WithName("credstash")
 
 // SecretManagerClientProvider will be our unicreds client
 type SecretManagerClientProvider interface {
-	SetKMSConfig(config *aws.Config)
-	SetDynamoDBConfig(config *aws.Config)
-	GetHighestVersionSecret(tableName *string, name string, encContext *unicreds.EncryptionContextValue) (*unicreds.DecryptedCredential, error)
-	GetSecret(tableName *string, name string, version string, encContext *unicreds.EncryptionContextValue) (*unicreds.DecryptedCredential, error)
+	GetHighestVersionSecret(ctx context.Context, tableName *string, name string, encContext *unicreds.EncryptionContextValue) (*unicreds.DecryptedCredential, error)
+	GetSecret(ctx context.Context, tableName *string, name string, version string, encContext *unicreds.EncryptionContextValue) (*unicreds.DecryptedCredential, error)
 }
 
-// SecretManagerClient defining this struct to write methods for it
+// SecretManagerClient reads and decrypts credstash secrets with its own
+// DynamoDB and KMS clients, unlike the unicreds functions that share
+// process-wide clients between all backends
 type SecretManagerClient struct {
+	DynamoDB dynamodbiface.DynamoDBAPI
+	KMS      kmsiface.KMSAPI
 }
 
-// SetKMSConfig sets configuration for KMS access
-func (s SecretManagerClient) SetKMSConfig(config *aws.Config) {
-	unicreds.SetKMSConfig(config)
-}
-
-// SetDynamoDBConfig sets configuration for DynamoDB access
-func (s SecretManagerClient) SetDynamoDBConfig(config *aws.Config) {
-	unicreds.SetDynamoDBConfig(config)
+// NewSecretManagerClient returns a SecretManagerClient using sess for DynamoDB and KMS
+func NewSecretManagerClient(sess *session.Session) *SecretManagerClient {
+	return &SecretManagerClient{
+		DynamoDB: dynamodb.New(sess),
+		KMS:      kms.New(sess),
+	}
 }
 
 // GetHighestVersionSecret gets a secret with latest version from credstash
-func (s SecretManagerClient) GetHighestVersionSecret(tableName *string, name string, encContext *unicreds.EncryptionContextValue) (*unicreds.DecryptedCredential, error) {
-	return unicreds.GetHighestVersionSecret(tableName, name, encContext)
+func (s *SecretManagerClient) GetHighestVersionSecret(ctx context.Context, tableName *string, name string, encContext *unicreds.EncryptionContextValue) (*unicreds.DecryptedCredential, error) {
+	res, err := s.DynamoDB.QueryWithContext(ctx, &dynamodb.QueryInput{
+		TableName: tableName,
+		ExpressionAttributeNames: map[string]*string{
+			"#N": aws.String("name"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":name": {S: aws.String(name)},
+		},
+		KeyConditionExpression: aws.String("#N = :name"),
+		Limit:                  aws.Int64(1),
+		ConsistentRead:         aws.Bool(true),
+		ScanIndexForward:       aws.Bool(false), // descending order
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(res.Items) == 0 {
+		return nil, unicreds.ErrSecretNotFound
+	}
+
+	return s.decryptItem(ctx, res.Items[0], encContext)
 }
 
 // GetSecret gets a secret with specific version from credstash
-func (s SecretManagerClient) GetSecret(tableName *string, name string, version string, encContext *unicreds.EncryptionContextValue) (*unicreds.DecryptedCredential, error) {
-	return unicreds.GetSecret(tableName, name, version, encContext)
+func (s *SecretManagerClient) GetSecret(ctx context.Context, tableName *string, name string, version string, encContext *unicreds.EncryptionContextValue) (*unicreds.DecryptedCredential, error) {
+	res, err := s.DynamoDB.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		Key: map[string]*dynamodb.AttributeValue{
+			"name":    {S: aws.String(name)},
+			"version": {S: aws.String(version)},
+		},
+		TableName: tableName,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(res.Item) == 0 {
+		return nil, unicreds.ErrSecretNotFound
+	}
+
+	return s.decryptItem(ctx, res.Item, encContext)
+}
+
+// decryptItem decrypts a credstash DynamoDB item the way unicreds does: the
+// KMS data key holds the AES key and the HMAC key of the contents
+func (s *SecretManagerClient) decryptItem(ctx context.Context, item map[string]*dynamodb.AttributeValue, encContext *unicreds.EncryptionContextValue) (*unicreds.DecryptedCredential, error) {
+	cred := &unicreds.Credential{}
+	if err := unicreds.Decode(item, cred); err != nil {
+		return nil, err
+	}
+
+	wrappedKey, err := base64.StdEncoding.DecodeString(cred.Key)
+	if err != nil {
+		return nil, err
+	}
+	dataKey, err := s.KMS.DecryptWithContext(ctx, &kms.DecryptInput{
+		CiphertextBlob:    wrappedKey,
+		EncryptionContext: *encContext,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(dataKey.Plaintext) != 64 {
+		return nil, fmt.Errorf("unexpected credstash data key length %d", len(dataKey.Plaintext))
+	}
+
+	contents, err := base64.StdEncoding.DecodeString(cred.Contents)
+	if err != nil {
+		return nil, err
+	}
+	if !hmac.Equal(unicreds.ComputeHmac256(contents, dataKey.Plaintext[32:]), cred.Hmac) {
+		return nil, unicreds.ErrHmacValidationFailed
+	}
+
+	secret, err := unicreds.Decrypt(dataKey.Plaintext[:32], contents)
+	if err != nil {
+		return nil, err
+	}
+
+	return &unicreds.DecryptedCredential{Credential: cred, Secret: string(secret)}, nil
 }
 
 // Backend represents a backend for Credstash
 type Backend struct {
-	SecretsManager SecretManagerClientProvider
-	session        *session.Session
+	SecretsManager    SecretManagerClientProvider
+	session           *session.Session
+	table             string
+	encryptionContext map[string]string
 }
 
 func init() {
@@ -74,74 +153,127 @@ func NewBackend() backend.Backend {
 }
 
 // Init initializes the Backend for Credstash
-func (s *Backend) Init(parameters map[string]interface{}, credentials []byte) error {
+func (s *Backend) Init(ctx context.Context, parameters map[string]interface{}, credentials []byte) error {
 	var err error
-	s.SecretsManager = SecretManagerClient{}
 
-	s.session, err = utils.GetAWSSession(parameters, credentials, defaultRegion)
+	s.session, err = utils.GetAWSSession(ctx, parameters, credentials, defaultRegion)
 	if err != nil {
 		return err
 	}
-	var ok bool
-	table, ok = parameters["table"].(string)
-	if !ok {
-		log.Error(nil, "Credstash Dynamo DB table key missing")
-		return nil
+
+	s.table = defaultTable
+	if table, ok := parameters["table"]; ok {
+		tableName, ok := table.(string)
+		if !ok || tableName == "" {
+			return fmt.Errorf("invalid Credstash DynamoDB table parameter: %v", table)
+		}
+		s.table = tableName
 	}
 
-	configEncryptionContext, ok = parameters["encryptionContext"].(map[string]string)
-	if !ok {
+	s.encryptionContext, err = parseEncryptionContext(parameters["encryptionContext"])
+	if err != nil {
+		return err
+	}
+	if len(s.encryptionContext) == 0 {
 		log.Info("Not using security encryption context. Consider using it")
 	}
 
-	s.SecretsManager.SetKMSConfig(s.session.Config)
-	s.SecretsManager.SetDynamoDBConfig(s.session.Config)
+	s.SecretsManager = NewSecretManagerClient(s.session)
 	return nil
 }
 
-// Get retrieves the secret associated with key from Credstash
-func (s *Backend) Get(key string, version string) (string, error) {
-	if table == "" {
-		table = "credential-store"
+// parseEncryptionContext returns the encryptionContext parameter as strings,
+// it is a map[string]interface{} once decoded from the SecretStore JSON
+func parseEncryptionContext(parameter interface{}) (map[string]string, error) {
+	encryptionContext := map[string]string{}
+	switch values := parameter.(type) {
+	case nil:
+	case map[string]string:
+		for k, v := range values {
+			encryptionContext[k] = v
+		}
+	case map[string]interface{}:
+		for k, v := range values {
+			switch v.(type) {
+			case string, bool, float64, int, int64:
+				encryptionContext[k] = fmt.Sprint(v)
+			default:
+				return nil, fmt.Errorf("invalid Credstash encryptionContext value for %s: %v", k, v)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("invalid Credstash encryptionContext parameter: %v", parameter)
 	}
+	return encryptionContext, nil
+}
 
+// Get retrieves the secret associated with key from Credstash
+func (s *Backend) Get(ctx context.Context, key string, version string) (*backend.Value, error) {
 	if s.SecretsManager == nil {
 		log.Error(fmt.Errorf("error"), "backend not initialized")
-		return "", fmt.Errorf("backend not initialized")
+		return nil, fmt.Errorf("backend not initialized")
 	}
 
 	encryptionContext := unicreds.NewEncryptionContextValue()
-	for k, v := range configEncryptionContext {
-		if err := encryptionContext.Set(k + ":" + v); err != nil {
-			return "", err
-		}
+	for k, v := range s.encryptionContext {
+		(*encryptionContext)[k] = aws.String(v)
 	}
-	if version == "" {
-		creds, err := s.SecretsManager.GetHighestVersionSecret(aws.String(table), key, encryptionContext)
+	parsed, err := backend.ParseVersion(version)
+	if err != nil {
+		return nil, err
+	}
+	if parsed.Kind != backend.VersionKindLatest && parsed.Kind != backend.VersionKindID {
+		return nil, fmt.Errorf("version %q: %s versions are not supported by credstash", version, parsed.Kind)
+	}
+	version = parsed.Value
+
+	if parsed.Kind == backend.VersionKindLatest {
+		creds, err := s.SecretsManager.GetHighestVersionSecret(ctx, aws.String(s.table), key, encryptionContext)
 		if err != nil {
 			log.Error(err, "Failed fetching secret from credstash",
-				"Secret.Key", key, "Secret.Version", "latest", "Secret.Table", table, "Secret.Context", configEncryptionContext)
+				"Secret.Key", key, "Secret.Version", "latest", "Secret.Table", s.table, "Secret.Context", s.encryptionContext)
 
-			return "", err
+			return nil, err
 		}
 
-		return creds.Secret, nil
+		return credentialValue(creds), nil
 	}
 	formattedVersion, err := formatCredstashVersion(version)
 	if err != nil {
 		log.Error(err, "Failed formatting secret version",
-			"Secret.Key", key, "Secret.Version", version, "Secret.Table", table, "Secret.Context", configEncryptionContext)
-		return "", err
+			"Secret.Key", key, "Secret.Version", version, "Secret.Table", s.table, "Secret.Context", s.encryptionContext)
+		return nil, err
 	}
 
-	creds, err := s.SecretsManager.GetSecret(aws.String(table), key, formattedVersion, encryptionContext)
+	creds, err := s.SecretsManager.GetSecret(ctx, aws.String(s.table), key, formattedVersion, encryptionContext)
 	if err != nil {
 		log.Error(err, "Failed fetching secret from credstash",
-			"Secret.Key", key, "Secret.Version", formattedVersion, "Secret.Table", table, "Secret.Context", configEncryptionContext)
-		return "", err
+			"Secret.Key", key, "Secret.Version", formattedVersion, "Secret.Table", s.table, "Secret.Context", s.encryptionContext)
+		return nil, err
 	}
 
-	return creds.Secret, nil
+	return credentialValue(creds), nil
+}
+
+// GetPrevious retrieves the version of key preceding the version of current
+func (s *Backend) GetPrevious(ctx context.Context, key string, current *backend.Value) (*backend.Value, error) {
+	number, err := strconv.Atoi(current.Metadata[backend.MetadataVersion])
+	if err != nil {
+		return nil, fmt.Errorf("version of %s is not a version number: %v", key, err)
+	}
+	if number <= 1 {
+		return nil, nil
+	}
+	return s.Get(ctx, key, strconv.Itoa(number-1))
+}
+
+// credentialValue returns the secret and version of a decrypted credential
+func credentialValue(creds *unicreds.DecryptedCredential) *backend.Value {
+	version := ""
+	if creds.Credential != nil {
+		version = creds.Credential.Version
+	}
+	return backend.NewValue([]byte(creds.Secret), version)
 }
 
 func formatCredstashVersion(inputVersion string) (string, error) {
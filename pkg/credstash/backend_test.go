@@ -1,10 +1,20 @@
 package credstash
 
 import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"reflect"
 	"testing"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/aws/aws-sdk-go/service/kms/kmsiface"
 	"github.com/aws/aws-sdk-go/service/secretsmanager/secretsmanageriface"
+	backendpkg "github.com/containersolutions/externalsecret-operator/pkg/backend"
 	. "github.com/smartystreets/goconvey/convey"
 	unicreds "github.com/versent/unicreds"
 )
@@ -15,23 +25,73 @@ type mockedSecretsManager struct {
 }
 
 // GetHighestVersionSecret mocked to return expected value
-func (s mockedSecretsManager) GetHighestVersionSecret(tableName *string, name string, encContext *unicreds.EncryptionContextValue) (*unicreds.DecryptedCredential, error) {
+func (s mockedSecretsManager) GetHighestVersionSecret(ctx context.Context, tableName *string, name string, encContext *unicreds.EncryptionContextValue) (*unicreds.DecryptedCredential, error) {
 	var cred *unicreds.Credential
 	return &unicreds.DecryptedCredential{Credential: cred, Secret: "secretValue"}, nil
 }
 
 // GetSecret mocked to return expected value
-func (s mockedSecretsManager) GetSecret(tableName *string, name string, version string, encContext *unicreds.EncryptionContextValue) (*unicreds.DecryptedCredential, error) {
-	var cred *unicreds.Credential
+func (s mockedSecretsManager) GetSecret(ctx context.Context, tableName *string, name string, version string, encContext *unicreds.EncryptionContextValue) (*unicreds.DecryptedCredential, error) {
+	cred := &unicreds.Credential{Version: version}
 	return &unicreds.DecryptedCredential{Credential: cred, Secret: "secretValue"}, nil
 }
 
-// SetKMSConfig sets configuration for KMS access
-func (s mockedSecretsManager) SetKMSConfig(config *aws.Config) {
+// mockedDynamoDB stores credstash items of a single table
+type mockedDynamoDB struct {
+	dynamodbiface.DynamoDBAPI
+	table string
+	items []map[string]*dynamodb.AttributeValue
 }
 
-// SetDynamoDBConfig sets configuration for DynamoDB access
-func (s mockedSecretsManager) SetDynamoDBConfig(config *aws.Config) {
+// QueryWithContext mocked to return the last item of the table
+func (m *mockedDynamoDB) QueryWithContext(ctx aws.Context, input *dynamodb.QueryInput, opts ...request.Option) (*dynamodb.QueryOutput, error) {
+	if *input.TableName != m.table {
+		return nil, fmt.Errorf("table %s not found", *input.TableName)
+	}
+	return &dynamodb.QueryOutput{Items: m.items[len(m.items)-1:]}, nil
+}
+
+// GetItemWithContext mocked to return the item with the requested version
+func (m *mockedDynamoDB) GetItemWithContext(ctx aws.Context, input *dynamodb.GetItemInput, opts ...request.Option) (*dynamodb.GetItemOutput, error) {
+	if *input.TableName != m.table {
+		return nil, fmt.Errorf("table %s not found", *input.TableName)
+	}
+	for _, item := range m.items {
+		if *item["version"].S == *input.Key["version"].S {
+			return &dynamodb.GetItemOutput{Item: item}, nil
+		}
+	}
+	return &dynamodb.GetItemOutput{}, nil
+}
+
+// mockedKMS decrypts data keys requiring an encryption context
+type mockedKMS struct {
+	kmsiface.KMSAPI
+	dataKey           []byte
+	encryptionContext map[string]*string
+}
+
+// DecryptWithContext mocked to return the data key when the encryption context matches
+func (m *mockedKMS) DecryptWithContext(ctx aws.Context, input *kms.DecryptInput, opts ...request.Option) (*kms.DecryptOutput, error) {
+	if !reflect.DeepEqual(input.EncryptionContext, m.encryptionContext) {
+		return nil, fmt.Errorf("InvalidCiphertextException")
+	}
+	return &kms.DecryptOutput{Plaintext: m.dataKey}, nil
+}
+
+// credstashItem encrypts secret like a credstash client
+func credstashItem(dataKey []byte, name, version, secret string) map[string]*dynamodb.AttributeValue {
+	contents, err := unicreds.Encrypt(dataKey[:32], []byte(secret))
+	So(err, ShouldBeNil)
+	item, err := unicreds.Encode(&unicreds.Credential{
+		Name:     name,
+		Version:  version,
+		Key:      base64.StdEncoding.EncodeToString([]byte("wrapped-key")),
+		Contents: base64.StdEncoding.EncodeToString(contents),
+		Hmac:     unicreds.ComputeHmac256(contents, dataKey[32:]),
+	})
+	So(err, ShouldBeNil)
+	return item
 }
 
 func TestNewBackend(t *testing.T) {
@@ -51,7 +111,7 @@ func TestGet(t *testing.T) {
 	Convey("Given an uninitialized CredstashSecretsManagerBackend", t, func() {
 		backend := Backend{}
 		Convey("When retrieving a secret", func() {
-			_, err := backend.Get(secretKey, keyVersion)
+			_, err := backend.Get(context.Background(), secretKey, keyVersion)
 			Convey("Then an error is returned", func() {
 				So(err, ShouldNotBeNil)
 				So(err.Error(), ShouldEqual, "backend not initialized")
@@ -63,10 +123,113 @@ func TestGet(t *testing.T) {
 		backend := Backend{}
 		backend.SecretsManager = mockedSecretsManager{}
 		Convey("When retrieving a secret", func() {
-			actualValue, err := backend.Get(secretKey, keyVersion)
+			actualValue, err := backend.Get(context.Background(), secretKey, keyVersion)
 			Convey("Then no error is returned", func() {
 				So(err, ShouldBeNil)
-				So(actualValue, ShouldEqual, expectedValue)
+				So(string(actualValue.Data), ShouldEqual, expectedValue)
+			})
+		})
+		Convey("When retrieving a secret by version stage", func() {
+			_, err := backend.Get(context.Background(), secretKey, "stage:AWSCURRENT")
+			Convey("Then an error is returned", func() {
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldContainSubstring, "not supported by credstash")
+			})
+		})
+	})
+}
+
+func TestSecretManagerClient(t *testing.T) {
+	dataKey := []byte("0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef")
+
+	Convey("Given two initialized Credstash backends with different tables and encryption contexts", t, func() {
+		first := Backend{
+			SecretsManager: &SecretManagerClient{
+				DynamoDB: &mockedDynamoDB{table: "first-store", items: []map[string]*dynamodb.AttributeValue{
+					credstashItem(dataKey, "secret", "0000000000000000001", "firstValue"),
+					credstashItem(dataKey, "secret", "0000000000000000002", "firstValue2"),
+				}},
+				KMS: &mockedKMS{dataKey: dataKey, encryptionContext: map[string]*string{"app": aws.String("first")}},
+			},
+			table:             "first-store",
+			encryptionContext: map[string]string{"app": "first"},
+		}
+		second := Backend{
+			SecretsManager: &SecretManagerClient{
+				DynamoDB: &mockedDynamoDB{table: "second-store", items: []map[string]*dynamodb.AttributeValue{
+					credstashItem(dataKey, "secret", "0000000000000000001", "secondValue"),
+				}},
+				KMS: &mockedKMS{dataKey: dataKey, encryptionContext: map[string]*string{}},
+			},
+			table:             "second-store",
+			encryptionContext: map[string]string{},
+		}
+
+		Convey("When retrieving the latest version of a secret from each backend", func() {
+			firstValue, firstErr := first.Get(context.Background(), "secret", "")
+			secondValue, secondErr := second.Get(context.Background(), "secret", "latest")
+			Convey("Then each backend reads and decrypts from its own table", func() {
+				So(firstErr, ShouldBeNil)
+				So(string(firstValue.Data), ShouldEqual, "firstValue2")
+				So(firstValue.Metadata[backendpkg.MetadataVersion], ShouldEqual, "0000000000000000002")
+				So(secondErr, ShouldBeNil)
+				So(string(secondValue.Data), ShouldEqual, "secondValue")
+			})
+		})
+
+		Convey("When retrieving a specific version of a secret", func() {
+			value, err := first.Get(context.Background(), "secret", "1")
+			Convey("Then that version is decrypted", func() {
+				So(err, ShouldBeNil)
+				So(string(value.Data), ShouldEqual, "firstValue")
+			})
+		})
+
+		Convey("When retrieving a missing version of a secret", func() {
+			_, err := second.Get(context.Background(), "secret", "2")
+			Convey("Then a not found error is returned", func() {
+				So(err, ShouldEqual, unicreds.ErrSecretNotFound)
+			})
+		})
+
+		Convey("When the encryption context does not match", func() {
+			first.encryptionContext = map[string]string{"app": "other"}
+			_, err := first.Get(context.Background(), "secret", "")
+			Convey("Then an error is returned", func() {
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldEqual, "InvalidCiphertextException")
+			})
+		})
+
+		Convey("When the contents were tampered with", func() {
+			dynamoDB := first.SecretsManager.(*SecretManagerClient).DynamoDB.(*mockedDynamoDB)
+			item := credstashItem(dataKey, "secret", "0000000000000000003", "firstValue3")
+			item["hmac"] = &dynamodb.AttributeValue{B: []byte("invalid")}
+			dynamoDB.items = append(dynamoDB.items, item)
+			_, err := first.Get(context.Background(), "secret", "")
+			Convey("Then the HMAC validation fails", func() {
+				So(err, ShouldEqual, unicreds.ErrHmacValidationFailed)
+			})
+		})
+	})
+}
+
+func TestGetPrevious(t *testing.T) {
+	Convey("Given an initialized CredstashSecretsManagerBackend", t, func() {
+		backend := Backend{}
+		backend.SecretsManager = mockedSecretsManager{}
+		Convey("When retrieving the previous version of a secret", func() {
+			previous, err := backend.GetPrevious(context.Background(), "secret", backendpkg.NewValue([]byte("secretValue"), "0000000000000000003"))
+			Convey("Then the preceding version is returned", func() {
+				So(err, ShouldBeNil)
+				So(previous.Metadata[backendpkg.MetadataVersion], ShouldEqual, "0000000000000000002")
+			})
+		})
+		Convey("When retrieving the previous version of the first version", func() {
+			previous, err := backend.GetPrevious(context.Background(), "secret", backendpkg.NewValue([]byte("secretValue"), "0000000000000000001"))
+			Convey("Then there is none", func() {
+				So(err, ShouldBeNil)
+				So(previous, ShouldBeNil)
 			})
 		})
 	})
@@ -200,6 +363,30 @@ func TestInit(t *testing.T) {
 			expectedSecretAccessKey: "VGhhdEtleQoVGhhdEtleQo",
 			expectedSessionToken:    "tZWtletZWtle",
 		},
+
+		{
+			credentials: `{
+				"accessKeyID":     "some",
+				"secretAccessKey": "VGhhdEtleQoVGhhdEtleQo",
+				"sessionToken": ""
+			}`,
+			parameters: map[string]interface{}{
+				"region": "eu-west-1",
+				"encryptionContext": map[string]interface{}{
+					"securityKey": "securityValue",
+					"tier":        float64(2),
+				},
+			},
+			expectedAccessKeyID: "some",
+			expectedRegion:      "eu-west-1",
+			expectedTable:       "credential-store",
+			expectedEncryptionContext: map[string]string{
+				"securityKey": "securityValue",
+				"tier":        "2",
+			},
+			expectedSecretAccessKey: "VGhhdEtleQoVGhhdEtleQo",
+			expectedSessionToken:    "",
+		},
 	}
 
 	for _, test := range tests {
@@ -207,7 +394,7 @@ func TestInit(t *testing.T) {
 
 			Convey("When initializing an Credstash backend", func() {
 				b := Backend{}
-				err := b.Init(test.parameters, []byte(test.credentials))
+				err := b.Init(context.Background(), test.parameters, []byte(test.credentials))
 				So(err, ShouldBeNil)
 				Convey("Then credentials are reflected in the AWS session", func() {
 					actualCredentials, err := b.session.Config.Credentials.Get()
@@ -216,12 +403,30 @@ func TestInit(t *testing.T) {
 					So(actualCredentials.AccessKeyID, ShouldEqual, test.expectedAccessKeyID)
 					So(actualCredentials.SecretAccessKey, ShouldEqual, test.expectedSecretAccessKey)
 					So(actualCredentials.SessionToken, ShouldEqual, test.expectedSessionToken)
+					So(b.table, ShouldEqual, test.expectedTable)
+					So(b.encryptionContext, ShouldResemble, test.expectedEncryptionContext)
 				})
 			})
 
 		})
 	}
 
+	Convey("When invalid table or encryption context parameters are passed", t, func() {
+		credentials := []byte(`{"accessKeyID": "some", "secretAccessKey": "VGhhdEtleQoVGhhdEtleQo"}`)
+		b := Backend{}
+		errTable := b.Init(context.Background(), map[string]interface{}{"region": "eu-west-2", "table": ""}, credentials)
+		errContext := b.Init(context.Background(), map[string]interface{}{
+			"region":            "eu-west-2",
+			"encryptionContext": map[string]interface{}{"securityKey": []interface{}{"a", "b"}},
+		}, credentials)
+		Convey("Then an error is returned", func() {
+			So(errTable, ShouldNotBeNil)
+			So(errTable.Error(), ShouldContainSubstring, "invalid Credstash DynamoDB table parameter")
+			So(errContext, ShouldNotBeNil)
+			So(errContext.Error(), ShouldContainSubstring, "invalid Credstash encryptionContext value for securityKey")
+		})
+	})
+
 	Convey("When missing region parameter", t, func() {
 		testParams := credentialsAndParametersTest{
 			credentials: `{
@@ -237,7 +442,7 @@ func TestInit(t *testing.T) {
 		}
 
 		b := Backend{}
-		err := b.Init(testParams.parameters, []byte(testParams.credentials))
+		err := b.Init(context.Background(), testParams.parameters, []byte(testParams.credentials))
 		Convey("Then an error is returned", func() {
 			So(err, ShouldNotBeNil)
 			So(err.Error(), ShouldEqual, "AWS region parameter missing")
@@ -246,8 +451,8 @@ func TestInit(t *testing.T) {
 
 	Convey("When invalid credentials are passed", t, func() {
 		testParams := credentialsAndParametersTest{
-			credentials:             "",
-			parameters:              map[string]interface{}{},
+			credentials:             "{",
+			parameters:              map[string]interface{}{"region": "eu-west-2"},
 			expectedAccessKeyID:     "AKIABLABLA",
 			expectedRegion:          "eu-mediterranean-1",
 			expectedSecretAccessKey: "CredSsecrets",
@@ -255,7 +460,7 @@ func TestInit(t *testing.T) {
 		}
 
 		b := Backend{}
-		err := b.Init(testParams.parameters, []byte(testParams.credentials))
+		err := b.Init(context.Background(), testParams.parameters, []byte(testParams.credentials))
 		Convey("Then an error is returned", func() {
 			So(err, ShouldNotBeNil)
 			So(err.Error(), ShouldEqual, "unexpected end of JSON input")
@@ -4,6 +4,7 @@
 package dummy
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/containersolutions/externalsecret-operator/pkg/backend"
@@ -27,7 +28,7 @@ func NewBackend() backend.Backend {
 }
 
 // Init implements SecretsBackend interface, sets the suffix
-func (d *Backend) Init(parameters map[string]interface{}, credentials []byte) error {
+func (d *Backend) Init(ctx context.Context, parameters map[string]interface{}, credentials []byte) error {
 	if len(parameters) == 0 {
 		log.Error(fmt.Errorf("error"), "empty or invalid parameters: ")
 		return fmt.Errorf("empty or invalid parameters")
@@ -44,18 +45,54 @@ func (d *Backend) Init(parameters map[string]interface{}, credentials []byte) er
 }
 
 // Get a key and returns a fake secrets key + suffix
-func (d *Backend) Get(key string, version string) (string, error) {
+func (d *Backend) Get(ctx context.Context, key string, version string) (*backend.Value, error) {
 	if d.suffix == "" {
-		return "", fmt.Errorf("backend is not initialized")
+		return nil, fmt.Errorf("backend is not initialized")
 	}
 
 	if key == "" {
-		return "", fmt.Errorf("empty key provided")
+		return nil, fmt.Errorf("empty key provided")
 	}
 
 	if key == "ErroredKey" {
-		return "", fmt.Errorf("Mocked error")
+		return nil, fmt.Errorf("Mocked error")
 	}
 
-	return key + version + d.suffix, nil
+	return backend.NewValue([]byte(key+version+d.suffix), version), nil
+}
+
+// GetPrevious returns a fake previous version of key, key + "previous" + suffix
+func (d *Backend) GetPrevious(ctx context.Context, key string, current *backend.Value) (*backend.Value, error) {
+	if d.suffix == "" {
+		return nil, fmt.Errorf("backend is not initialized")
+	}
+	return backend.NewValue([]byte(key+"previous"+d.suffix), "previous"), nil
+}
+
+// ListedKeys are the keys returned by List
+var ListedKeys = []string{"listed-key", "other-listed-key"}
+
+// List returns a fake value for each of ListedKeys, key + suffix
+func (d *Backend) List(ctx context.Context) (map[string]*backend.Value, error) {
+	if d.suffix == "" {
+		return nil, fmt.Errorf("backend is not initialized")
+	}
+	values := make(map[string]*backend.Value, len(ListedKeys))
+	for _, key := range ListedKeys {
+		values[key] = backend.NewValue([]byte(key+d.suffix), "")
+	}
+	return values, nil
+}
+
+// VersionKinds implements backend.VersionKinds, the dummy backend accepts any version
+func (d *Backend) VersionKinds() []backend.VersionKind {
+	return []backend.VersionKind{backend.VersionKindLatest, backend.VersionKindID, backend.VersionKindStage, backend.VersionKindAlias}
+}
+
+// HealthCheck succeeds once the backend is initialized
+func (d *Backend) HealthCheck(ctx context.Context) error {
+	if d.suffix == "" {
+		return fmt.Errorf("backend is not initialized")
+	}
+	return nil
 }
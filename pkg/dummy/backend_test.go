@@ -1,6 +1,7 @@
 package dummy
 
 import (
+	"context"
 	"testing"
 
 	. "github.com/smartystreets/goconvey/convey"
@@ -25,7 +26,7 @@ func TestGet(t *testing.T) {
 	Convey("Given an uninitialized dummy backend", t, func() {
 		backend := Backend{}
 		Convey("When retrieving a secret", func() {
-			_, err := backend.Get(secretKey, keyVersion)
+			_, err := backend.Get(context.Background(), secretKey, keyVersion)
 			Convey("Then an error is returned", func() {
 				So(err, ShouldNotBeNil)
 				So(err.Error(), ShouldEqual, "backend is not initialized")
@@ -37,15 +38,15 @@ func TestGet(t *testing.T) {
 		backend := Backend{}
 		backend.suffix = testSuffix
 		Convey("When retrieving a secret", func() {
-			actualValue, err := backend.Get(secretKey, keyVersion)
+			actualValue, err := backend.Get(context.Background(), secretKey, keyVersion)
 			Convey("Then no error is returned", func() {
 				So(err, ShouldBeNil)
-				So(actualValue, ShouldEqual, expectedValue)
+				So(string(actualValue.Data), ShouldEqual, expectedValue)
 			})
 		})
 
 		Convey("When retrieving secret details", func() {
-			_, err := backend.Get("", "")
+			_, err := backend.Get(context.Background(), "", "")
 			Convey("An  error is returned when key is empty", func() {
 				So(err, ShouldNotBeNil)
 				So(err.Error(), ShouldEqual, "empty key provided")
@@ -54,7 +55,7 @@ func TestGet(t *testing.T) {
 		})
 
 		Convey("When mock error key is provided", func() {
-			_, err := backend.Get("ErroredKey", "")
+			_, err := backend.Get(context.Background(), "ErroredKey", "")
 			Convey("An  error is returned when key is a mock error key", func() {
 				So(err, ShouldNotBeNil)
 				So(err.Error(), ShouldEqual, "Mocked error")
@@ -64,6 +65,20 @@ func TestGet(t *testing.T) {
 	})
 }
 
+func TestList(t *testing.T) {
+	Convey("Given an initialized dummy backend", t, func() {
+		backend := Backend{suffix: "test-suffix"}
+		Convey("When listing secrets", func() {
+			values, err := backend.List(context.Background())
+			Convey("Then a value is returned for each listed key", func() {
+				So(err, ShouldBeNil)
+				So(values, ShouldHaveLength, len(ListedKeys))
+				So(string(values["listed-key"].Data), ShouldEqual, "listed-keytest-suffix")
+			})
+		})
+	})
+}
+
 func TestInit(t *testing.T) {
 	var (
 		params      = make(map[string]interface{})
@@ -74,20 +89,20 @@ func TestInit(t *testing.T) {
 	Convey("Should initialize backend", t, func() {
 		backend := Backend{}
 		credentials = []byte{}
-		err := backend.Init(params, credentials)
+		err := backend.Init(context.Background(), params, credentials)
 		So(err, ShouldBeNil)
 	})
 
 	Convey("Should fail initialize backend with invalid config", t, func() {
 		backend := Backend{}
-		err := backend.Init(make(map[string]interface{}), credentials)
+		err := backend.Init(context.Background(), make(map[string]interface{}), credentials)
 		So(err, ShouldNotBeNil)
 		So(err.Error(), ShouldEqual, "empty or invalid parameters")
 	})
 
 	Convey("Should fail initialize backend with invalid parameter suffix", t, func() {
 		backend := Backend{}
-		err := backend.Init(map[string]interface{}{"unknown": "fail"}, credentials)
+		err := backend.Init(context.Background(), map[string]interface{}{"unknown": "fail"}, credentials)
 		So(err, ShouldNotBeNil)
 		So(err.Error(), ShouldEqual, "missing parameters")
 	})
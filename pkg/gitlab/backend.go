@@ -3,8 +3,12 @@
 package gitlab
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
+	"net/url"
 
 	"github.com/containersolutions/externalsecret-operator/pkg/backend"
 	gitlab "github.com/xanzy/go-gitlab"
@@ -30,10 +34,16 @@ func (e *ErrGet) Error() string {
 	return fmt.Sprintf("gitlab backend get '%s' failed: %s", e.itemName, e.message)
 }
 
-// Backend is a gitlab variables backend
+// defaultEnvironmentScope is the environment scope of the variables of all environments
+const defaultEnvironmentScope = "*"
+
+// Backend is a gitlab variables backend. It reads the variables of a project,
+// of a group or of the instance.
 type Backend struct {
-	client    *gitlab.Client
-	projectID interface{}
+	client           *gitlab.Client
+	projectID        interface{}
+	groupID          interface{}
+	environmentScope string
 }
 
 func init() {
@@ -46,7 +56,7 @@ func NewBackend() backend.Backend {
 }
 
 // Init implements SecretsBackend interface
-func (d *Backend) Init(parameters map[string]interface{}, credentials []byte) error {
+func (d *Backend) Init(ctx context.Context, parameters map[string]interface{}, credentials []byte) error {
 	var err error
 
 	if len(parameters) == 0 {
@@ -60,20 +70,39 @@ func (d *Backend) Init(parameters map[string]interface{}, credentials []byte) er
 		return &ErrInitFailed{message: err.Error()}
 	}
 
-	baseURL, ok := parameters["baseURL"]
+	baseURL, ok := parameters["baseURL"].(string)
 	if !ok {
 		log.Error(fmt.Errorf("error"), "missing baseURL parameter: ")
 		return fmt.Errorf("missing baseURL parameter")
 	}
 
-	projectID, ok := parameters["projectID"]
-	if !ok {
-		log.Error(fmt.Errorf("error"), "missing projectID parameter: ")
-		return fmt.Errorf("missing projectID parameter")
+	d.projectID, err = parseID(parameters, "projectID")
+	if err != nil {
+		return err
+	}
+	d.groupID, err = parseID(parameters, "groupID")
+	if err != nil {
+		return err
+	}
+	instance, _ := parameters["instance"].(bool)
+
+	scopes := 0
+	for _, set := range []bool{d.projectID != nil, d.groupID != nil, instance} {
+		if set {
+			scopes++
+		}
+	}
+	if scopes != 1 {
+		log.Error(fmt.Errorf("error"), "exactly one of projectID, groupID or instance parameters required")
+		return fmt.Errorf("exactly one of projectID, groupID or instance parameters required")
 	}
 
-	d.projectID = projectID
-	d.client, err = gitlab.NewClient(gitlabCreds.Token, gitlab.WithBaseURL(baseURL.(string)))
+	d.environmentScope, _ = parameters["environmentScope"].(string)
+	if d.environmentScope != "" && d.projectID == nil {
+		return fmt.Errorf("environmentScope parameter only applies to project variables")
+	}
+
+	d.client, err = gitlab.NewClient(gitlabCreds.Token, gitlab.WithBaseURL(baseURL))
 	if err != nil {
 		log.Error(fmt.Errorf("error"), "failed to create client: ")
 		return fmt.Errorf("failed to create client")
@@ -81,20 +110,247 @@ func (d *Backend) Init(parameters map[string]interface{}, credentials []byte) er
 	return nil
 }
 
+// parseID returns the project or group ID parameter name, either its number
+// or its path like "group/project", nil when it is not set
+func parseID(parameters map[string]interface{}, name string) (interface{}, error) {
+	switch id := parameters[name].(type) {
+	case nil:
+		return nil, nil
+	case string:
+		if id == "" {
+			return nil, nil
+		}
+		return id, nil
+	case int:
+		return id, nil
+	case int64:
+		return int(id), nil
+	case float64:
+		// JSON numbers are decoded to float64
+		if id != float64(int(id)) {
+			return nil, fmt.Errorf("invalid %s parameter: %v", name, id)
+		}
+		return int(id), nil
+	}
+	return nil, fmt.Errorf("invalid %s parameter: %v", name, parameters[name])
+}
+
 // Get takes a key and version, and returns the value
-func (d *Backend) Get(key string, version string) (string, error) {
+func (d *Backend) Get(ctx context.Context, key string, version string) (*backend.Value, error) {
 	if key == "" {
-		return "", fmt.Errorf("empty key provided")
+		return nil, fmt.Errorf("empty key provided")
 	}
 
-	variable, _, err := d.client.ProjectVariables.GetVariable(fmt.Sprintf("%.f", d.projectID), key, nil)
-	if err != nil {
-		return "", err
+	if d.client == nil {
+		return nil, fmt.Errorf("backend not initialized")
+	}
+
+	// Variables are not versioned
+	if err := backend.SupportsVersion(d, version); err != nil {
+		return nil, err
+	}
+
+	var value string
+	switch {
+	case d.projectID != nil && d.environmentScope != "":
+		// The variable of the environment scope wins over the one of all environments
+		variable, err := d.getScopedProjectVariable(ctx, key, d.environmentScope)
+		if err == errVariableNotFound && d.environmentScope != defaultEnvironmentScope {
+			variable, err = d.getScopedProjectVariable(ctx, key, defaultEnvironmentScope)
+		}
+		if err == errVariableNotFound {
+			return nil, &ErrGet{itemName: key, message: fmt.Sprintf("no variable for environment scope %s", d.environmentScope)}
+		}
+		if err != nil {
+			return nil, err
+		}
+		value = variable.Value
+	case d.projectID != nil:
+		variable, _, err := d.client.ProjectVariables.GetVariable(d.projectID, key, gitlab.WithContext(ctx))
+		if err != nil {
+			return nil, err
+		}
+		value = variable.Value
+	case d.groupID != nil:
+		variable, _, err := d.client.GroupVariables.GetVariable(d.groupID, key, gitlab.WithContext(ctx))
+		if err != nil {
+			return nil, err
+		}
+		value = variable.Value
+	default:
+		variable, err := d.getInstanceVariable(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		value = variable.Value
 	}
 
 	log.Info("Get was successful for the Gitlab")
 
-	return variable.Value, nil
+	return backend.NewValue([]byte(value), ""), nil
+}
+
+// List implements backend.Lister, it returns all the variables of the project,
+// group or instance
+func (d *Backend) List(ctx context.Context) (map[string]*backend.Value, error) {
+	if d.client == nil {
+		return nil, fmt.Errorf("backend not initialized")
+	}
+
+	values := map[string]*backend.Value{}
+	if d.projectID != nil {
+		variables, err := d.listProjectVariables(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for key, variable := range variables {
+			values[key] = backend.NewValue([]byte(variable.Value), "")
+		}
+		return values, nil
+	}
+
+	opts := gitlab.ListOptions{PerPage: 100, Page: 1}
+	for opts.Page != 0 {
+		var response *gitlab.Response
+		if d.groupID != nil {
+			variables, resp, err := d.client.GroupVariables.ListVariables(d.groupID, (*gitlab.ListGroupVariablesOptions)(&opts), gitlab.WithContext(ctx))
+			if err != nil {
+				return nil, err
+			}
+			for _, variable := range variables {
+				values[variable.Key] = backend.NewValue([]byte(variable.Value), "")
+			}
+			response = resp
+		} else {
+			variables, resp, err := d.listInstanceVariables(ctx, (*gitlab.ListInstanceVariablesOptions)(&opts))
+			if err != nil {
+				return nil, err
+			}
+			for _, variable := range variables {
+				values[variable.Key] = backend.NewValue([]byte(variable.Value), "")
+			}
+			response = resp
+		}
+		opts.Page = response.NextPage
+	}
+	return values, nil
+}
+
+// listProjectVariables returns the variables of the project by key, keeping
+// for each key the variable of the environment scope, or else the one of all
+// environments. Scopes are matched literally, see variableFilter.
+func (d *Backend) listProjectVariables(ctx context.Context) (map[string]*gitlab.ProjectVariable, error) {
+	scope := d.environmentScope
+	if scope == "" {
+		scope = defaultEnvironmentScope
+	}
+
+	variables := map[string]*gitlab.ProjectVariable{}
+	opts := &gitlab.ListProjectVariablesOptions{PerPage: 100, Page: 1}
+	for opts.Page != 0 {
+		page, resp, err := d.client.ProjectVariables.ListVariables(d.projectID, opts, gitlab.WithContext(ctx))
+		if err != nil {
+			return nil, err
+		}
+		for _, variable := range page {
+			switch variable.EnvironmentScope {
+			case scope:
+				variables[variable.Key] = variable
+			case defaultEnvironmentScope:
+				if _, ok := variables[variable.Key]; !ok {
+					variables[variable.Key] = variable
+				}
+			}
+		}
+		opts.Page = resp.NextPage
+	}
+	return variables, nil
+}
+
+// errVariableNotFound is returned by getScopedProjectVariable when the project
+// has no variable of the key in the environment scope
+var errVariableNotFound = errors.New("variable not found")
+
+// variableFilter selects a project variable by environment scope. Scopes are
+// matched literally, a wildcard scope like "review/*" only matches a variable
+// whose scope is "review/*" itself.
+type variableFilter struct {
+	EnvironmentScope string `url:"filter[environment_scope]"`
+}
+
+// getScopedProjectVariable gets the project variable of the key in the
+// environment scope. The ProjectVariables service of the go-gitlab client
+// does not support the filter, so the API is called directly.
+func (d *Backend) getScopedProjectVariable(ctx context.Context, key, scope string) (*gitlab.ProjectVariable, error) {
+	path := fmt.Sprintf("projects/%s/variables/%s", url.PathEscape(fmt.Sprint(d.projectID)), url.PathEscape(key))
+	req, err := d.client.NewRequest(http.MethodGet, path, &variableFilter{EnvironmentScope: scope}, []gitlab.RequestOptionFunc{gitlab.WithContext(ctx)})
+	if err != nil {
+		return nil, err
+	}
+
+	variable := &gitlab.ProjectVariable{}
+	resp, err := d.client.Do(req, variable)
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		return nil, errVariableNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return variable, nil
+}
+
+// getInstanceVariable gets an instance variable. The InstanceVariables service
+// of the go-gitlab client is never initialized, so the API is called directly.
+func (d *Backend) getInstanceVariable(ctx context.Context, key string) (*gitlab.InstanceVariable, error) {
+	req, err := d.client.NewRequest(http.MethodGet, "admin/ci/variables/"+url.PathEscape(key), nil, []gitlab.RequestOptionFunc{gitlab.WithContext(ctx)})
+	if err != nil {
+		return nil, err
+	}
+
+	variable := &gitlab.InstanceVariable{}
+	if _, err := d.client.Do(req, variable); err != nil {
+		return nil, err
+	}
+	return variable, nil
+}
+
+// listInstanceVariables lists a page of instance variables, see getInstanceVariable
+func (d *Backend) listInstanceVariables(ctx context.Context, opts *gitlab.ListInstanceVariablesOptions) ([]*gitlab.InstanceVariable, *gitlab.Response, error) {
+	req, err := d.client.NewRequest(http.MethodGet, "admin/ci/variables", opts, []gitlab.RequestOptionFunc{gitlab.WithContext(ctx)})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	variables := []*gitlab.InstanceVariable{}
+	resp, err := d.client.Do(req, &variables)
+	if err != nil {
+		return nil, resp, err
+	}
+	return variables, resp, nil
+}
+
+// VersionKinds implements backend.VersionKinds, GitLab variables have no version
+func (d *Backend) VersionKinds() []backend.VersionKind {
+	return []backend.VersionKind{backend.VersionKindLatest}
+}
+
+// HealthCheck gets the project or group, or lists one instance variable, to
+// check GitLab is reachable with the token
+func (d *Backend) HealthCheck(ctx context.Context) error {
+	if d.client == nil {
+		return fmt.Errorf("backend not initialized")
+	}
+
+	var err error
+	switch {
+	case d.projectID != nil:
+		_, _, err = d.client.Projects.GetProject(d.projectID, nil, gitlab.WithContext(ctx))
+	case d.groupID != nil:
+		_, _, err = d.client.Groups.GetGroup(d.groupID, gitlab.WithContext(ctx))
+	default:
+		_, _, err = d.listInstanceVariables(ctx, &gitlab.ListInstanceVariablesOptions{PerPage: 1})
+	}
+	return err
 }
 
 type GitlabCredentials struct {
@@ -0,0 +1,236 @@
+package gitlab
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type variable struct {
+	Key              string `json:"key"`
+	Value            string `json:"value"`
+	EnvironmentScope string `json:"environment_scope,omitempty"`
+}
+
+// newGitLab returns a GitLab stand-in serving the variables of the project
+// "group/project", of the group 42 and of the instance. Project variables are
+// listed two per page, listed counts the requests of these pages.
+func newGitLab(listed *int) *httptest.Server {
+	projectVariables := []variable{
+		{Key: "DATABASE_PASSWORD", Value: "all-password", EnvironmentScope: "*"},
+		{Key: "DATABASE_PASSWORD", Value: "production-password", EnvironmentScope: "production"},
+		{Key: "API_TOKEN", Value: "all-token", EnvironmentScope: "*"},
+		{Key: "STAGING_ONLY", Value: "staging", EnvironmentScope: "staging"},
+	}
+
+	responses := map[string]interface{}{
+		"/api/v4/projects/group%2Fproject":        map[string]interface{}{"id": 1},
+		"/api/v4/groups/42/variables":             []variable{{Key: "GROUP_KEY", Value: "group-value"}},
+		"/api/v4/groups/42/variables/GROUP_KEY":   variable{Key: "GROUP_KEY", Value: "group-value"},
+		"/api/v4/groups/42":                       map[string]interface{}{"id": 42},
+		"/api/v4/admin/ci/variables":              []variable{{Key: "INSTANCE_KEY", Value: "instance-value"}},
+		"/api/v4/admin/ci/variables/INSTANCE_KEY": variable{Key: "INSTANCE_KEY", Value: "instance-value"},
+	}
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Private-Token") != "token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		path := r.URL.EscapedPath()
+		if strings.HasPrefix(path, "/api/v4/projects/group%2Fproject/variables/") {
+			// Without filter GitLab returns the first variable of the key
+			key := strings.TrimPrefix(path, "/api/v4/projects/group%2Fproject/variables/")
+			scope, filtered := r.URL.Query()["filter[environment_scope]"]
+			for _, variable := range projectVariables {
+				if variable.Key == key && (!filtered || variable.EnvironmentScope == scope[0]) {
+					json.NewEncoder(w).Encode(variable)
+					return
+				}
+			}
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if path == "/api/v4/projects/group%2Fproject/variables" {
+			*listed++
+			page := projectVariables[:2]
+			if r.URL.Query().Get("page") == "2" {
+				page = projectVariables[2:]
+			} else {
+				w.Header().Set("X-Next-Page", "2")
+			}
+			json.NewEncoder(w).Encode(page)
+			return
+		}
+
+		response, ok := responses[path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(response)
+	}))
+}
+
+func TestInit(t *testing.T) {
+	credentials := []byte(`{"token": "token"}`)
+
+	Convey("When initializing with a numeric project ID", t, func() {
+		backend := &Backend{}
+		err := backend.Init(context.Background(), map[string]interface{}{"baseURL": "https://gitlab.com", "projectID": float64(12345678)}, credentials)
+		Convey("Then the ID is kept as a number", func() {
+			So(err, ShouldBeNil)
+			So(backend.projectID, ShouldEqual, 12345678)
+		})
+	})
+
+	Convey("When initializing with a project path", t, func() {
+		backend := &Backend{}
+		err := backend.Init(context.Background(), map[string]interface{}{"baseURL": "https://gitlab.com", "projectID": "group/project"}, credentials)
+		Convey("Then the path is used as ID", func() {
+			So(err, ShouldBeNil)
+			So(backend.projectID, ShouldEqual, "group/project")
+		})
+	})
+
+	Convey("When initializing without project, group or instance", t, func() {
+		backend := &Backend{}
+		err := backend.Init(context.Background(), map[string]interface{}{"baseURL": "https://gitlab.com"}, credentials)
+		Convey("Then an error is returned", func() {
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldEqual, "exactly one of projectID, groupID or instance parameters required")
+		})
+	})
+
+	Convey("When initializing with both a project and a group", t, func() {
+		backend := &Backend{}
+		err := backend.Init(context.Background(), map[string]interface{}{"baseURL": "https://gitlab.com", "projectID": "group/project", "groupID": float64(42)}, credentials)
+		Convey("Then an error is returned", func() {
+			So(err, ShouldNotBeNil)
+		})
+	})
+
+	Convey("When initializing a group with an environment scope", t, func() {
+		backend := &Backend{}
+		err := backend.Init(context.Background(), map[string]interface{}{"baseURL": "https://gitlab.com", "groupID": float64(42), "environmentScope": "production"}, credentials)
+		Convey("Then an error is returned", func() {
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldEqual, "environmentScope parameter only applies to project variables")
+		})
+	})
+
+	Convey("When initializing with an invalid project ID", t, func() {
+		backend := &Backend{}
+		err := backend.Init(context.Background(), map[string]interface{}{"baseURL": "https://gitlab.com", "projectID": 1.5}, credentials)
+		Convey("Then an error is returned", func() {
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldEqual, "invalid projectID parameter: 1.5")
+		})
+	})
+}
+
+func TestGetAndList(t *testing.T) {
+	listed := 0
+	server := newGitLab(&listed)
+	defer server.Close()
+	credentials := []byte(`{"token": "token"}`)
+
+	newBackend := func(parameters map[string]interface{}) *Backend {
+		parameters["baseURL"] = server.URL
+		backend := &Backend{}
+		So(backend.Init(context.Background(), parameters, credentials), ShouldBeNil)
+		return backend
+	}
+
+	Convey("Given a backend for a project path", t, func() {
+		backend := newBackend(map[string]interface{}{"projectID": "group/project"})
+
+		Convey("When retrieving a variable", func() {
+			value, err := backend.Get(context.Background(), "API_TOKEN", "")
+			Convey("Then its value is returned", func() {
+				So(err, ShouldBeNil)
+				So(string(value.Data), ShouldEqual, "all-token")
+			})
+		})
+
+		Convey("When retrieving a version of a variable", func() {
+			_, err := backend.Get(context.Background(), "API_TOKEN", "2")
+			Convey("Then an error is returned", func() {
+				So(err, ShouldNotBeNil)
+			})
+		})
+
+		Convey("When listing the variables", func() {
+			values, err := backend.List(context.Background())
+			Convey("Then the variables of all environments of every page are returned", func() {
+				So(err, ShouldBeNil)
+				So(values, ShouldHaveLength, 2)
+				So(string(values["DATABASE_PASSWORD"].Data), ShouldEqual, "all-password")
+				So(string(values["API_TOKEN"].Data), ShouldEqual, "all-token")
+			})
+		})
+
+		Convey("When checking its health", func() {
+			So(backend.HealthCheck(context.Background()), ShouldBeNil)
+		})
+	})
+
+	Convey("Given a backend for an environment scope of a project", t, func() {
+		backend := newBackend(map[string]interface{}{"projectID": "group/project", "environmentScope": "production"})
+
+		Convey("When retrieving variables", func() {
+			listed = 0
+			password, errPassword := backend.Get(context.Background(), "DATABASE_PASSWORD", "")
+			token, errToken := backend.Get(context.Background(), "API_TOKEN", "")
+			_, errStaging := backend.Get(context.Background(), "STAGING_ONLY", "")
+			Convey("Then the variables of the scope win over the ones of all environments", func() {
+				So(errPassword, ShouldBeNil)
+				So(string(password.Data), ShouldEqual, "production-password")
+				So(errToken, ShouldBeNil)
+				So(string(token.Data), ShouldEqual, "all-token")
+				So(errStaging, ShouldNotBeNil)
+				So(errStaging.Error(), ShouldEqual, "gitlab backend get 'STAGING_ONLY' failed: no variable for environment scope production")
+				So(listed, ShouldEqual, 0)
+			})
+		})
+	})
+
+	Convey("Given a backend for a group", t, func() {
+		backend := newBackend(map[string]interface{}{"groupID": float64(42)})
+
+		Convey("When retrieving and listing variables", func() {
+			value, err := backend.Get(context.Background(), "GROUP_KEY", "")
+			values, errList := backend.List(context.Background())
+			Convey("Then the group variables are returned", func() {
+				So(err, ShouldBeNil)
+				So(string(value.Data), ShouldEqual, "group-value")
+				So(errList, ShouldBeNil)
+				So(values, ShouldHaveLength, 1)
+				So(string(values["GROUP_KEY"].Data), ShouldEqual, "group-value")
+				So(backend.HealthCheck(context.Background()), ShouldBeNil)
+			})
+		})
+	})
+
+	Convey("Given a backend for the instance", t, func() {
+		backend := newBackend(map[string]interface{}{"instance": true})
+
+		Convey("When retrieving and listing variables", func() {
+			value, err := backend.Get(context.Background(), "INSTANCE_KEY", "")
+			values, errList := backend.List(context.Background())
+			Convey("Then the instance variables are returned", func() {
+				So(err, ShouldBeNil)
+				So(string(value.Data), ShouldEqual, "instance-value")
+				So(errList, ShouldBeNil)
+				So(values, ShouldHaveLength, 1)
+				So(backend.HealthCheck(context.Background()), ShouldBeNil)
+			})
+		})
+	})
+}
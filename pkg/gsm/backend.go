@@ -3,15 +3,23 @@ package gsm
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"time"
 
 	"cloud.google.com/go/iam"
 	secretmanager "cloud.google.com/go/secretmanager/apiv1"
 	"github.com/containersolutions/externalsecret-operator/pkg/backend"
 	"github.com/googleapis/gax-go"
 	"golang.org/x/oauth2/google"
+	"google.golang.org/api/iterator"
 	option "google.golang.org/api/option"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
 	secretmanagerpb "google.golang.org/genproto/googleapis/cloud/secretmanager/v1"
 	iampb "google.golang.org/genproto/googleapis/iam/v1"
@@ -26,6 +34,9 @@ const (
 
 var log = ctrl.Log.WithName("gsm")
 
+// validLocation matches Google Cloud locations, which name the regional endpoints
+var validLocation = regexp.MustCompile(`^[a-z0-9-]+$`)
+
 type GoogleSecretManagerClient interface {
 	AccessSecretVersion(ctx context.Context, req *secretmanagerpb.AccessSecretVersionRequest, opts ...gax.CallOption) (*secretmanagerpb.AccessSecretVersionResponse, error)
 	AddSecretVersion(ctx context.Context, req *secretmanagerpb.AddSecretVersionRequest, opts ...gax.CallOption) (*secretmanagerpb.SecretVersion, error)
@@ -50,9 +61,24 @@ type GoogleSecretManagerClient interface {
 // Backend for Google Secrets Manager
 type Backend struct {
 	projectID           string
+	location            string
 	SecretManagerClient GoogleSecretManagerClient
+
+	// options are appended to the client options of the parameters
+	options []option.ClientOption
+}
+
+// detachedContext keeps the values of a context but not its deadline and
+// cancellation. The client and the token sources of its credentials keep the
+// context they are created with for every token refresh, and outlive Init.
+type detachedContext struct {
+	context.Context
 }
 
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}       { return nil }
+func (detachedContext) Err() error                  { return nil }
+
 func init() {
 	backend.Register("gsm", NewBackend)
 }
@@ -62,11 +88,12 @@ func NewBackend() backend.Backend {
 	return &Backend{}
 }
 
-// Init initializes Google secretmanager backend
-func (g *Backend) Init(parameters map[string]interface{}, credentials []byte) error {
-	ctx := context.Background()
+// Init initializes Google secretmanager backend. Without credentials the
+// client uses the Application Default Credentials, e.g. GKE Workload Identity,
+// when ctx allows ambient credentials.
+func (g *Backend) Init(ctx context.Context, parameters map[string]interface{}, credentials []byte) error {
 
-	if len(parameters) == 0 || len(credentials) == 0 {
+	if len(parameters) == 0 {
 		return fmt.Errorf("credentials or parameters invalid")
 	}
 
@@ -76,15 +103,15 @@ func (g *Backend) Init(parameters map[string]interface{}, credentials []byte) er
 	}
 
 	g.projectID = projectID
+	g.location, _ = parameters["location"].(string)
 
-	config, err := google.JWTConfigFromJSON(credentials, cloudPlatformRole)
+	clientCtx := detachedContext{ctx}
+	opts, err := clientOptions(clientCtx, parameters, credentials)
 	if err != nil {
 		return err
 	}
 
-	ts := config.TokenSource(ctx)
-
-	client, err := secretmanager.NewClient(ctx, option.WithTokenSource(ts))
+	client, err := secretmanager.NewClient(clientCtx, append(opts, g.options...)...)
 	if err != nil {
 		return fmt.Errorf("failed to create secretmanager client: %v", err)
 	}
@@ -94,20 +121,118 @@ func (g *Backend) Init(parameters map[string]interface{}, credentials []byte) er
 	return nil
 }
 
+// clientOptions returns the secretmanager client options of the parameters:
+// the endpoint, the service account to impersonate and whether the endpoint
+// is an insecure emulator. Token sources keep ctx, it must not be cancelled.
+func clientOptions(ctx context.Context, parameters map[string]interface{}, credentials []byte) ([]option.ClientOption, error) {
+	opts := []option.ClientOption{}
+
+	endpoint, _ := parameters["endpoint"].(string)
+	customEndpoint := endpoint != ""
+	if location, _ := parameters["location"].(string); endpoint == "" && location != "" {
+		if !validLocation.MatchString(location) {
+			return nil, fmt.Errorf("invalid location parameter: %s", location)
+		}
+		endpoint = fmt.Sprintf("secretmanager.%s.rep.googleapis.com:443", location)
+	}
+	if endpoint != "" {
+		opts = append(opts, option.WithEndpoint(endpoint))
+	}
+
+	if insecure, _ := parameters["insecure"].(bool); insecure {
+		return append(opts,
+			option.WithoutAuthentication(),
+			option.WithGRPCDialOption(grpc.WithInsecure())), nil
+	}
+
+	if len(credentials) == 0 {
+		// The access tokens of the operator are only sent to Google
+		if customEndpoint {
+			return nil, fmt.Errorf("endpoint needs credentials or insecure, the Application Default Credentials are only sent to Google")
+		}
+		if !backend.AmbientCredentials(ctx) {
+			return nil, fmt.Errorf("credentials missing, the operator does not allow ambient credentials")
+		}
+	}
+
+	if len(credentials) > 0 {
+		config, err := google.JWTConfigFromJSON(credentials, cloudPlatformRole)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, option.WithTokenSource(config.TokenSource(ctx)))
+	}
+
+	if target, _ := parameters["impersonateServiceAccount"].(string); target != "" {
+		delegates, err := stringsParameter(parameters, "delegates")
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, option.ImpersonateCredentials(target, delegates...))
+	}
+
+	return opts, nil
+}
+
+// stringsParameter returns the list of strings parameter name of parameters
+func stringsParameter(parameters map[string]interface{}, name string) ([]string, error) {
+	switch values := parameters[name].(type) {
+	case nil:
+		return nil, nil
+	case []string:
+		return values, nil
+	case []interface{}:
+		strs := make([]string, 0, len(values))
+		for _, value := range values {
+			str, ok := value.(string)
+			if !ok {
+				return nil, fmt.Errorf("invalid %s parameter: %v", name, parameters[name])
+			}
+			strs = append(strs, str)
+		}
+		return strs, nil
+	}
+	return nil, fmt.Errorf("invalid %s parameter: %v", name, parameters[name])
+}
+
+// parent returns the resource name of the project, or of the location of
+// the project for regional secrets
+func (g *Backend) parent() string {
+	if g.location != "" {
+		return fmt.Sprintf("projects/%s/locations/%s", g.projectID, g.location)
+	}
+	return fmt.Sprintf("projects/%s", g.projectID)
+}
+
+// VersionKinds implements backend.VersionKinds, Google SecretManager retrieves
+// versions by number or alias
+func (g *Backend) VersionKinds() []backend.VersionKind {
+	return []backend.VersionKind{backend.VersionKindLatest, backend.VersionKindID, backend.VersionKindAlias}
+}
+
 // Get retrieves key from Google SecretManager
-func (g *Backend) Get(key string, version string) (string, error) {
-	ctx := context.Background()
+func (g *Backend) Get(ctx context.Context, key string, version string) (*backend.Value, error) {
 
 	if g.SecretManagerClient == nil || g.projectID == "" {
 		log.Error(fmt.Errorf("error"), "backend is not initialized")
-		return "", fmt.Errorf("backend is not initialized")
+		return nil, fmt.Errorf("backend is not initialized")
 	}
 
-	if version == "" {
+	parsed, err := backend.ParseVersion(version)
+	if err != nil {
+		return nil, err
+	}
+	switch parsed.Kind {
+	case backend.VersionKindLatest:
 		version = defaultVersion
+	case backend.VersionKindID, backend.VersionKindAlias:
+		// Aliases are resolved by Google SecretManager like version numbers
+		version = parsed.Value
+	default:
+		return nil, fmt.Errorf("version %q: %s versions are not supported by Google SecretManager", version, parsed.Kind)
 	}
 
-	name := fmt.Sprintf("projects/%s/secrets/%s/versions/%s", g.projectID, key, version)
+	name := fmt.Sprintf("%s/secrets/%s/versions/%s", g.parent(), key, version)
 
 	req := &secretmanagerpb.AccessSecretVersionRequest{
 		Name: name,
@@ -115,8 +240,57 @@ func (g *Backend) Get(key string, version string) (string, error) {
 
 	result, err := g.SecretManagerClient.AccessSecretVersion(ctx, req)
 	if err != nil {
-		return "", fmt.Errorf("failed to access secret version: %v", err)
+		return nil, fmt.Errorf("failed to access secret version: %w", err)
 	}
 
-	return string(result.Payload.Data), nil
+	return backend.NewValue(result.Payload.Data, path.Base(result.Name)), nil
+}
+
+// GetPrevious retrieves the highest enabled version of key lower than the
+// version number of current
+func (g *Backend) GetPrevious(ctx context.Context, key string, current *backend.Value) (*backend.Value, error) {
+	number, err := strconv.Atoi(current.Metadata[backend.MetadataVersion])
+	if err != nil {
+		return nil, fmt.Errorf("version of %s is not a version number: %v", key, err)
+	}
+
+	for version := number - 1; version > 0; version-- {
+		previous, err := g.Get(ctx, key, strconv.Itoa(version))
+		if err == nil {
+			return previous, nil
+		}
+
+		// Destroyed or disabled versions are skipped
+		code := status.Code(errors.Unwrap(err))
+		if code != codes.NotFound && code != codes.FailedPrecondition {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+// HealthCheck lists at most one secret of the project to check Google
+// SecretManager is reachable
+func (g *Backend) HealthCheck(ctx context.Context) error {
+	if g.SecretManagerClient == nil || g.projectID == "" {
+		return fmt.Errorf("backend is not initialized")
+	}
+
+	it := g.SecretManagerClient.ListSecrets(ctx, &secretmanagerpb.ListSecretsRequest{
+		Parent:   g.parent(),
+		PageSize: 1,
+	})
+	_, err := it.Next()
+	if err != nil && err != iterator.Done {
+		return fmt.Errorf("failed to list secrets: %v", err)
+	}
+	return nil
+}
+
+// Close closes the connection of the Google SecretManager client
+func (g *Backend) Close() error {
+	if g.SecretManagerClient == nil {
+		return nil
+	}
+	return g.SecretManagerClient.Close()
 }
@@ -0,0 +1,14 @@
+// Package logging configures the logger of the operator and guarantees that
+// secret values never reach the log output.
+package logging
+
+import (
+	"github.com/go-logr/logr"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+)
+
+// New returns a redacting zap logger configured with opts, bound to the -zap-*
+// flags. Logs are JSON encoded at the info level unless -zap-devel is set.
+func New(opts *zap.Options) logr.Logger {
+	return Redact(zap.New(zap.UseFlagOptions(opts)))
+}
@@ -0,0 +1,126 @@
+package logging
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/containersolutions/externalsecret-operator/pkg/backend"
+)
+
+// Redacted replaces the values that are never logged
+const Redacted = "[REDACTED]"
+
+// sensitiveKeys are the log keys whose values are always redacted, whatever their type
+var sensitiveKeys = map[string]bool{
+	"data":        true,
+	"stringdata":  true,
+	"credentials": true,
+	"password":    true,
+	"token":       true,
+	"value":       true,
+	"secretvalue": true,
+}
+
+// Redact returns a logger redacting the values logged through logger: Secret
+// objects are reduced to their name, type and keys, backend values and bytes
+// to their length, and the values of sensitive keys like "data" or "token"
+// are dropped. Error messages are logged as is, backends must not include
+// secret values in the errors they return.
+func Redact(logger logr.Logger) logr.Logger {
+	return &redactingLogger{logger: logger}
+}
+
+type redactingLogger struct {
+	logger logr.Logger
+}
+
+func (l *redactingLogger) Enabled() bool {
+	return l.logger.Enabled()
+}
+
+func (l *redactingLogger) Info(msg string, keysAndValues ...interface{}) {
+	l.logger.Info(msg, redactKeysAndValues(keysAndValues)...)
+}
+
+func (l *redactingLogger) Error(err error, msg string, keysAndValues ...interface{}) {
+	l.logger.Error(err, msg, redactKeysAndValues(keysAndValues)...)
+}
+
+func (l *redactingLogger) V(level int) logr.Logger {
+	return &redactingLogger{logger: l.logger.V(level)}
+}
+
+func (l *redactingLogger) WithValues(keysAndValues ...interface{}) logr.Logger {
+	return &redactingLogger{logger: l.logger.WithValues(redactKeysAndValues(keysAndValues)...)}
+}
+
+func (l *redactingLogger) WithName(name string) logr.Logger {
+	return &redactingLogger{logger: l.logger.WithName(name)}
+}
+
+func redactKeysAndValues(keysAndValues []interface{}) []interface{} {
+	redacted := make([]interface{}, len(keysAndValues))
+	for i := range keysAndValues {
+		if i%2 == 0 {
+			// keys are kept, zapr reports the non-string ones
+			redacted[i] = keysAndValues[i]
+			continue
+		}
+
+		key, _ := keysAndValues[i-1].(string)
+		redacted[i] = redactValue(key, keysAndValues[i])
+	}
+	return redacted
+}
+
+func redactValue(key string, value interface{}) interface{} {
+	if sensitiveKeys[strings.ToLower(key)] {
+		return Redacted
+	}
+
+	switch v := value.(type) {
+	case *corev1.Secret:
+		if v == nil {
+			return nil
+		}
+		return secretSummary(v)
+	case corev1.Secret:
+		return secretSummary(&v)
+	case *backend.Value:
+		if v == nil {
+			return nil
+		}
+		return fmt.Sprintf("%s (%d bytes)", Redacted, len(v.Data))
+	case backend.Value:
+		return fmt.Sprintf("%s (%d bytes)", Redacted, len(v.Data))
+	case []byte:
+		return fmt.Sprintf("%s (%d bytes)", Redacted, len(v))
+	case map[string][]byte:
+		return fmt.Sprintf("%s (keys: %s)", Redacted, strings.Join(sortedKeys(v), ","))
+	}
+	return value
+}
+
+// secretSummary describes secret without its data
+func secretSummary(secret *corev1.Secret) string {
+	keys := sortedKeys(secret.Data)
+	for key := range secret.StringData {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return fmt.Sprintf("%s/%s (type: %s, keys: %s)", secret.Namespace, secret.Name, secret.Type, strings.Join(keys, ","))
+}
+
+func sortedKeys(data map[string][]byte) []string {
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
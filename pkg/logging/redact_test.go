@@ -0,0 +1,70 @@
+package logging
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+
+	"github.com/containersolutions/externalsecret-operator/pkg/backend"
+)
+
+const secretValue = "s3cr3t-value"
+
+func TestRedact(t *testing.T) {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "target", Namespace: "default"},
+		Type:       corev1.SecretTypeOpaque,
+		Data:       map[string][]byte{"password": []byte(secretValue)},
+		StringData: map[string]string{"username": secretValue},
+	}
+
+	Convey("Given a JSON logger with redaction", t, func() {
+		out := &bytes.Buffer{}
+		logger := New(&zap.Options{DestWritter: out})
+
+		Convey("When logging a Secret", func() {
+			logger.Info("Creating Secret", "secret", secret)
+			logger.Error(errors.New("conflict"), "Failed to create Secret", "secret", *secret)
+			Convey("Then only its name, type and keys are logged", func() {
+				So(out.String(), ShouldNotContainSubstring, secretValue)
+				So(out.String(), ShouldContainSubstring, `"msg":"Creating Secret"`)
+				So(out.String(), ShouldContainSubstring, "default/target (type: Opaque, keys: password,username)")
+			})
+		})
+
+		Convey("When logging secret data and values", func() {
+			logger.Info("Retrieved",
+				"bytes", []byte(secretValue),
+				"secretMap", map[string][]byte{"key": []byte(secretValue)},
+				"retrieved", backend.NewValue([]byte(secretValue), "1"))
+			Convey("Then only their size or keys are logged", func() {
+				So(out.String(), ShouldNotContainSubstring, secretValue)
+				So(out.String(), ShouldContainSubstring, Redacted+" (12 bytes)")
+				So(out.String(), ShouldContainSubstring, Redacted+" (keys: key)")
+			})
+		})
+
+		Convey("When logging sensitive keys", func() {
+			logger.WithValues("token", secretValue).V(0).Info("Notified", "Credentials", secretValue, "data", map[string]string{"key": secretValue})
+			Convey("Then their values are redacted whatever their type", func() {
+				So(out.String(), ShouldNotContainSubstring, secretValue)
+				So(out.String(), ShouldContainSubstring, `"token":"`+Redacted+`"`)
+				So(out.String(), ShouldContainSubstring, `"Credentials":"`+Redacted+`"`)
+			})
+		})
+
+		Convey("When logging other values", func() {
+			logger.WithName("controller").Info("Reconciling", "externalsecret", "default/example", "count", 2)
+			Convey("Then they are logged as is", func() {
+				So(out.String(), ShouldContainSubstring, `"logger":"controller"`)
+				So(out.String(), ShouldContainSubstring, `"externalsecret":"default/example"`)
+				So(out.String(), ShouldContainSubstring, `"count":2`)
+			})
+		})
+	})
+}
@@ -0,0 +1,139 @@
+package notification
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+const (
+	snsTypeNotification             = "Notification"
+	snsTypeSubscriptionConfirmation = "SubscriptionConfirmation"
+	snsTypeUnsubscribeConfirmation  = "UnsubscribeConfirmation"
+)
+
+// Secrets Manager appends a dash and six random characters to secret ARNs
+var arnSuffix = regexp.MustCompile(`-[a-zA-Z0-9]{6}$`)
+
+// awsWriteEvents are the Secrets Manager API calls changing the value of a
+// secret. Other calls, e.g. the GetSecretValue calls of the operator, are
+// ignored so that they do not trigger reconciles.
+var awsWriteEvents = map[string]bool{
+	"PutSecretValue":           true,
+	"UpdateSecret":             true,
+	"RotateSecret":             true,
+	"UpdateSecretVersionStage": true,
+	"DeleteSecret":             true,
+	"RestoreSecret":            true,
+}
+
+type snsMessage struct {
+	Type         string `json:"Type"`
+	Message      string `json:"Message"`
+	SubscribeURL string `json:"SubscribeURL"`
+	TopicArn     string `json:"TopicArn"`
+}
+
+type eventBridgeEvent struct {
+	Source     string `json:"source"`
+	DetailType string `json:"detail-type"`
+	Detail     struct {
+		EventSource       string `json:"eventSource"`
+		EventName         string `json:"eventName"`
+		RequestParameters struct {
+			SecretID string `json:"secretId"`
+		} `json:"requestParameters"`
+		AdditionalEventData struct {
+			SecretID string `json:"SecretId"`
+		} `json:"additionalEventData"`
+	} `json:"detail"`
+}
+
+// parseAWS handles Secrets Manager events delivered by EventBridge, either
+// directly through an API destination or wrapped in an SNS notification
+func parseAWS(r *Receiver, w http.ResponseWriter, body []byte) ([]string, bool, error) {
+	sns := &snsMessage{}
+	if err := json.Unmarshal(body, sns); err != nil {
+		return nil, false, fmt.Errorf("invalid AWS notification: %v", err)
+	}
+
+	switch sns.Type {
+	case snsTypeSubscriptionConfirmation:
+		if err := r.confirmSNSSubscription(sns.SubscribeURL); err != nil {
+			return nil, false, err
+		}
+		r.Log.Info("Confirmed SNS subscription", "topic", sns.TopicArn)
+		w.WriteHeader(http.StatusOK)
+		return nil, true, nil
+	case snsTypeUnsubscribeConfirmation:
+		w.WriteHeader(http.StatusOK)
+		return nil, true, nil
+	case snsTypeNotification:
+		body = []byte(sns.Message)
+	}
+
+	event := &eventBridgeEvent{}
+	if err := json.Unmarshal(body, event); err != nil {
+		return nil, false, fmt.Errorf("invalid EventBridge event: %v", err)
+	}
+
+	if event.Source != "aws.secretsmanager" || !awsWriteEvents[event.Detail.EventName] {
+		return nil, false, nil
+	}
+
+	keys := []string{}
+	for _, id := range []string{event.Detail.RequestParameters.SecretID, event.Detail.AdditionalEventData.SecretID} {
+		keys = append(keys, awsSecretKeys(id)...)
+	}
+	return keys, false, nil
+}
+
+// awsSecretKeys returns the keys an ExternalSecret may use to reference the
+// secret id: the id itself and, for ARNs, the secret name
+func awsSecretKeys(id string) []string {
+	if id == "" {
+		return nil
+	}
+
+	keys := []string{id}
+	if !strings.HasPrefix(id, "arn:") {
+		return keys
+	}
+
+	parts := strings.SplitN(id, ":secret:", 2)
+	if len(parts) != 2 {
+		return keys
+	}
+
+	return append(keys, parts[1], arnSuffix.ReplaceAllString(parts[1], ""))
+}
+
+func (r *Receiver) confirmSNSSubscription(subscribeURL string) error {
+	u, err := url.Parse(subscribeURL)
+	if err != nil {
+		return fmt.Errorf("invalid SubscribeURL: %v", err)
+	}
+
+	if u.Scheme != "https" || !strings.HasSuffix(u.Hostname(), ".amazonaws.com") {
+		return fmt.Errorf("refusing to confirm subscription on %s", u.Host)
+	}
+
+	httpClient := r.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	resp, err := httpClient.Get(u.String())
+	if err != nil {
+		return fmt.Errorf("failed to confirm subscription: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to confirm subscription: %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package notification
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const eventGridSubscriptionValidation = "Microsoft.EventGrid.SubscriptionValidationEvent"
+
+type eventGridEvent struct {
+	EventType string          `json:"eventType"`
+	Subject   string          `json:"subject"`
+	Data      json.RawMessage `json:"data"`
+}
+
+type keyVaultEventData struct {
+	ObjectType string `json:"ObjectType"`
+	ObjectName string `json:"ObjectName"`
+}
+
+type validationEventData struct {
+	ValidationCode string `json:"validationCode"`
+}
+
+// parseAzure handles Key Vault events delivered by an Event Grid webhook subscription
+func parseAzure(r *Receiver, w http.ResponseWriter, body []byte) ([]string, bool, error) {
+	events := []eventGridEvent{}
+	if err := json.Unmarshal(body, &events); err != nil {
+		return nil, false, fmt.Errorf("invalid Event Grid events: %v", err)
+	}
+
+	keys := []string{}
+	for _, event := range events {
+		if event.EventType == eventGridSubscriptionValidation {
+			data := &validationEventData{}
+			if err := json.Unmarshal(event.Data, data); err != nil {
+				return nil, false, fmt.Errorf("invalid Event Grid validation event: %v", err)
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			err := json.NewEncoder(w).Encode(map[string]string{"validationResponse": data.ValidationCode})
+			return nil, true, err
+		}
+
+		if !strings.HasPrefix(event.EventType, "Microsoft.KeyVault.") {
+			continue
+		}
+
+		data := &keyVaultEventData{}
+		if err := json.Unmarshal(event.Data, data); err != nil {
+			return nil, false, fmt.Errorf("invalid Key Vault event: %v", err)
+		}
+
+		name := data.ObjectName
+		if name == "" {
+			name = event.Subject
+		}
+		if name != "" {
+			keys = append(keys, name)
+		}
+	}
+
+	return keys, false, nil
+}
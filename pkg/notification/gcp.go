@@ -0,0 +1,33 @@
+package notification
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+type pubSubPush struct {
+	Message struct {
+		Attributes map[string]string `json:"attributes"`
+		MessageID  string            `json:"messageId"`
+	} `json:"message"`
+	Subscription string `json:"subscription"`
+}
+
+// parseGCP handles Secret Manager notifications delivered by a Pub/Sub push subscription
+func parseGCP(r *Receiver, w http.ResponseWriter, body []byte) ([]string, bool, error) {
+	push := &pubSubPush{}
+	if err := json.Unmarshal(body, push); err != nil {
+		return nil, false, fmt.Errorf("invalid Pub/Sub message: %v", err)
+	}
+
+	// projects/<project>/secrets/<secret>
+	secretID := push.Message.Attributes["secretId"]
+	if secretID == "" {
+		return nil, false, nil
+	}
+
+	parts := strings.Split(secretID, "/")
+	return []string{parts[len(parts)-1]}, false, nil
+}
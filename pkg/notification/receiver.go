@@ -0,0 +1,225 @@
+// Package notification implements an HTTP receiver for secret change
+// notifications pushed by providers. Instead of waiting for the next
+// refreshInterval, every ExternalSecret referencing a changed remote key is
+// enqueued for reconciliation as soon as the notification arrives.
+//
+// Supported payloads:
+//
+//	POST /aws    AWS EventBridge events, directly or wrapped in an SNS notification
+//	POST /gcp    GCP Pub/Sub push messages from Secret Manager
+//	POST /azure  Azure Event Grid events from Key Vault
+package notification
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	secretsv1alpha1 "github.com/containersolutions/externalsecret-operator/apis/secrets/v1alpha1"
+	storev1alpha1 "github.com/containersolutions/externalsecret-operator/apis/store/v1alpha1"
+	"github.com/containersolutions/externalsecret-operator/pkg/backend"
+	config "github.com/containersolutions/externalsecret-operator/pkg/config"
+	"github.com/containersolutions/externalsecret-operator/pkg/store"
+)
+
+const (
+	// TokenQueryParameter is the query parameter carrying the shared token
+	TokenQueryParameter = "token"
+
+	maxBodySize     = 1 << 20
+	shutdownTimeout = time.Second * 10
+)
+
+// parser extracts the changed remote keys from a notification body. When
+// the notification requires an answer (e.g. subscription handshakes) the
+// parser writes it to w and returns handled = true.
+type parser func(r *Receiver, w http.ResponseWriter, body []byte) (keys []string, handled bool, err error)
+
+// Receiver serves provider change notifications and sends a GenericEvent
+// for every ExternalSecret referencing a changed key
+type Receiver struct {
+	client.Client
+	Log logr.Logger
+	// Addr is the address the receiver binds to
+	Addr string
+	// Token must be passed in the `token` query parameter of every notification,
+	// the receiver does not start without it
+	Token string
+	// Cache values of changed keys are dropped before enqueueing
+	Cache *backend.Cache
+	// Events receives the ExternalSecrets to reconcile
+	Events chan<- event.GenericEvent
+	// HTTPClient is used to confirm SNS subscriptions
+	HTTPClient *http.Client
+}
+
+// Start implements manager.Runnable
+func (r *Receiver) Start(stop <-chan struct{}) error {
+	if r.Token == "" {
+		return fmt.Errorf("notification receiver needs a token")
+	}
+
+	listener, err := net.Listen("tcp", r.Addr)
+	if err != nil {
+		return err
+	}
+
+	server := &http.Server{Handler: r.Handler()}
+	go func() {
+		<-stop
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := server.Shutdown(ctx); err != nil {
+			r.Log.Error(err, "Failed to shut down notification receiver")
+		}
+	}()
+
+	r.Log.Info("Starting notification receiver", "addr", r.Addr)
+	if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+// Handler returns the http.Handler serving every provider endpoint
+func (r *Receiver) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/aws", r.handle("aws", "asm", parseAWS))
+	mux.Handle("/gcp", r.handle("gcp", "gsm", parseGCP))
+	mux.Handle("/azure", r.handle("azure", "akv", parseAzure))
+	return mux
+}
+
+// handle serves the notifications of provider, about the secrets of the
+// SecretStores of type backendType
+func (r *Receiver) handle(provider string, backendType string, parse parser) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		log := r.Log.WithValues("provider", provider)
+
+		if req.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		if !r.authorized(req) {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		body, err := ioutil.ReadAll(http.MaxBytesReader(w, req.Body, maxBodySize))
+		if err != nil {
+			http.Error(w, "unable to read body", http.StatusBadRequest)
+			return
+		}
+
+		keys, handled, err := parse(r, w, body)
+		if err != nil {
+			log.Error(err, "Invalid notification")
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if handled {
+			return
+		}
+
+		if err := r.enqueue(req.Context(), backendType, keys); err != nil {
+			log.Error(err, "Failed to enqueue ExternalSecrets")
+			http.Error(w, "unable to enqueue", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+// authorized tells whether req carries the token. Notifications are not
+// signed, e.g. SNS signatures are not verified, the token authenticates them.
+func (r *Receiver) authorized(req *http.Request) bool {
+	if r.Token == "" {
+		return false
+	}
+
+	token := req.URL.Query().Get(TokenQueryParameter)
+	return subtle.ConstantTimeCompare([]byte(token), []byte(r.Token)) == 1
+}
+
+// enqueue drops the cached values of keys in the SecretStores of type
+// backendType, and sends an event for every ExternalSecret of these stores
+// referencing one of keys
+func (r *Receiver) enqueue(ctx context.Context, backendType string, keys []string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	secretStores := &storev1alpha1.SecretStoreList{}
+	if err := r.List(ctx, secretStores); err != nil {
+		return err
+	}
+
+	stores := map[string]bool{}
+	for i := range secretStores.Items {
+		storeConfig, err := config.ConfigFromCtrl(secretStores.Items[i].Spec.Store.Raw)
+		if err != nil || storeConfig.Type != backendType {
+			continue
+		}
+
+		backendName := store.BackendName(&secretStores.Items[i])
+		stores[backendName] = true
+		for _, key := range keys {
+			r.Cache.InvalidateKey(backendName, key)
+		}
+	}
+
+	changed := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		changed[key] = true
+	}
+
+	externalSecrets := &secretsv1alpha1.ExternalSecretList{}
+	if err := r.List(ctx, externalSecrets); err != nil {
+		return err
+	}
+
+	for i := range externalSecrets.Items {
+		externalSecret := &externalSecrets.Items[i]
+		if !stores[externalSecret.Namespace+"/"+externalSecret.Spec.StoreRef.Name] || !references(externalSecret, changed) {
+			continue
+		}
+
+		r.Log.Info("Enqueueing ExternalSecret", "externalsecret", fmt.Sprintf("%s/%s", externalSecret.Namespace, externalSecret.Name))
+		select {
+		case r.Events <- event.GenericEvent{Meta: externalSecret, Object: externalSecret}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	return nil
+}
+
+// references tells whether externalSecret reads one of keys, through its data
+// or the prefix of its dataFrom
+func references(externalSecret *secretsv1alpha1.ExternalSecret, keys map[string]bool) bool {
+	for _, data := range externalSecret.Spec.Data {
+		if keys[data.Key] {
+			return true
+		}
+	}
+	for _, dataFrom := range externalSecret.Spec.DataFrom {
+		for key := range keys {
+			if strings.HasPrefix(key, dataFrom.Prefix) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,225 @@
+package notification
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	secretsv1alpha1 "github.com/containersolutions/externalsecret-operator/apis/secrets/v1alpha1"
+	storev1alpha1 "github.com/containersolutions/externalsecret-operator/apis/store/v1alpha1"
+	"github.com/containersolutions/externalsecret-operator/pkg/backend"
+)
+
+type recordingTransport struct {
+	requests []*http.Request
+}
+
+func (t *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.requests = append(t.requests, req)
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Status:     "200 OK",
+		Body:       ioutil.NopCloser(bytes.NewReader(nil)),
+	}, nil
+}
+
+func newSecretStore(name string, backendType string) *storev1alpha1.SecretStore {
+	return &storev1alpha1.SecretStore{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Spec: storev1alpha1.SecretStoreSpec{
+			Store: runtime.RawExtension{Raw: []byte(`{"type": "` + backendType + `"}`)},
+		},
+	}
+}
+
+func newExternalSecret(name string, store string, keys ...string) *secretsv1alpha1.ExternalSecret {
+	data := []secretsv1alpha1.ExternalSecretData{}
+	for _, key := range keys {
+		data = append(data, secretsv1alpha1.ExternalSecretData{Key: key})
+	}
+
+	return &secretsv1alpha1.ExternalSecret{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Spec: secretsv1alpha1.ExternalSecretSpec{
+			StoreRef: secretsv1alpha1.ExternalSecretStoreRef{Name: store},
+			Data:     data,
+		},
+	}
+}
+
+func newListingExternalSecret(name string, store string, prefix string) *secretsv1alpha1.ExternalSecret {
+	externalSecret := newExternalSecret(name, store)
+	externalSecret.Spec.DataFrom = []secretsv1alpha1.ExternalSecretDataFrom{{Prefix: prefix}}
+	return externalSecret
+}
+
+func fixture(name string) []byte {
+	data, err := ioutil.ReadFile(filepath.Join("testdata", name))
+	So(err, ShouldBeNil)
+	return data
+}
+
+func post(handler http.Handler, path string, body []byte) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, path, bytes.NewReader(body)))
+	return recorder
+}
+
+func enqueued(events chan event.GenericEvent) []string {
+	names := []string{}
+	for {
+		select {
+		case e := <-events:
+			names = append(names, e.Meta.GetName())
+		default:
+			sort.Strings(names)
+			return names
+		}
+	}
+}
+
+func TestReceiver(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := secretsv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := storev1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	Convey("Given a receiver and ExternalSecrets referencing remote keys", t, func() {
+		events := make(chan event.GenericEvent, 10)
+		transport := &recordingTransport{}
+		cache := backend.NewCache(backend.DefaultCacheTTL, backend.DefaultCacheSize)
+		receiver := &Receiver{
+			Client: fake.NewFakeClientWithScheme(scheme,
+				newSecretStore("asm-store", "asm"),
+				newSecretStore("gsm-store", "gsm"),
+				newSecretStore("akv-store", "akv"),
+				newExternalSecret("by-name", "asm-store", "prod/db-ca"),
+				newExternalSecret("by-arn", "asm-store", "arn:aws:secretsmanager:eu-west-2:111122223333:secret:prod/db-ca-AbC123"),
+				newExternalSecret("gsm", "gsm-store", "shared-db-ca", "other"),
+				newExternalSecret("asm-same-key", "asm-store", "shared-db-ca"),
+				newExternalSecret("akv", "akv-store", "db-password"),
+				newExternalSecret("unrelated", "asm-store", "unrelated"),
+				newListingExternalSecret("by-prefix", "asm-store", "prod/"),
+				newListingExternalSecret("other-prefix", "asm-store", "staging/"),
+				newListingExternalSecret("gsm-prefix", "gsm-store", "prod/"),
+			),
+			Log:        ctrl.Log.WithName("test"),
+			Token:      "s3cr3t",
+			Cache:      cache,
+			Events:     events,
+			HTTPClient: &http.Client{Transport: transport},
+		}
+		handler := receiver.Handler()
+
+		Convey("When receiving an EventBridge event", func() {
+			resp := post(handler, "/aws?token=s3cr3t", fixture("aws-eventbridge.json"))
+			Convey("Then ExternalSecrets referencing the secret by name or ARN are enqueued", func() {
+				So(resp.Code, ShouldEqual, http.StatusOK)
+				So(enqueued(events), ShouldResemble, []string{"by-arn", "by-name", "by-prefix"})
+			})
+		})
+
+		Convey("When receiving an EventBridge event of a read", func() {
+			body := bytes.Replace(fixture("aws-eventbridge.json"), []byte(`"PutSecretValue"`), []byte(`"GetSecretValue"`), 1)
+			resp := post(handler, "/aws?token=s3cr3t", body)
+			Convey("Then it is ignored", func() {
+				So(resp.Code, ShouldEqual, http.StatusOK)
+				So(enqueued(events), ShouldBeEmpty)
+			})
+		})
+
+		Convey("When receiving an SNS notification", func() {
+			resp := post(handler, "/aws?token=s3cr3t", fixture("aws-sns-notification.json"))
+			Convey("Then the wrapped EventBridge event is handled", func() {
+				So(resp.Code, ShouldEqual, http.StatusOK)
+				So(enqueued(events), ShouldResemble, []string{"by-arn", "by-name", "by-prefix"})
+			})
+		})
+
+		Convey("When receiving an SNS subscription confirmation", func() {
+			resp := post(handler, "/aws?token=s3cr3t", fixture("aws-sns-subscription.json"))
+			Convey("Then the subscription is confirmed", func() {
+				So(resp.Code, ShouldEqual, http.StatusOK)
+				So(len(transport.requests), ShouldEqual, 1)
+				So(transport.requests[0].URL.Host, ShouldEqual, "sns.eu-west-2.amazonaws.com")
+				So(enqueued(events), ShouldBeEmpty)
+			})
+		})
+
+		Convey("When receiving an SNS subscription confirmation outside of AWS", func() {
+			body := bytes.Replace(fixture("aws-sns-subscription.json"), []byte("sns.eu-west-2.amazonaws.com"), []byte("example.com"), 1)
+			resp := post(handler, "/aws?token=s3cr3t", body)
+			Convey("Then it is rejected", func() {
+				So(resp.Code, ShouldEqual, http.StatusBadRequest)
+				So(transport.requests, ShouldBeEmpty)
+			})
+		})
+
+		Convey("When receiving a Pub/Sub push message", func() {
+			old := func() (*backend.Value, error) { return backend.NewValue([]byte("old"), ""), nil }
+			cache.Get("default/gsm-store", "shared-db-ca", "", old)
+			cache.Get("default/asm-store", "shared-db-ca", "", old)
+			resp := post(handler, "/gcp?token=s3cr3t", fixture("gcp-pubsub.json"))
+			Convey("Then only ExternalSecrets of GSM stores are enqueued and have their cached value dropped", func() {
+				So(resp.Code, ShouldEqual, http.StatusOK)
+				So(enqueued(events), ShouldResemble, []string{"gsm"})
+				So(cache.Len(), ShouldEqual, 1)
+			})
+		})
+
+		Convey("When receiving an Event Grid event", func() {
+			resp := post(handler, "/azure?token=s3cr3t", fixture("azure-eventgrid.json"))
+			Convey("Then ExternalSecrets referencing the secret are enqueued", func() {
+				So(resp.Code, ShouldEqual, http.StatusOK)
+				So(enqueued(events), ShouldResemble, []string{"akv"})
+			})
+		})
+
+		Convey("When receiving an Event Grid subscription validation", func() {
+			resp := post(handler, "/azure?token=s3cr3t", fixture("azure-validation.json"))
+			Convey("Then the validation code is echoed", func() {
+				So(resp.Code, ShouldEqual, http.StatusOK)
+				So(resp.Body.String(), ShouldContainSubstring, `"validationResponse":"512d38b6-c7b8-40c8-89fe-f46f9e9622b6"`)
+				So(enqueued(events), ShouldBeEmpty)
+			})
+		})
+
+		Convey("When receiving an invalid payload", func() {
+			resp := post(handler, "/gcp?token=s3cr3t", []byte("garbage"))
+			Convey("Then it is rejected", func() {
+				So(resp.Code, ShouldEqual, http.StatusBadRequest)
+			})
+		})
+
+		Convey("When a notification does not carry the token", func() {
+			resp := post(handler, "/gcp", fixture("gcp-pubsub.json"))
+			Convey("Then it is rejected", func() {
+				So(resp.Code, ShouldEqual, http.StatusUnauthorized)
+				So(enqueued(events), ShouldBeEmpty)
+			})
+		})
+
+		Convey("When no token is configured", func() {
+			receiver.Token = ""
+			resp := post(handler, "/gcp?token=", fixture("gcp-pubsub.json"))
+			Convey("Then every notification is rejected", func() {
+				So(resp.Code, ShouldEqual, http.StatusUnauthorized)
+				So(enqueued(events), ShouldBeEmpty)
+			})
+		})
+	})
+}
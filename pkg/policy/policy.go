@@ -0,0 +1,109 @@
+// Package policy decides which remote keys ExternalSecrets may read from a
+// SecretStore
+package policy
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	secretsv1alpha1 "github.com/containersolutions/externalsecret-operator/apis/secrets/v1alpha1"
+	storev1alpha1 "github.com/containersolutions/externalsecret-operator/apis/store/v1alpha1"
+)
+
+// DeniedError lists the remote keys an ExternalSecret may not read
+type DeniedError struct {
+	Store string
+	Keys  []string
+}
+
+func (e *DeniedError) Error() string {
+	return fmt.Sprintf("SecretStore %s denies reading keys %s", e.Store, strings.Join(e.Keys, ", "))
+}
+
+// Allowed tells whether p allows an ExternalSecret in namespace and labelled
+// with esLabels to read key. Every key is allowed without policy.
+func Allowed(p *storev1alpha1.SecretStorePolicy, key string, namespace string, esLabels map[string]string) (bool, error) {
+	if p == nil {
+		return true, nil
+	}
+
+	for _, rule := range p.Rules {
+		matches, err := ruleMatches(rule, key, namespace, esLabels)
+		if err != nil {
+			return false, err
+		}
+		if matches {
+			return rule.Action == storev1alpha1.PolicyActionAllow, nil
+		}
+	}
+	return p.DefaultAction == storev1alpha1.PolicyActionAllow, nil
+}
+
+// Check returns a *DeniedError when the policy of st denies s to read the keys
+// of its data. Keys listed by dataFrom are filtered instead, see Allowed.
+func Check(st *storev1alpha1.SecretStore, s *secretsv1alpha1.ExternalSecret) error {
+	if st.Spec.Policy == nil {
+		return nil
+	}
+
+	var denied []string
+	for _, data := range s.Spec.Data {
+		allowed, err := Allowed(st.Spec.Policy, data.Key, s.Namespace, s.Labels)
+		if err != nil {
+			return err
+		}
+		if !allowed {
+			denied = append(denied, data.Key)
+		}
+	}
+	if len(denied) > 0 {
+		return &DeniedError{Store: st.Name, Keys: denied}
+	}
+	return nil
+}
+
+// ruleMatches tells whether rule matches key and the namespace and labels of
+// the ExternalSecret. The namespace is the only attribute the creator of the
+// ExternalSecret cannot choose.
+func ruleMatches(rule storev1alpha1.SecretStorePolicyRule, key string, namespace string, esLabels map[string]string) (bool, error) {
+	if len(rule.Namespaces) > 0 && !contains(rule.Namespaces, namespace) {
+		return false, nil
+	}
+
+	if rule.Selector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(rule.Selector)
+		if err != nil {
+			return false, fmt.Errorf("invalid policy selector: %w", err)
+		}
+		if !selector.Matches(labels.Set(esLabels)) {
+			return false, nil
+		}
+	}
+
+	if len(rule.Keys) == 0 {
+		return true, nil
+	}
+	for _, pattern := range rule.Keys {
+		matches, err := path.Match(pattern, key)
+		if err != nil {
+			return false, fmt.Errorf("invalid policy key pattern %q: %w", pattern, err)
+		}
+		if matches {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,129 @@
+package policy
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	secretsv1alpha1 "github.com/containersolutions/externalsecret-operator/apis/secrets/v1alpha1"
+	storev1alpha1 "github.com/containersolutions/externalsecret-operator/apis/store/v1alpha1"
+)
+
+func TestAllowed(t *testing.T) {
+	Convey("Given no policy", t, func() {
+		Convey("Then every key is allowed", func() {
+			allowed, err := Allowed(nil, "prod/db/password", "default", nil)
+			So(err, ShouldBeNil)
+			So(allowed, ShouldBeTrue)
+		})
+	})
+
+	Convey("Given a policy", t, func() {
+		p := &storev1alpha1.SecretStorePolicy{
+			Rules: []storev1alpha1.SecretStorePolicyRule{
+				{
+					Action: storev1alpha1.PolicyActionDeny,
+					Keys:   []string{"shared/admin-*"},
+				},
+				{
+					Action: storev1alpha1.PolicyActionAllow,
+					Keys:   []string{"shared/*"},
+				},
+				{
+					Action:   storev1alpha1.PolicyActionAllow,
+					Keys:     []string{"team-a/*"},
+					Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"team": "a"}},
+				},
+				{
+					Action:     storev1alpha1.PolicyActionAllow,
+					Keys:       []string{"team-c/*"},
+					Namespaces: []string{"team-c", "team-c-staging"},
+				},
+			},
+		}
+		teamA := map[string]string{"team": "a"}
+
+		Convey("Then the first matching rule applies", func() {
+			tests := []struct {
+				key       string
+				namespace string
+				labels    map[string]string
+				expected  bool
+			}{
+				{"shared/db", "default", nil, true},
+				{"shared/admin-token", "default", teamA, false},
+				{"shared/nested/db", "default", nil, false},
+				{"team-a/db", "default", teamA, true},
+				{"team-a/db", "default", map[string]string{"team": "b"}, false},
+				{"team-b/db", "default", teamA, false},
+				{"team-c/db", "team-c-staging", nil, true},
+				{"team-c/db", "default", map[string]string{"team": "c"}, false},
+			}
+			for _, tt := range tests {
+				allowed, err := Allowed(p, tt.key, tt.namespace, tt.labels)
+				So(err, ShouldBeNil)
+				So(allowed, ShouldEqual, tt.expected)
+			}
+		})
+
+		Convey("When the default action is Allow", func() {
+			p.DefaultAction = storev1alpha1.PolicyActionAllow
+			Convey("Then keys matching no rule are allowed", func() {
+				allowed, err := Allowed(p, "team-b/db", "default", teamA)
+				So(err, ShouldBeNil)
+				So(allowed, ShouldBeTrue)
+			})
+		})
+
+		Convey("When a pattern is invalid", func() {
+			p.Rules = []storev1alpha1.SecretStorePolicyRule{{Action: storev1alpha1.PolicyActionAllow, Keys: []string{"["}}}
+			Convey("Then an error is returned", func() {
+				_, err := Allowed(p, "key", "default", nil)
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+}
+
+func TestCheck(t *testing.T) {
+	Convey("Given a SecretStore allowing shared keys", t, func() {
+		st := &storev1alpha1.SecretStore{
+			ObjectMeta: metav1.ObjectMeta{Name: "store"},
+			Spec: storev1alpha1.SecretStoreSpec{
+				Policy: &storev1alpha1.SecretStorePolicy{
+					Rules: []storev1alpha1.SecretStorePolicyRule{
+						{Action: storev1alpha1.PolicyActionAllow, Keys: []string{"shared/*"}},
+					},
+				},
+			},
+		}
+		s := &secretsv1alpha1.ExternalSecret{
+			Spec: secretsv1alpha1.ExternalSecretSpec{
+				Data: []secretsv1alpha1.ExternalSecretData{
+					{Key: "shared/db"},
+					{Key: "prod/db"},
+					{Key: "prod/api"},
+				},
+			},
+		}
+
+		Convey("When checking an ExternalSecret reading other keys", func() {
+			err := Check(st, s)
+			Convey("Then the denied keys are reported", func() {
+				So(err, ShouldNotBeNil)
+				denied, ok := err.(*DeniedError)
+				So(ok, ShouldBeTrue)
+				So(denied.Keys, ShouldResemble, []string{"prod/db", "prod/api"})
+				So(err.Error(), ShouldEqual, "SecretStore store denies reading keys prod/db, prod/api")
+			})
+		})
+
+		Convey("When checking an ExternalSecret only reading shared keys", func() {
+			s.Spec.Data = s.Spec.Data[:1]
+			Convey("Then it is allowed", func() {
+				So(Check(st, s), ShouldBeNil)
+			})
+		})
+	})
+}
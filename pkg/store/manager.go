@@ -0,0 +1,291 @@
+// Package store initializes the backends of SecretStores on demand.
+package store
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	storev1alpha1 "github.com/containersolutions/externalsecret-operator/apis/store/v1alpha1"
+	"github.com/containersolutions/externalsecret-operator/pkg/backend"
+	config "github.com/containersolutions/externalsecret-operator/pkg/config"
+)
+
+// CredentialsKey is the key of the credentials Secret holding the backend credentials
+const CredentialsKey = "credentials.json"
+
+// Manager initializes the backends of SecretStores. The SecretStore and the
+// ExternalSecret controllers both go through it, so that an ExternalSecret
+// reconciled before its store, e.g. after a restart, initializes the backend
+// instead of failing.
+type Manager struct {
+	client   client.Client
+	log      logr.Logger
+	backends *backend.Registry
+	cache    *backend.Cache
+
+	lock sync.Mutex
+	// backendLocks serialize the initialization and removal of each backend,
+	// so that a slow provider does not hold up the other SecretStores
+	backendLocks map[string]*sync.Mutex
+	// initialized holds the SecretStore revision each backend was initialized from
+	initialized map[string]string
+	// unhealthy holds the error of the last failed health check of each backend
+	unhealthy map[string]error
+
+	// selector and class restrict the SecretStores ReadyzCheck waits for
+	selector labels.Selector
+	class    string
+	// elected is closed once the operator instance is the leader, nil when
+	// it does not run leader election
+	elected <-chan struct{}
+}
+
+// NewManager returns a Manager reading SecretStore credentials with c and
+// registering backends in backends. The values of cache are invalidated
+// whenever a backend is initialized.
+func NewManager(c client.Client, log logr.Logger, backends *backend.Registry, cache *backend.Cache) *Manager {
+	return &Manager{
+		client:       c,
+		log:          log,
+		backends:     backends,
+		cache:        cache,
+		backendLocks: make(map[string]*sync.Mutex),
+		initialized:  make(map[string]string),
+		unhealthy:    make(map[string]error),
+	}
+}
+
+// Select restricts the SecretStores ReadyzCheck waits for to those the
+// operator instance reconciles, see Selected
+func (m *Manager) Select(selector labels.Selector, class string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.selector = selector
+	m.class = class
+}
+
+// SetElected makes ReadyzCheck report ready until elected is closed. Only
+// the leader reconciles SecretStores, an instance waiting for the leader
+// election would otherwise never be ready, e.g. blocking a rolling update.
+func (m *Manager) SetElected(elected <-chan struct{}) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.elected = elected
+}
+
+// Init initializes the backend of secretStore, unless it is already
+// initialized from the same SecretStore spec and credentials
+func (m *Manager) Init(ctx context.Context, secretStore *storev1alpha1.SecretStore) error {
+	backendName := BackendName(secretStore)
+
+	if !secretStore.DeletionTimestamp.IsZero() {
+		return fmt.Errorf("SecretStore %s is being deleted", secretStore.Name)
+	}
+
+	storeConfig, err := config.ConfigFromCtrl(secretStore.Spec.Store.Raw)
+	if err != nil {
+		return err
+	}
+
+	credentials, credentialsVersion, err := m.credentials(ctx, secretStore)
+	if err != nil {
+		return err
+	}
+
+	revision := fmt.Sprintf("%s/%d/%s", secretStore.UID, secretStore.Generation, credentialsVersion)
+
+	backendLock := m.backendLock(backendName)
+	backendLock.Lock()
+	defer backendLock.Unlock()
+
+	m.lock.Lock()
+	initialized := m.initialized[backendName] == revision
+	m.lock.Unlock()
+
+	if _, found := m.backends.Get(backendName); found && initialized {
+		return nil
+	}
+
+	m.log.Info("Initializing backend", "secretstore", secretStore.Name, "backend", backendName)
+	err = m.backends.Init(ctx, backendName, storeConfig, credentials)
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if err != nil {
+		delete(m.initialized, backendName)
+		return err
+	}
+
+	m.initialized[backendName] = revision
+	delete(m.unhealthy, backendName)
+	m.cache.Invalidate(backendName)
+
+	return nil
+}
+
+// Remove closes the backend of secretStore and drops its cached values
+func (m *Manager) Remove(secretStore *storev1alpha1.SecretStore) error {
+	backendName := BackendName(secretStore)
+
+	backendLock := m.backendLock(backendName)
+	backendLock.Lock()
+	defer backendLock.Unlock()
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.log.Info("Removing backend", "secretstore", secretStore.Name, "backend", backendName)
+	delete(m.initialized, backendName)
+	delete(m.unhealthy, backendName)
+	m.cache.Invalidate(backendName)
+
+	return m.backends.Remove(backendName)
+}
+
+// backendLock returns the lock serializing the initialization and removal of
+// the backend backendName
+func (m *Manager) backendLock(backendName string) *sync.Mutex {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	backendLock, found := m.backendLocks[backendName]
+	if !found {
+		backendLock = &sync.Mutex{}
+		m.backendLocks[backendName] = backendLock
+	}
+	return backendLock
+}
+
+// HealthCheck checks the backend of secretStore can reach its provider. It
+// returns false, and no error, when the backend has no health check.
+func (m *Manager) HealthCheck(ctx context.Context, secretStore *storev1alpha1.SecretStore) (bool, error) {
+	backendName := BackendName(secretStore)
+
+	instance, found := m.backends.Get(backendName)
+	if !found {
+		return false, fmt.Errorf("backend %s is not initialized", backendName)
+	}
+
+	checker, ok := instance.(backend.HealthChecker)
+	if !ok {
+		return false, nil
+	}
+
+	checkCtx, cancel := m.backends.Context(ctx, backendName)
+	defer cancel()
+	err := checker.HealthCheck(checkCtx)
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if err != nil {
+		m.unhealthy[backendName] = err
+		return true, err
+	}
+	delete(m.unhealthy, backendName)
+	return true, nil
+}
+
+// ReadyzCheck is a healthz.Checker failing while a SecretStore does not have
+// its backend initialized, or its backend failed its last health check. It
+// does not fail before the instance is elected, see SetElected.
+func (m *Manager) ReadyzCheck(req *http.Request) error {
+	m.lock.Lock()
+	elected := m.elected
+	m.lock.Unlock()
+
+	if elected != nil {
+		select {
+		case <-elected:
+		default:
+			return nil
+		}
+	}
+
+	secretStores := &storev1alpha1.SecretStoreList{}
+	err := m.client.List(req.Context(), secretStores)
+	if err != nil {
+		return fmt.Errorf("failed to list SecretStores: %w", err)
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	for i, secretStore := range secretStores.Items {
+		if !secretStore.DeletionTimestamp.IsZero() || !Selected(&secretStores.Items[i], m.selector, m.class) {
+			continue
+		}
+
+		backendName := BackendName(&secretStores.Items[i])
+		if _, found := m.initialized[backendName]; !found {
+			return fmt.Errorf("SecretStore %s/%s is not initialized", secretStore.Namespace, secretStore.Name)
+		}
+		if err := m.unhealthy[backendName]; err != nil {
+			return fmt.Errorf("SecretStore %s/%s is unhealthy: %w", secretStore.Namespace, secretStore.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// credentials returns the content and resource version of the credentials
+// Secret of secretStore, the credentials are empty when it has none
+func (m *Manager) credentials(ctx context.Context, secretStore *storev1alpha1.SecretStore) ([]byte, string, error) {
+	name := CredentialsSecretName(secretStore)
+	if name == "" {
+		return nil, "", nil
+	}
+
+	credentialsSecret := &corev1.Secret{}
+	err := m.client.Get(ctx, types.NamespacedName{Name: name, Namespace: secretStore.Namespace}, credentialsSecret)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get credentials Secret %s: %w", name, err)
+	}
+
+	return credentialsSecret.Data[CredentialsKey], credentialsSecret.ResourceVersion, nil
+}
+
+// BackendName returns the name the backend of secretStore is registered under,
+// unique per SecretStore. Spec.Controller is the controller class of the
+// SecretStore and may be shared.
+func BackendName(secretStore *storev1alpha1.SecretStore) string {
+	return secretStore.Namespace + "/" + secretStore.Name
+}
+
+// Selected tells whether an operator instance reconciling the objects matching
+// selector, and the SecretStores of the controller class, reconciles
+// secretStore. A nil selector and an empty class select every SecretStore.
+func Selected(secretStore *storev1alpha1.SecretStore, selector labels.Selector, class string) bool {
+	if selector != nil && !selector.Matches(labels.Set(secretStore.Labels)) {
+		return false
+	}
+	return class == "" || secretStore.Spec.Controller == class
+}
+
+// CredentialsSecretName returns the name of the credentials Secret referenced
+// by auth.secretRef in the store config of secretStore, if any
+func CredentialsSecretName(secretStore *storev1alpha1.SecretStore) string {
+	storeConfig, err := config.ConfigFromCtrl(secretStore.Spec.Store.Raw)
+	if err != nil {
+		return ""
+	}
+
+	secretRef, ok := storeConfig.Auth["secretRef"].(map[string]interface{})
+	if !ok {
+		return ""
+	}
+
+	name, _ := secretRef["name"].(string)
+	return name
+}
@@ -0,0 +1,324 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	storev1alpha1 "github.com/containersolutions/externalsecret-operator/apis/store/v1alpha1"
+	"github.com/containersolutions/externalsecret-operator/pkg/backend"
+)
+
+var (
+	inits     int
+	healthErr error
+)
+
+type mockBackend struct {
+	credentials []byte
+}
+
+func (m *mockBackend) Init(ctx context.Context, parameters map[string]interface{}, credentials []byte) error {
+	inits++
+	m.credentials = credentials
+	return nil
+}
+
+func (m *mockBackend) Get(ctx context.Context, key string, version string) (*backend.Value, error) {
+	return backend.NewValue(m.credentials, ""), nil
+}
+
+func (m *mockBackend) HealthCheck(ctx context.Context) error {
+	return healthErr
+}
+
+// blockingBackend waits in Init until release is closed
+type blockingBackend struct {
+	mockBackend
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingBackend) Init(ctx context.Context, parameters map[string]interface{}, credentials []byte) error {
+	close(b.entered)
+	<-b.release
+	return nil
+}
+
+func newSecretStore(storeConfig string) *storev1alpha1.SecretStore {
+	return &storev1alpha1.SecretStore{
+		ObjectMeta: metav1.ObjectMeta{Name: "store", Namespace: "default", UID: "uid", Generation: 1},
+		Spec: storev1alpha1.SecretStoreSpec{
+			Controller: "store-manager-test",
+			Store:      runtime.RawExtension{Raw: []byte(storeConfig)},
+		},
+	}
+}
+
+func instance(backends *backend.Registry) backend.Backend {
+	instance, _ := backends.Get("default/store")
+	return instance
+}
+
+func TestManager(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := storev1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	backend.Register("store-manager-mock", func() backend.Backend { return &mockBackend{} })
+
+	ctx := context.Background()
+
+	Convey("Given a SecretStore with a credentials Secret", t, func() {
+		backends := backend.NewRegistry()
+		inits = 0
+
+		credentials := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "credentials", Namespace: "default", ResourceVersion: "1"},
+			Data:       map[string][]byte{CredentialsKey: []byte("v1")},
+		}
+		c := fake.NewFakeClientWithScheme(scheme, credentials)
+		manager := NewManager(c, ctrl.Log.WithName("test"), backends, nil)
+		secretStore := newSecretStore(`{"type": "store-manager-mock", "auth": {"secretRef": {"name": "credentials"}}}`)
+
+		Convey("When initializing it twice", func() {
+			So(manager.Init(ctx, secretStore), ShouldBeNil)
+			So(manager.Init(ctx, secretStore), ShouldBeNil)
+			Convey("Then the backend is initialized once with the credentials", func() {
+				So(inits, ShouldEqual, 1)
+				value, err := instance(backends).Get(ctx, "", "")
+				So(err, ShouldBeNil)
+				So(string(value.Data), ShouldEqual, "v1")
+			})
+		})
+
+		Convey("When the SecretStore spec changes", func() {
+			So(manager.Init(ctx, secretStore), ShouldBeNil)
+			secretStore.Generation++
+			So(manager.Init(ctx, secretStore), ShouldBeNil)
+			Convey("Then the backend is initialized again", func() {
+				So(inits, ShouldEqual, 2)
+			})
+		})
+
+		Convey("When the credentials change", func() {
+			So(manager.Init(ctx, secretStore), ShouldBeNil)
+			credentials.Data[CredentialsKey] = []byte("v2")
+			So(c.Update(ctx, credentials), ShouldBeNil)
+			So(manager.Init(ctx, secretStore), ShouldBeNil)
+			Convey("Then the backend is initialized with the new credentials", func() {
+				So(inits, ShouldEqual, 2)
+				value, _ := instance(backends).Get(ctx, "", "")
+				So(string(value.Data), ShouldEqual, "v2")
+			})
+		})
+	})
+
+	Convey("Given an initialized SecretStore", t, func() {
+		backends := backend.NewRegistry()
+		manager := NewManager(fake.NewFakeClientWithScheme(scheme), ctrl.Log.WithName("test"), backends, nil)
+		secretStore := newSecretStore(`{"type": "store-manager-mock"}`)
+		So(manager.Init(ctx, secretStore), ShouldBeNil)
+
+		Convey("When removing it", func() {
+			err := manager.Remove(secretStore)
+			Convey("Then the backend instance is removed", func() {
+				So(err, ShouldBeNil)
+				_, found := backends.Get("default/store")
+				So(found, ShouldBeFalse)
+			})
+		})
+
+		Convey("When it is being deleted", func() {
+			now := metav1.Now()
+			secretStore.DeletionTimestamp = &now
+			inits = 0
+			err := manager.Init(ctx, secretStore)
+			Convey("Then its backend is not initialized again", func() {
+				So(err, ShouldNotBeNil)
+				So(inits, ShouldEqual, 0)
+			})
+		})
+	})
+
+	Convey("Given a SecretStore without credentials", t, func() {
+		backends := backend.NewRegistry()
+		inits = 0
+		manager := NewManager(fake.NewFakeClientWithScheme(scheme), ctrl.Log.WithName("test"), backends, nil)
+		secretStore := newSecretStore(`{"type": "store-manager-mock"}`)
+
+		Convey("When initializing it", func() {
+			err := manager.Init(ctx, secretStore)
+			Convey("Then the backend is initialized with empty credentials", func() {
+				So(err, ShouldBeNil)
+				So(inits, ShouldEqual, 1)
+			})
+		})
+	})
+
+	Convey("Given a SecretStore with a missing credentials Secret", t, func() {
+		backends := backend.NewRegistry()
+		manager := NewManager(fake.NewFakeClientWithScheme(scheme), ctrl.Log.WithName("test"), backends, nil)
+		secretStore := newSecretStore(`{"type": "store-manager-mock", "auth": {"secretRef": {"name": "missing"}}}`)
+
+		Convey("When initializing it", func() {
+			err := manager.Init(ctx, secretStore)
+			Convey("Then an error is returned", func() {
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldStartWith, "failed to get credentials Secret missing")
+			})
+		})
+	})
+
+	Convey("Given a SecretStore", t, func() {
+		backends := backend.NewRegistry()
+		secretStore := newSecretStore(`{"type": "store-manager-mock"}`)
+		manager := NewManager(fake.NewFakeClientWithScheme(scheme, secretStore.DeepCopy()), ctrl.Log.WithName("test"), backends, nil)
+		req := httptest.NewRequest(http.MethodGet, "/readyz", nil)
+		healthErr = nil
+
+		Convey("When its backend is not initialized", func() {
+			err := manager.ReadyzCheck(req)
+			Convey("Then the operator is not ready", func() {
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldContainSubstring, "is not initialized")
+			})
+		})
+
+		Convey("When the operator instance is not the leader yet", func() {
+			elected := make(chan struct{})
+			manager.SetElected(elected)
+			Convey("Then the operator is ready until it is elected", func() {
+				So(manager.ReadyzCheck(req), ShouldBeNil)
+				close(elected)
+				So(manager.ReadyzCheck(req), ShouldNotBeNil)
+			})
+		})
+
+		Convey("When it belongs to another controller class", func() {
+			manager.Select(nil, "other-class")
+			Convey("Then the operator does not wait for it", func() {
+				So(manager.ReadyzCheck(req), ShouldBeNil)
+			})
+		})
+
+		Convey("When its backend passes its health check", func() {
+			So(manager.Init(ctx, secretStore), ShouldBeNil)
+			checked, err := manager.HealthCheck(ctx, secretStore)
+			Convey("Then the operator is ready", func() {
+				So(checked, ShouldBeTrue)
+				So(err, ShouldBeNil)
+				So(manager.ReadyzCheck(req), ShouldBeNil)
+			})
+		})
+
+		Convey("When its backend fails its health check", func() {
+			So(manager.Init(ctx, secretStore), ShouldBeNil)
+			healthErr = errors.New("unreachable")
+			checked, err := manager.HealthCheck(ctx, secretStore)
+			healthErr = nil
+			Convey("Then the operator is not ready until it passes again", func() {
+				So(checked, ShouldBeTrue)
+				So(err, ShouldNotBeNil)
+				So(manager.ReadyzCheck(req), ShouldNotBeNil)
+
+				_, err = manager.HealthCheck(ctx, secretStore)
+				So(err, ShouldBeNil)
+				So(manager.ReadyzCheck(req), ShouldBeNil)
+			})
+		})
+	})
+}
+
+func TestSharedControllerClass(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := storev1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	backend.Register("store-manager-mock", func() backend.Backend { return &mockBackend{} })
+
+	Convey("Given two SecretStores of the same controller class", t, func() {
+		backends := backend.NewRegistry()
+		manager := NewManager(fake.NewFakeClientWithScheme(scheme), ctrl.Log.WithName("test"), backends, nil)
+		first := newSecretStore(`{"type": "store-manager-mock"}`)
+		second := newSecretStore(`{"type": "store-manager-mock"}`)
+		second.Name = "other-store"
+
+		Convey("When initializing them", func() {
+			So(manager.Init(context.Background(), first), ShouldBeNil)
+			So(manager.Init(context.Background(), second), ShouldBeNil)
+			Convey("Then each has its own backend", func() {
+				firstInstance, foundFirst := backends.Get(BackendName(first))
+				secondInstance, foundSecond := backends.Get(BackendName(second))
+				So(foundFirst, ShouldBeTrue)
+				So(foundSecond, ShouldBeTrue)
+				So(firstInstance, ShouldNotEqual, secondInstance)
+			})
+		})
+	})
+}
+
+func TestConcurrentInit(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := storev1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	backend.Register("store-manager-mock", func() backend.Backend { return &mockBackend{} })
+	blocking := &blockingBackend{entered: make(chan struct{}), release: make(chan struct{})}
+	backend.Register("store-manager-blocking", func() backend.Backend { return blocking })
+
+	Convey("Given a SecretStore whose provider does not answer", t, func() {
+		backends := backend.NewRegistry()
+		manager := NewManager(fake.NewFakeClientWithScheme(scheme), ctrl.Log.WithName("test"), backends, nil)
+		slow := newSecretStore(`{"type": "store-manager-blocking"}`)
+		slow.Name = "slow-store"
+
+		slowErr := make(chan error)
+		go func() { slowErr <- manager.Init(context.Background(), slow) }()
+		<-blocking.entered
+
+		Convey("When initializing another SecretStore", func() {
+			err := manager.Init(context.Background(), newSecretStore(`{"type": "store-manager-mock"}`))
+			Convey("Then it does not wait for the first one", func() {
+				So(err, ShouldBeNil)
+				So(instance(backends), ShouldNotBeNil)
+
+				close(blocking.release)
+				So(<-slowErr, ShouldBeNil)
+			})
+		})
+	})
+}
+
+func TestSelected(t *testing.T) {
+	Convey("Given a SecretStore", t, func() {
+		secretStore := newSecretStore(`{"type": "store-manager-mock"}`)
+		secretStore.Labels = map[string]string{"team": "a"}
+		teamA, err := labels.Parse("team=a")
+		So(err, ShouldBeNil)
+		teamB, err := labels.Parse("team=b")
+		So(err, ShouldBeNil)
+
+		Convey("Then it is selected by its labels and its controller class", func() {
+			So(Selected(secretStore, nil, ""), ShouldBeTrue)
+			So(Selected(secretStore, teamA, ""), ShouldBeTrue)
+			So(Selected(secretStore, teamB, ""), ShouldBeFalse)
+			So(Selected(secretStore, nil, "store-manager-test"), ShouldBeTrue)
+			So(Selected(secretStore, teamA, "other-class"), ShouldBeFalse)
+		})
+	})
+}
@@ -0,0 +1,201 @@
+package utils_test
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/containersolutions/externalsecret-operator/pkg/backend"
+	utils "github.com/containersolutions/externalsecret-operator/pkg/utils"
+)
+
+const stsResponse = `<%[1]sResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+  <%[1]sResult>
+    <Credentials>
+      <AccessKeyId>ASIA%[1]s</AccessKeyId>
+      <SecretAccessKey>roleSecret</SecretAccessKey>
+      <SessionToken>roleToken</SessionToken>
+      <Expiration>2100-01-01T00:00:00Z</Expiration>
+    </Credentials>
+  </%[1]sResult>
+</%[1]sResponse>`
+
+var _ = Describe("AWSSessionConfig.NewSession", func() {
+	var (
+		sts      *httptest.Server
+		requests []url.Values
+		config   utils.AWSSessionConfig
+		ctx      = backend.WithAmbientCredentials(context.Background())
+	)
+
+	BeforeEach(func() {
+		requests = nil
+		sts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			Expect(r.ParseForm()).To(Succeed())
+			requests = append(requests, r.PostForm)
+			fmt.Fprintf(w, stsResponse, r.PostForm.Get("Action"))
+		}))
+		config = utils.AWSSessionConfig{DefaultRegion: "eu-west-2", STSEndpoint: sts.URL}
+	})
+
+	AfterEach(func() {
+		sts.Close()
+	})
+
+	Context("With static credentials", func() {
+		It("Should use the credentials and the endpoint", func() {
+			sess, err := config.NewSession(context.Background(), map[string]interface{}{
+				"region":   "eu-west-1",
+				"endpoint": "https://aws.example.com",
+			}, []byte(`{"accessKeyID": "AKIASTATIC", "secretAccessKey": "secret"}`))
+			Expect(err).To(BeNil())
+			Expect(*sess.Config.Endpoint).To(Equal("https://aws.example.com"))
+
+			value, err := sess.Config.Credentials.Get()
+			Expect(err).To(BeNil())
+			Expect(value.AccessKeyID).To(Equal("AKIASTATIC"))
+		})
+	})
+
+	Context("Without credentials", func() {
+		It("Should use the default credential chain", func() {
+			os.Setenv("AWS_ACCESS_KEY_ID", "AKIAENVIRONMENT")
+			os.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+			defer os.Unsetenv("AWS_ACCESS_KEY_ID")
+			defer os.Unsetenv("AWS_SECRET_ACCESS_KEY")
+
+			sess, err := config.NewSession(ctx, map[string]interface{}{"region": ""}, nil)
+			Expect(err).To(BeNil())
+			Expect(*sess.Config.Region).To(Equal("eu-west-2"))
+
+			value, err := sess.Config.Credentials.Get()
+			Expect(err).To(BeNil())
+			Expect(value.AccessKeyID).To(Equal("AKIAENVIRONMENT"))
+		})
+
+		It("Should fail unless ambient credentials are allowed", func() {
+			for _, provider := range []string{"", utils.AWSCredentialsDefault, utils.AWSCredentialsWebIdentity, utils.AWSCredentialsInstanceProfile} {
+				_, err := config.NewSession(context.Background(), map[string]interface{}{
+					"region":              "eu-west-1",
+					"credentialsProvider": provider,
+				}, nil)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("does not allow ambient credentials"))
+			}
+		})
+	})
+
+	Context("With a role to assume", func() {
+		It("Should assume the role with the external ID and session tags", func() {
+			sess, err := config.NewSession(context.Background(), map[string]interface{}{
+				"region":   "eu-west-1",
+				"endpoint": "https://aws.example.com",
+				"assumeRole": map[string]interface{}{
+					"roleArn":     "arn:aws:iam::123456789012:role/secrets",
+					"externalId":  "tenant-a",
+					"sessionName": "externalsecret-operator",
+					"duration":    "1h",
+					"sessionTags": map[string]interface{}{"team": "payments"},
+				},
+			}, []byte(`{"accessKeyID": "AKIASTATIC", "secretAccessKey": "secret"}`))
+			Expect(err).To(BeNil())
+
+			value, err := sess.Config.Credentials.Get()
+			Expect(err).To(BeNil())
+			Expect(value.AccessKeyID).To(Equal("ASIAAssumeRole"))
+			Expect(requests).To(HaveLen(1))
+			Expect(requests[0].Get("RoleArn")).To(Equal("arn:aws:iam::123456789012:role/secrets"))
+			Expect(requests[0].Get("ExternalId")).To(Equal("tenant-a"))
+			Expect(requests[0].Get("RoleSessionName")).To(Equal("externalsecret-operator"))
+			Expect(requests[0].Get("Tags.member.1.Key")).To(Equal("team"))
+			Expect(requests[0].Get("Tags.member.1.Value")).To(Equal("payments"))
+		})
+
+		It("Should fail without a role ARN", func() {
+			_, err := config.NewSession(ctx, map[string]interface{}{
+				"region":     "eu-west-1",
+				"assumeRole": map[string]interface{}{"externalId": "tenant-a"},
+			}, nil)
+			Expect(err).To(MatchError("AWS assumeRole needs a roleArn"))
+		})
+	})
+
+	Context("With a web identity", func() {
+		It("Should exchange the token of the token file", func() {
+			dir, err := ioutil.TempDir("", "web-identity")
+			Expect(err).To(BeNil())
+			defer os.RemoveAll(dir)
+			tokenFile := filepath.Join(dir, "token")
+			Expect(ioutil.WriteFile(tokenFile, []byte("service-account-token"), 0600)).To(Succeed())
+
+			os.Setenv("AWS_ROLE_ARN", "arn:aws:iam::123456789012:role/operator")
+			os.Setenv("AWS_WEB_IDENTITY_TOKEN_FILE", tokenFile)
+			defer os.Unsetenv("AWS_ROLE_ARN")
+			defer os.Unsetenv("AWS_WEB_IDENTITY_TOKEN_FILE")
+
+			sess, err := config.NewSession(ctx, map[string]interface{}{
+				"region":              "eu-west-1",
+				"endpoint":            "https://aws.example.com",
+				"credentialsProvider": utils.AWSCredentialsWebIdentity,
+				"webIdentity": map[string]interface{}{
+					"roleArn":   "arn:aws:iam::123456789012:role/irsa",
+					"tokenFile": tokenFile,
+				},
+			}, nil)
+			Expect(err).To(BeNil())
+
+			value, err := sess.Config.Credentials.Get()
+			Expect(err).To(BeNil())
+			Expect(value.AccessKeyID).To(Equal("ASIAAssumeRoleWithWebIdentity"))
+			Expect(requests).To(HaveLen(1))
+			Expect(requests[0].Get("RoleArn")).To(Equal("arn:aws:iam::123456789012:role/irsa"))
+			Expect(requests[0].Get("WebIdentityToken")).To(Equal("service-account-token"))
+		})
+
+		It("Should refuse other token files", func() {
+			os.Setenv("AWS_ROLE_ARN", "arn:aws:iam::123456789012:role/operator")
+			os.Setenv("AWS_WEB_IDENTITY_TOKEN_FILE", "/var/run/secrets/eks.amazonaws.com/serviceaccount/token")
+			defer os.Unsetenv("AWS_ROLE_ARN")
+			defer os.Unsetenv("AWS_WEB_IDENTITY_TOKEN_FILE")
+
+			_, err := config.NewSession(ctx, map[string]interface{}{
+				"region":              "eu-west-1",
+				"credentialsProvider": utils.AWSCredentialsWebIdentity,
+				"webIdentity": map[string]interface{}{
+					"roleArn":   "arn:aws:iam::123456789012:role/irsa",
+					"tokenFile": "/var/run/secrets/kubernetes.io/serviceaccount/token",
+				},
+			}, nil)
+			Expect(err).To(MatchError("AWS web identity tokenFile /var/run/secrets/kubernetes.io/serviceaccount/token is not the AWS_WEB_IDENTITY_TOKEN_FILE of the operator"))
+			Expect(requests).To(BeEmpty())
+		})
+
+		It("Should fail without a token file", func() {
+			os.Unsetenv("AWS_WEB_IDENTITY_TOKEN_FILE")
+			_, err := config.NewSession(ctx, map[string]interface{}{
+				"region":              "eu-west-1",
+				"credentialsProvider": utils.AWSCredentialsWebIdentity,
+				"webIdentity":         map[string]interface{}{"roleArn": "arn:aws:iam::123456789012:role/irsa"},
+			}, nil)
+			Expect(err).To(MatchError("AWS web identity needs a roleArn and a tokenFile"))
+		})
+	})
+
+	Context("With an unknown credentials provider", func() {
+		It("Should fail", func() {
+			_, err := config.NewSession(ctx, map[string]interface{}{
+				"region":              "eu-west-1",
+				"credentialsProvider": "vault",
+			}, nil)
+			Expect(err).To(MatchError(`unknown AWS credentialsProvider "vault"`))
+		})
+	})
+})
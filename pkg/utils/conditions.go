@@ -0,0 +1,26 @@
+package utils
+
+import (
+	"context"
+	"reflect"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// SetStatusCondition sets condition in conditions, the status conditions of
+// obj, and updates the status of obj with c. The status is only written when
+// the condition changes.
+func SetStatusCondition(ctx context.Context, c client.StatusClient, obj runtime.Object, conditions *[]metav1.Condition, condition metav1.Condition) error {
+	updated := append([]metav1.Condition{}, *conditions...)
+	meta.SetStatusCondition(&updated, condition)
+
+	if reflect.DeepEqual(updated, *conditions) {
+		return nil
+	}
+
+	*conditions = updated
+	return c.Status().Update(ctx, obj)
+}
@@ -0,0 +1,53 @@
+package utils_test
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	storev1alpha1 "github.com/containersolutions/externalsecret-operator/apis/store/v1alpha1"
+	utils "github.com/containersolutions/externalsecret-operator/pkg/utils"
+)
+
+// countingStatusClient counts the status updates
+type countingStatusClient struct {
+	updates int
+}
+
+func (c *countingStatusClient) Status() client.StatusWriter {
+	return c
+}
+
+func (c *countingStatusClient) Update(ctx context.Context, obj runtime.Object, opts ...client.UpdateOption) error {
+	c.updates++
+	return nil
+}
+
+func (c *countingStatusClient) Patch(ctx context.Context, obj runtime.Object, patch client.Patch, opts ...client.PatchOption) error {
+	return nil
+}
+
+var _ = Describe("SetStatusCondition", func() {
+	It("Should only update the status when the condition changes", func() {
+		c := &countingStatusClient{}
+		secretStore := &storev1alpha1.SecretStore{}
+		ready := metav1.Condition{Type: "Ready", Status: metav1.ConditionTrue, Reason: "Healthy"}
+
+		Expect(utils.SetStatusCondition(context.Background(), c, secretStore, &secretStore.Status.Conditions, ready)).To(Succeed())
+		Expect(c.updates).To(Equal(1))
+		Expect(secretStore.Status.Conditions).To(HaveLen(1))
+		Expect(secretStore.Status.Conditions[0].Reason).To(Equal("Healthy"))
+
+		Expect(utils.SetStatusCondition(context.Background(), c, secretStore, &secretStore.Status.Conditions, ready)).To(Succeed())
+		Expect(c.updates).To(Equal(1))
+
+		ready.Status, ready.Reason = metav1.ConditionFalse, "Unhealthy"
+		Expect(utils.SetStatusCondition(context.Background(), c, secretStore, &secretStore.Status.Conditions, ready)).To(Succeed())
+		Expect(c.updates).To(Equal(2))
+		Expect(secretStore.Status.Conditions[0].Reason).To(Equal("Unhealthy"))
+	})
+})
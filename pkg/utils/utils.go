@@ -1,22 +1,29 @@
 package utils
 
 import (
+	"context"
 	"crypto/rand"
 	"encoding/json"
 	"fmt"
 	"math/big"
+	"os"
+	"sort"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/credentials/ec2rolecreds"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
 	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sts"
 	ctrl "sigs.k8s.io/controller-runtime"
+
+	"github.com/containersolutions/externalsecret-operator/pkg/backend"
 )
 
 const validObjChars = "0123456789abcdefghijklmnopqrstuvwxyz"
 
-var (
-	log = ctrl.Log.WithName("asm")
-)
+var log = ctrl.Log.WithName("asm")
 
 // RandomBytes generate random bytes
 func RandomBytes(n int) ([]byte, error) {
@@ -65,18 +72,47 @@ type AWSCredentials struct {
 	SessionToken    string
 }
 
-/* GetAWSSession returns an aws.session.Session based on the parameters or environment variables
-* If parameters are not present or incomplete (secret key, access key AND region)
-* then let default config loading order to go on:
+// AWS credentials providers selected by the credentialsProvider parameter
+const (
+	// AWSCredentialsStatic uses the access keys of the credentials Secret
+	AWSCredentialsStatic = "static"
+	// AWSCredentialsDefault uses the default credential chain of the SDK:
+	// environment, shared files, web identity from environment and instance roles
+	AWSCredentialsDefault = "default"
+	// AWSCredentialsWebIdentity exchanges a web identity token, e.g. the IRSA
+	// service account token, for role credentials
+	AWSCredentialsWebIdentity = "webIdentity"
+	// AWSCredentialsInstanceProfile uses the EC2 instance profile credentials
+	AWSCredentialsInstanceProfile = "instanceProfile"
+)
+
+// AWSSessionConfig configures the AWS sessions of the backends, as opposed to
+// the parameters which are set by the SecretStore
+type AWSSessionConfig struct {
+	// DefaultRegion is used when the region parameter is empty
+	DefaultRegion string
+	// STSEndpoint overrides the endpoint the credentials are exchanged with,
+	// the endpoint of AWS when empty
+	STSEndpoint string
+}
+
+// GetAWSSession returns a session of the AWSSessionConfig with defaultRegion,
+// see AWSSessionConfig.NewSession
+func GetAWSSession(ctx context.Context, parameters map[string]interface{}, creds []byte, defaultRegion string) (*session.Session, error) {
+	return AWSSessionConfig{DefaultRegion: defaultRegion}.NewSession(ctx, parameters, creds)
+}
+
+/* NewSession returns an aws.session.Session based on the parameters and credentials
+* The credentialsProvider parameter selects where credentials come from, it is
+* "static" when credentials are passed and "default" otherwise, falling back to
+* the default config loading order:
 * https://docs.aws.amazon.com/sdk-for-go/api/aws/session/
+* Providers other than "static" use the ambient credentials of the operator and
+* need ctx to allow them, see backend.AmbientCredentials.
+* The assumeRole parameter assumes a role with these credentials and the endpoint
+* parameter overrides the endpoint of the AWS APIs, except STS.
  */
-func GetAWSSession(parameters map[string]interface{}, creds []byte, defaultRegion string) (*session.Session, error) {
-	awsCreds := &AWSCredentials{}
-	if err := json.Unmarshal(creds, awsCreds); err != nil {
-		log.Error(err, "Unmarshalling failed")
-		return nil, err
-	}
-
+func (c AWSSessionConfig) NewSession(ctx context.Context, parameters map[string]interface{}, creds []byte) (*session.Session, error) {
 	region, ok := parameters["region"].(string)
 	if !ok {
 		log.Error(nil, "AWS region parameter missing")
@@ -84,14 +120,152 @@ func GetAWSSession(parameters map[string]interface{}, creds []byte, defaultRegio
 	}
 
 	if region == "" {
-		region = defaultRegion
+		region = c.DefaultRegion
+	}
+
+	// Credentials are exchanged with the STS endpoint of AWS, never with the
+	// endpoint parameter which is set by the SecretStore
+	config := aws.NewConfig().WithRegion(region)
+	if c.STSEndpoint != "" {
+		config = config.WithEndpoint(c.STSEndpoint)
+	}
+
+	sess, err := session.NewSession(config)
+	if err != nil {
+		return nil, err
 	}
 
-	return session.NewSession(&aws.Config{
-		Region: aws.String(region),
-		Credentials: credentials.NewStaticCredentials(
+	awsCredentials, err := getAWSCredentials(ctx, sess, parameters, creds)
+	if err != nil {
+		return nil, err
+	}
+
+	assumeRole, err := mapParameter(parameters, "assumeRole")
+	if err != nil {
+		return nil, err
+	}
+	if assumeRole != nil {
+		awsCredentials, err = assumeRoleCredentials(sess.Copy(&aws.Config{Credentials: awsCredentials}), assumeRole)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	clientConfig := &aws.Config{Credentials: awsCredentials, Endpoint: aws.String("")}
+	if endpoint, _ := parameters["endpoint"].(string); endpoint != "" {
+		clientConfig.Endpoint = aws.String(endpoint)
+	}
+	return sess.Copy(clientConfig), nil
+}
+
+// getAWSCredentials returns the credentials of the credentialsProvider parameter
+func getAWSCredentials(ctx context.Context, sess *session.Session, parameters map[string]interface{}, creds []byte) (*credentials.Credentials, error) {
+	provider, _ := parameters["credentialsProvider"].(string)
+	if provider == "" {
+		provider = AWSCredentialsDefault
+		if len(creds) > 0 {
+			provider = AWSCredentialsStatic
+		}
+	}
+
+	switch provider {
+	case AWSCredentialsDefault, AWSCredentialsWebIdentity, AWSCredentialsInstanceProfile:
+		if !backend.AmbientCredentials(ctx) {
+			return nil, fmt.Errorf("AWS credentialsProvider %q uses the credentials of the operator, which does not allow ambient credentials", provider)
+		}
+	}
+
+	switch provider {
+	case AWSCredentialsStatic:
+		awsCreds := &AWSCredentials{}
+		if err := json.Unmarshal(creds, awsCreds); err != nil {
+			log.Error(err, "Unmarshalling failed")
+			return nil, err
+		}
+		return credentials.NewStaticCredentials(
 			awsCreds.AccessKeyID,
 			awsCreds.SecretAccessKey,
-			awsCreds.SessionToken),
-	})
+			awsCreds.SessionToken), nil
+	case AWSCredentialsDefault:
+		return sess.Config.Credentials, nil
+	case AWSCredentialsWebIdentity:
+		webIdentity, err := mapParameter(parameters, "webIdentity")
+		if err != nil {
+			return nil, err
+		}
+		roleArn := stringParameter(webIdentity, "roleArn", os.Getenv("AWS_ROLE_ARN"))
+		// Only the token meant for AWS is exchanged, other files such as the
+		// service account token of the operator must not leave the pod
+		tokenFile := os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE")
+		if file := stringParameter(webIdentity, "tokenFile", tokenFile); file != tokenFile {
+			return nil, fmt.Errorf("AWS web identity tokenFile %s is not the AWS_WEB_IDENTITY_TOKEN_FILE of the operator", file)
+		}
+		if roleArn == "" || tokenFile == "" {
+			return nil, fmt.Errorf("AWS web identity needs a roleArn and a tokenFile")
+		}
+		sessionName := stringParameter(webIdentity, "sessionName", os.Getenv("AWS_ROLE_SESSION_NAME"))
+		return stscreds.NewWebIdentityCredentials(sess, roleArn, sessionName, tokenFile), nil
+	case AWSCredentialsInstanceProfile:
+		return ec2rolecreds.NewCredentials(sess), nil
+	}
+	return nil, fmt.Errorf("unknown AWS credentialsProvider %q", provider)
+}
+
+// assumeRoleCredentials returns the credentials of the role of the assumeRole
+// parameter, assumed with the credentials of sess
+func assumeRoleCredentials(sess *session.Session, assumeRole map[string]interface{}) (*credentials.Credentials, error) {
+	roleArn := stringParameter(assumeRole, "roleArn", "")
+	if roleArn == "" {
+		return nil, fmt.Errorf("AWS assumeRole needs a roleArn")
+	}
+
+	var duration time.Duration
+	if value := stringParameter(assumeRole, "duration", ""); value != "" {
+		var err error
+		if duration, err = time.ParseDuration(value); err != nil {
+			return nil, fmt.Errorf("invalid AWS assumeRole duration: %w", err)
+		}
+	}
+
+	tagValues, err := mapParameter(assumeRole, "sessionTags")
+	if err != nil {
+		return nil, err
+	}
+	tags := make([]*sts.Tag, 0, len(tagValues))
+	for key, value := range tagValues {
+		tags = append(tags, &sts.Tag{Key: aws.String(key), Value: aws.String(fmt.Sprint(value))})
+	}
+	sort.Slice(tags, func(i, j int) bool { return *tags[i].Key < *tags[j].Key })
+
+	return stscreds.NewCredentials(sess, roleArn, func(p *stscreds.AssumeRoleProvider) {
+		if externalID := stringParameter(assumeRole, "externalId", ""); externalID != "" {
+			p.ExternalID = aws.String(externalID)
+		}
+		p.RoleSessionName = stringParameter(assumeRole, "sessionName", "")
+		p.Duration = duration
+		if len(tags) > 0 {
+			p.Tags = tags
+		}
+	}), nil
+}
+
+// mapParameter returns the object parameter name of parameters, nil when it is not set
+func mapParameter(parameters map[string]interface{}, name string) (map[string]interface{}, error) {
+	value, ok := parameters[name]
+	if !ok || value == nil {
+		return nil, nil
+	}
+	values, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid AWS %s parameter: %v", name, value)
+	}
+	return values, nil
+}
+
+// stringParameter returns the string parameter name of parameters or defaultValue
+func stringParameter(parameters map[string]interface{}, name string, defaultValue string) string {
+	if value, _ := parameters[name].(string); value != "" {
+		return value
+	}
+	return defaultValue
 }